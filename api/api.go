@@ -6,33 +6,215 @@ import (
 	"errors"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/jemgunay/canlendar-graph/auth"
+	"github.com/jemgunay/canlendar-graph/bac"
+	"github.com/jemgunay/canlendar-graph/budget"
 	"github.com/jemgunay/canlendar-graph/calendar"
+	"github.com/jemgunay/canlendar-graph/collect"
+	"github.com/jemgunay/canlendar-graph/config"
+	"github.com/jemgunay/canlendar-graph/goal"
+	"github.com/jemgunay/canlendar-graph/guideline"
+	"github.com/jemgunay/canlendar-graph/impute"
+	"github.com/jemgunay/canlendar-graph/session"
+	"github.com/jemgunay/canlendar-graph/stats"
 	"github.com/jemgunay/canlendar-graph/storage"
+	"github.com/jemgunay/canlendar-graph/taper"
+	"github.com/jemgunay/canlendar-graph/tenant"
+	"github.com/jemgunay/canlendar-graph/units"
+	"github.com/jemgunay/canlendar-graph/watch"
 )
 
 // API defines the HTTP handlers.
 type API struct {
 	storer     storage.Storer
-	calFetcher calendar.Fetcher
+	calendars  calendar.Provider
+	users      *tenant.Directory
+	imputer    impute.Imputer
+	guidelines guideline.Set
+	unlogged   stats.Policy
+	budgets    budget.Planner
+	sessions   session.Detector
+	bac        bac.Estimator
+	goals      goal.Storer
+	taper      taper.Storer
+	shares     *auth.ShareSigner
+	jobs       *collect.Tracker
+	locker     storage.Locker
+	lockTTL    time.Duration
+	runs       storage.RunStore
+	watches    *watch.Manager
+	now        func() time.Time
+
+	// collecting tracks running collect jobs
+	collecting sync.WaitGroup
+}
+
+// Option is used to provide optional configuration to an API.
+type Option func(a *API)
+
+// WithImputer defines the imputer used to resolve units for events logged as unknown.
+func WithImputer(imputer impute.Imputer) Option {
+	return func(a *API) {
+		a.imputer = imputer
+	}
+}
+
+// WithGuidelines defines the set of guideline profiles which can be selected when querying.
+func WithGuidelines(guidelines guideline.Set) Option {
+	return func(a *API) {
+		a.guidelines = guidelines
+	}
+}
+
+// WithUnloggedPolicy defines how days with no logged calendar entries are treated when calculating dry days.
+// Unsupported policies are ignored.
+func WithUnloggedPolicy(policy stats.Policy) Option {
+	return func(a *API) {
+		if policy.IsValid() {
+			a.unlogged = policy
+		}
+	}
+}
+
+// WithBudget defines the planner used to determine unit budgets.
+func WithBudget(planner budget.Planner) Option {
+	return func(a *API) {
+		a.budgets = planner
+	}
 }
 
-// New initialises an API.
-func New(storer storage.Storer, calFetcher calendar.Fetcher) *API {
-	return &API{
-		storer:     storer,
-		calFetcher: calFetcher,
+// WithSessionDetector defines the detector used to group events into drinking sessions.
+func WithSessionDetector(detector session.Detector) Option {
+	return func(a *API) {
+		a.sessions = detector
 	}
 }
 
+// WithBACEstimator defines the estimator used to estimate blood alcohol concentration.
+func WithBACEstimator(estimator bac.Estimator) Option {
+	return func(a *API) {
+		a.bac = estimator
+	}
+}
+
+// WithGoalStore defines the store used to persist goals. Goal endpoints are unavailable if no store is provided.
+func WithGoalStore(goals goal.Storer) Option {
+	return func(a *API) {
+		a.goals = goals
+	}
+}
+
+// WithTaperStore defines the store used to persist the tapering plan. Tapering plan endpoints are unavailable if no
+// store is provided.
+func WithTaperStore(store taper.Storer) Option {
+	return func(a *API) {
+		a.taper = store
+	}
+}
+
+// WithUsers defines the users served by the API. Storage is partitioned by user.
+func WithUsers(users *tenant.Directory) Option {
+	return func(a *API) {
+		a.users = users
+	}
+}
+
+// WithLocker defines the locker used to ensure that only one collect job runs at a time across all instances. The lock
+// expires after the TTL unless it is refreshed. Only one collect job runs at a time per instance if no locker is
+// provided.
+func WithLocker(locker storage.Locker, ttl time.Duration) Option {
+	return func(a *API) {
+		a.locker = locker
+		a.lockTTL = ttl
+	}
+}
+
+// WithRunStore defines the store used to persist the history of collect runs and each user's collection watermark.
+// Collection resumes from the day after the last written record, and collect history is unavailable, if no store is
+// provided.
+func WithRunStore(runs storage.RunStore) Option {
+	return func(a *API) {
+		a.runs = runs
+	}
+}
+
+// WithJobStore defines the store used to persist the status and progress of collect jobs, such that a job can be
+// polled from any instance. Jobs can only be polled from the instance which started them if no store is provided.
+func WithJobStore(store collect.Store) Option {
+	return func(a *API) {
+		a.jobs = collect.NewTracker(store)
+	}
+}
+
+// WithWatchManager defines the manager used to verify watch channel notifications. Notifications are rejected if no
+// manager is provided.
+func WithWatchManager(manager *watch.Manager) Option {
+	return func(a *API) {
+		a.watches = manager
+	}
+}
+
+// WithShareSigner defines the signer used to mint share links. Share links cannot be minted if no signer is provided.
+func WithShareSigner(signer *auth.ShareSigner) Option {
+	return func(a *API) {
+		a.shares = signer
+	}
+}
+
+// New initialises an API. Unknown units are imputed as the maximum recommended weekly units and UK guidelines are used
+// unless alternatives are provided. Days with no logged entries are assumed to be dry and budgets default to the
+// guideline limits. A single user is served unless users are provided.
+func New(storer storage.Storer, calendars calendar.Provider, opts ...Option) *API {
+	a := &API{
+		calendars: calendars,
+		users:     tenant.Single(tenant.User{}),
+		jobs:      collect.NewTracker(nil),
+		imputer: impute.New(config.Impute{
+			Strategy:   impute.Fixed.String(),
+			FixedUnits: calendar.MaxRecommendedWeeklyUnits,
+		}),
+		guidelines: guideline.New(config.Guideline{Profile: guideline.UK}),
+		unlogged:   stats.AssumeDry,
+		budgets:    budget.New(config.Budget{Projection: budget.Linear.String()}),
+		sessions:   session.New(config.Session{GapMinutes: 180, DrinkingDayCutoffHour: 6}),
+		bac:        bac.New(config.Body{WeightKg: 70, Sex: "male"}),
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	a.storer = userStorer{storer: storer, users: a.users}
+	return a
+}
+
 type queryResponse struct {
 	Plots    []storage.Plot    `json:"plots"`
 	Metadata queryResponseMeta `json:"metadata"`
 }
 
 type queryResponseMeta struct {
-	Guideline float64 `json:"guideline,omitempty"`
+	Guideline        float64                `json:"guideline,omitempty"`
+	GuidelinePlots   []storage.Plot         `json:"guideline_plots,omitempty"`
+	GuidelineProfile string                 `json:"guideline_profile"`
+	DailyLimit       float64                `json:"daily_limit,omitempty"`
+	WeeklyLimit      float64                `json:"weekly_limit"`
+	Totals           *queryTotals           `json:"totals,omitempty"`
+	Tiers            *guideline.TierSummary `json:"tiers,omitempty"`
+	TaperPlots       []storage.Plot         `json:"taper_plots,omitempty"`
+	Unit             units.Unit             `json:"unit"`
+}
+
+// queryTotals splits the units total for the queried range into observed and imputed units, and counts the imputed
+// entries. Entries excluded by the imputation strategy contribute no units, but are still counted.
+type queryTotals struct {
+	Observed       float64 `json:"observed"`
+	Imputed        float64 `json:"imputed"`
+	ImputedEntries int     `json:"imputed_entries"`
 }
 
 // Query collects calendar data from storage and returns it as plottable data points.
@@ -43,6 +225,21 @@ func (a *API) Query(w http.ResponseWriter, r *http.Request) {
 	aggregation := storage.Aggregation(query.Get("aggregation"))
 	startTime, _ := time.Parse(time.RFC3339, query.Get("start_time"))
 	endTime, _ := time.Parse(time.RFC3339, query.Get("end_time"))
+	splitImputed, _ := strconv.ParseBool(query.Get("split_imputed"))
+
+	outputUnit, ok := parseUnit(query)
+	if !ok {
+		log.Printf("unsupported output unit: %s", outputUnit)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	profile, ok := a.profile(ctx, query)
+	if !ok {
+		log.Printf("unsupported guideline profile: %s", query.Get("guideline"))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
 
 	opts := []storage.QueryOption{
 		storage.WithAggregation(aggregation),
@@ -57,119 +254,104 @@ func (a *API) Query(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// default 0 units for Day aggregation - only show a guideline for Week aggregation and higher
-	var guidelineUnits float64
-	switch aggregation {
-	case storage.Week:
-		guidelineUnits = calendar.MaxRecommendedWeeklyUnits
-	case storage.Month:
-		guidelineUnits = calendar.MaxRecommendedWeeklyUnits * 4
-	case storage.Year:
-		guidelineUnits = calendar.MaxRecommendedWeeklyUnits * 4 * 12
-	}
-
 	resp := queryResponse{
 		Metadata: queryResponseMeta{
-			Guideline: guidelineUnits,
+			Guideline:        profile.AverageLimit(aggregation),
+			GuidelineProfile: profile.Name,
+			DailyLimit:       profile.DailyUnits(),
+			WeeklyLimit:      profile.WeeklyUnits(),
 		},
-		Plots: records,
+		Plots: profile.Classify(aggregation, records),
 	}
+	resp.Metadata.Tiers = guideline.SummariseTiers(resp.Plots)
 
-	// JSON encode response
-	w.Header().Set("Content-Type", "application/json")
-	encoder := json.NewEncoder(w)
-	encoder.SetEscapeHTML(false)
-	if err := encoder.Encode(resp); err != nil {
-		log.Printf("failed to JSON encode response: %s", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-}
-
-type collectPayload struct {
-	StartTime time.Time `json:"start_time_override"`
-}
-
-// Collect scrapes the Google calendar API for new events (i.e. those created since the last scraped event) and writes
-// them to storage.
-func (a *API) Collect(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-
-	// get start time override from body
-	payload := collectPayload{}
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		log.Printf("unable to decode request body: %s", err)
-		w.WriteHeader(http.StatusBadRequest)
-		return
+	// only plot a guideline if the profile has a limit for the aggregation, i.e. not for Day aggregation if there is no
+	// daily limit
+	if resp.Metadata.Guideline > 0 {
+		resp.Metadata.GuidelinePlots = profile.Plots(aggregation, records)
 	}
 
-	// if no override, get the timestamp for the last written storage record. If there are no records in storage then
-	// the start of time will be used
-	if payload.StartTime.IsZero() {
-		var err error
-		if payload.StartTime, err = a.getLastTimestamp(ctx); err != nil {
-			log.Printf("failed to read last written timestamp from storage: %s", err)
+	// overlay the weekly targets of the tapering plan, if any
+	if a.taper != nil && aggregation == storage.Week {
+		plan, err := a.taper.Get(ctx)
+		switch {
+		case err == nil:
+			resp.Metadata.TaperPlots = plan.Targets()
+		case !errors.Is(err, taper.ErrNotFound):
+			log.Printf("failed to get tapering plan: %s", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-
-		// add 24h to ensure we don't recollect the last event
-		payload.StartTime = payload.StartTime.Add(time.Hour * 24)
 	}
 
-	// fetch calendar events for time range
-	eventIter, err := a.calFetcher.Fetch(ctx, payload.StartTime)
-	if err != nil {
-		if err == calendar.ErrNoEventsFound {
-			log.Printf("no new events found since %s", payload.StartTime.Format(time.RFC3339))
-			w.WriteHeader(http.StatusNoContent)
+	if splitImputed {
+		totals := &queryTotals{}
+		if totals.Observed, err = a.queryTotal(ctx, append(opts, storage.WithImputed(false))...); err != nil {
+			log.Printf("failed to query observed total from storage: %s", err)
+			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-		log.Printf("failed to fetch calendar events: %s", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	// process calendar events into
-	records := make([]storage.Record, 0, eventIter.Count())
-	for {
-		ev, err := eventIter.Next()
+		if totals.Imputed, err = a.queryTotal(ctx, append(opts, storage.WithImputed(true))...); err != nil {
+			log.Printf("failed to query imputed total from storage: %s", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		imputedEntries, err := a.queryTotal(ctx,
+			append(opts, storage.WithImputed(true), storage.WithAggregateFunc(storage.Count))...)
 		if err != nil {
-			if errors.Is(err, calendar.ErrNoMoreEvents) {
-				break
-			}
-			log.Printf("failed to read event: %s", err)
-			continue
+			log.Printf("failed to query imputed entry count from storage: %s", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
 		}
+		totals.ImputedEntries = int(imputedEntries)
+		resp.Metadata.Totals = totals
+	}
+
+	resp.convert(outputUnit)
+	writeJSON(w, resp)
+}
 
-		records = append(records, storage.Record{
-			Time: ev.Date,
-			Fields: map[string]interface{}{
-				"units": ev.Units,
-			},
-		})
+// convert converts the plots and guideline metadata from UK units to the provided unit.
+func (q *queryResponse) convert(unit units.Unit) {
+	q.Plots = unit.FromUKPlots(q.Plots)
+	q.Metadata.Guideline = unit.FromUK(q.Metadata.Guideline)
+	q.Metadata.GuidelinePlots = unit.FromUKPlots(q.Metadata.GuidelinePlots)
+	q.Metadata.DailyLimit = unit.FromUK(q.Metadata.DailyLimit)
+	q.Metadata.WeeklyLimit = unit.FromUK(q.Metadata.WeeklyLimit)
+	q.Metadata.TaperPlots = unit.FromUKPlots(q.Metadata.TaperPlots)
+	if q.Metadata.Totals != nil {
+		q.Metadata.Totals.Observed = unit.FromUK(q.Metadata.Totals.Observed)
+		q.Metadata.Totals.Imputed = unit.FromUK(q.Metadata.Totals.Imputed)
 	}
+	q.Metadata.Unit = unit
+}
+
+// parseUnit parses the output unit query parameter, defaulting to UK units. Returns false if the unit is unsupported.
+func parseUnit(query url.Values) (units.Unit, bool) {
+	outputUnit := units.UK
+	if u := query.Get("unit"); u != "" {
+		outputUnit = units.Unit(u)
+	}
+	return outputUnit, outputUnit.IsValid()
+}
 
-	// persist new events to storage
-	if err := a.storer.Store(ctx, records...); err != nil {
-		log.Printf("failed to persist events to storage: %s", err)
+// writeJSON JSON encodes the response body.
+func writeJSON(w http.ResponseWriter, resp interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(resp); err != nil {
+		log.Printf("failed to JSON encode response: %s", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 }
 
-// getLastTimestamp reads the last written unit timestamp from storage. If there were no results in storage, then the
-// value representing the start of time is returned.
-func (a *API) getLastTimestamp(ctx context.Context) (time.Time, error) {
-	startTime, err := a.storer.ReadLastTimestamp(ctx)
+// queryTotal sums the units of all plots for the given query. Zero is returned if there are no results.
+func (a *API) queryTotal(ctx context.Context, opts ...storage.QueryOption) (float64, error) {
+	plots, err := a.queryPlots(ctx, opts...)
 	if err != nil {
-		if errors.Is(err, storage.ErrNoResults) {
-			// default to the start of time
-			return time.Time{}, nil
-		}
-
-		return time.Time{}, err
+		return 0, err
 	}
-
-	return startTime, nil
+	return stats.Total(plots), nil
 }
@@ -11,131 +11,181 @@ import (
 	"time"
 
 	"github.com/golang/mock/gomock"
-	"github.com/jemgunay/canlendar-graph/calendar"
 	"github.com/jemgunay/canlendar-graph/storage"
 
-	mock_calendar "github.com/jemgunay/canlendar-graph/calendar/mocks"
 	mock_storage "github.com/jemgunay/canlendar-graph/storage/mocks"
 )
 
-func TestAPI_Collect(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
-
-	now := time.Now()
-
-	// generate events for the calendar event iterator
-	mockIter := mock_calendar.NewMockEventIterator(ctrl)
-	eventCount := 100
-	mockIter.EXPECT().Count().Return(eventCount)
-	var current int
-	mockIter.EXPECT().Next().DoAndReturn(
-		func() (calendar.Event, error) {
-			if current == eventCount {
-				return calendar.Event{}, calendar.ErrNoMoreEvents
-			}
-
-			ev := calendar.Event{
-				Date:  now.Add(-time.Hour * 24 * time.Duration(current)),
-				Units: float64(current % 10),
-			}
-			current++
-			return ev, nil
-		},
-	).AnyTimes()
-
-	mockCalendar := mock_calendar.NewMockFetcher(ctrl)
-	mockCalendar.EXPECT().Fetch(gomock.Any(), gomock.Any()).Return(mockIter, nil)
-
-	mockStorer := mock_storage.NewMockStorer(ctrl)
-	mockStorer.EXPECT().ReadLastTimestamp(gomock.Any()).Return(time.Time{}, storage.ErrNoResults)
-	var storedCount int
-	mockStorer.EXPECT().Store(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, records ...storage.Record) error {
-		storedCount++
-		return nil
-	})
-
-	api := New(mockStorer, mockCalendar)
-
-	w := httptest.NewRecorder()
-	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{}`))
-	api.Collect(w, r)
-
-	// validate status
-	status := w.Result().StatusCode
-	if status != http.StatusOK {
-		t.Fatalf("expected %d, got %d", http.StatusOK, status)
-	}
-
-	expectedStoredCount := 1
-	if storedCount != expectedStoredCount {
-		t.Fatalf("expected %d, got %d", expectedStoredCount, storedCount)
-	}
-}
-
 func TestAPI_Query(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	cases := []struct {
-		name        string
-		aggregation string
-		plots       []storage.Plot
-		status      int
-		respBody    string
+		name           string
+		aggregation    string
+		params         string
+		plots          []storage.Plot
+		observed       []storage.Plot
+		imputed        []storage.Plot
+		imputedEntries []storage.Plot
+		status         int
+		respBody       string
 	}{
 		{
 			name:        "week_empty",
 			aggregation: "week",
 			plots:       []storage.Plot{},
 			status:      http.StatusOK,
-			respBody:    `{"plots":[],"metadata":{"guideline":14}}`,
+			respBody:    `{"plots":[],"metadata":{"guideline":14,"guideline_profile":"uk","weekly_limit":14,"unit":"uk_units"}}`,
 		},
 		{
 			name:        "week_non_empty",
 			aggregation: "week",
 			plots: []storage.Plot{
-				{1, 1},
-				{2, 2},
-				{3, 3},
+				{X: 1, Y: 1},
+				{X: 2, Y: 2},
+				{X: 3, Y: 3},
 			},
 			status:   http.StatusOK,
-			respBody: `{"plots":[{"t":1,"y":1},{"t":2,"y":2},{"t":3,"y":3}],"metadata":{"guideline":14}}`,
+			respBody: `{"plots":[{"t":1,"y":1,"tier":"lower"},{"t":2,"y":2,"tier":"lower"},{"t":3,"y":3,"tier":"lower"}],"metadata":{"guideline":14,"guideline_plots":[{"t":1,"y":14},{"t":2,"y":14},{"t":3,"y":14}],"guideline_profile":"uk","weekly_limit":14,"tiers":{"lower":3,"increasing":0,"higher":0},"unit":"uk_units"}}`,
 		},
 		{
 			name:        "day_non_empty",
 			aggregation: "day",
 			plots: []storage.Plot{
-				{1, 1},
-				{2, 2},
-				{3, 3},
+				{X: 1, Y: 1},
+				{X: 2, Y: 2},
+				{X: 3, Y: 3},
 			},
 			status:   http.StatusOK,
-			respBody: `{"plots":[{"t":1,"y":1},{"t":2,"y":2},{"t":3,"y":3}],"metadata":{}}`,
+			respBody: `{"plots":[{"t":1,"y":1},{"t":2,"y":2},{"t":3,"y":3}],"metadata":{"guideline_profile":"uk","weekly_limit":14,"unit":"uk_units"}}`,
 		},
 		{
 			name:        "month_empty",
 			aggregation: "month",
 			plots:       []storage.Plot{},
 			status:      http.StatusOK,
-			respBody:    `{"plots":[],"metadata":{"guideline":56}}`,
+			respBody:    `{"plots":[],"metadata":{"guideline":60.875,"guideline_profile":"uk","weekly_limit":14,"unit":"uk_units"}}`,
 		},
 		{
 			name:        "year_empty",
 			aggregation: "year",
 			plots:       []storage.Plot{},
 			status:      http.StatusOK,
-			respBody:    `{"plots":[],"metadata":{"guideline":672}}`,
+			respBody:    `{"plots":[],"metadata":{"guideline":730.5,"guideline_profile":"uk","weekly_limit":14,"unit":"uk_units"}}`,
+		},
+		{
+			name:        "week_split_imputed",
+			aggregation: "week",
+			params:      "&split_imputed=true",
+			plots: []storage.Plot{
+				{X: 1, Y: 1},
+				{X: 2, Y: 2},
+			},
+			observed: []storage.Plot{
+				{X: 1, Y: 1},
+				{X: 2, Y: 1},
+			},
+			imputed: []storage.Plot{
+				{X: 2, Y: 1},
+			},
+			// one of the imputed entries was excluded, so contributes no units
+			imputedEntries: []storage.Plot{
+				{X: 2, Y: 2},
+			},
+			status:   http.StatusOK,
+			respBody: `{"plots":[{"t":1,"y":1,"tier":"lower"},{"t":2,"y":2,"tier":"lower"}],"metadata":{"guideline":14,"guideline_plots":[{"t":1,"y":14},{"t":2,"y":14}],"guideline_profile":"uk","weekly_limit":14,"totals":{"observed":2,"imputed":1,"imputed_entries":2},"tiers":{"lower":2,"increasing":0,"higher":0},"unit":"uk_units"}}`,
+		},
+		{
+			name:        "month_calendar_accurate",
+			aggregation: "month",
+			plots: []storage.Plot{
+				{X: 1640995200000, Y: 60},
+				{X: 1643673600000, Y: 50},
+			},
+			status:   http.StatusOK,
+			respBody: `{"plots":[{"t":1640995200000,"y":60,"tier":"lower"},{"t":1643673600000,"y":50,"tier":"lower"}],"metadata":{"guideline":60.875,"guideline_plots":[{"t":1640995200000,"y":62},{"t":1643673600000,"y":56}],"guideline_profile":"uk","weekly_limit":14,"tiers":{"lower":2,"increasing":0,"higher":0},"unit":"uk_units"}}`,
+		},
+		{
+			name:        "day_au_profile",
+			aggregation: "day",
+			params:      "&guideline=au",
+			plots: []storage.Plot{
+				{X: 1, Y: 1},
+			},
+			status:   http.StatusOK,
+			respBody: `{"plots":[{"t":1,"y":1}],"metadata":{"guideline":5,"guideline_plots":[{"t":1,"y":5}],"guideline_profile":"au","daily_limit":5,"weekly_limit":12.5,"unit":"uk_units"}}`,
+		},
+		{
+			name:        "week_grams",
+			aggregation: "week",
+			params:      "&unit=grams",
+			plots: []storage.Plot{
+				{X: 1, Y: 2},
+			},
+			status:   http.StatusOK,
+			respBody: `{"plots":[{"t":1,"y":16,"tier":"lower"}],"metadata":{"guideline":112,"guideline_plots":[{"t":1,"y":112}],"guideline_profile":"uk","weekly_limit":112,"tiers":{"lower":1,"increasing":0,"higher":0},"unit":"grams"}}`,
+		},
+		{
+			name:        "week_us_drinks",
+			aggregation: "week",
+			params:      "&unit=us_drinks&guideline=us_male",
+			plots: []storage.Plot{
+				{X: 1, Y: 7},
+			},
+			status:   http.StatusOK,
+			respBody: `{"plots":[{"t":1,"y":4,"tier":"lower"}],"metadata":{"guideline":14,"guideline_plots":[{"t":1,"y":14}],"guideline_profile":"us_male","daily_limit":4,"weekly_limit":14,"tiers":{"lower":1,"increasing":0,"higher":0},"unit":"us_drinks"}}`,
+		},
+		{
+			name:        "week_risk_tiers",
+			aggregation: "week",
+			params:      "&guideline=uk_male",
+			plots: []storage.Plot{
+				{X: 1, Y: 14},
+				{X: 2, Y: 30},
+				{X: 3, Y: 51},
+			},
+			status:   http.StatusOK,
+			respBody: `{"plots":[{"t":1,"y":14,"tier":"lower"},{"t":2,"y":30,"tier":"increasing"},{"t":3,"y":51,"tier":"higher"}],"metadata":{"guideline":14,"guideline_plots":[{"t":1,"y":14},{"t":2,"y":14},{"t":3,"y":14}],"guideline_profile":"uk_male","weekly_limit":14,"tiers":{"lower":1,"increasing":1,"higher":1},"unit":"uk_units"}}`,
+		},
+		{
+			name:        "unsupported_unit",
+			aggregation: "week",
+			params:      "&unit=pints",
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "unsupported_profile",
+			aggregation: "week",
+			params:      "&guideline=mars",
+			status:      http.StatusBadRequest,
 		},
 	}
 
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
 			mockStorer := mock_storage.NewMockStorer(ctrl)
-			mockStorer.EXPECT().Query(gomock.Any(), gomock.Any()).Return(tt.plots, nil).AnyTimes()
+			mockStorer.EXPECT().Query(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, opts ...storage.QueryOption) ([]storage.Plot, error) {
+					q, err := storage.NewQuery(opts...)
+					if err != nil {
+						return nil, err
+					}
+					switch {
+					case q.Imputed == nil:
+						return tt.plots, nil
+					case *q.Imputed && q.AggregateFunc == storage.Count:
+						return tt.imputedEntries, nil
+					case *q.Imputed:
+						return tt.imputed, nil
+					default:
+						return tt.observed, nil
+					}
+				},
+			).AnyTimes()
 
 			w := httptest.NewRecorder()
-			query := fmt.Sprintf("/?aggregation=%s&end_time=%s", tt.aggregation, time.Now().Format(time.RFC3339))
+			query := fmt.Sprintf("/?aggregation=%s&end_time=%s%s", tt.aggregation, time.Now().Format(time.RFC3339), tt.params)
 			r := httptest.NewRequest(http.MethodGet, query, nil)
 
 			api := New(mockStorer, nil)
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/bac"
+	"github.com/jemgunay/canlendar-graph/storage"
+)
+
+// bacStep is the interval at which the estimated BAC curve is sampled.
+const bacStep = 5 * time.Minute
+
+type bacResponse struct {
+	Date string `json:"date"`
+	bac.Timeline
+}
+
+// BAC estimates the blood alcohol concentration curve for the timed events of a drinking day (date, formatted as
+// YYYY-MM-DD), including the peak BAC and the estimated time at which BAC returns to zero. The date defaults to the
+// current drinking day.
+func (a *API) BAC(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	query := r.URL.Query()
+	date := a.sessions.DrinkingDay(a.now())
+	if d := query.Get("date"); d != "" {
+		var err error
+		if date, err = time.Parse("2006-01-02", d); err != nil {
+			log.Printf("invalid date provided: %s", d)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	// query either side of the date to account for time zones and the drinking day cutoff
+	records, err := a.queryRecords(ctx,
+		storage.WithStartTime(date.AddDate(0, 0, -1)),
+		storage.WithEndTime(date.AddDate(0, 0, 2)),
+	)
+	if err != nil {
+		log.Printf("failed to query records from storage: %s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var dayRecords []storage.Record
+	for _, rec := range records {
+		if rec.Timed() && a.sessions.DrinkingDay(rec.LocalTime()).Equal(date) {
+			dayRecords = append(dayRecords, rec)
+		}
+	}
+
+	resp := bacResponse{
+		Date:     date.Format("2006-01-02"),
+		Timeline: a.bac.Estimate(dayRecords, bacStep),
+	}
+	if resp.Points == nil {
+		resp.Points = []bac.Point{}
+	}
+
+	writeJSON(w, resp)
+}
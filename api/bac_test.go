@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jemgunay/canlendar-graph/bac"
+	"github.com/jemgunay/canlendar-graph/config"
+	"github.com/jemgunay/canlendar-graph/storage"
+
+	mock_storage "github.com/jemgunay/canlendar-graph/storage/mocks"
+)
+
+func TestAPI_BAC(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	record := func(t time.Time, units float64) storage.Record {
+		return storage.Record{
+			Time: t,
+			Fields: map[string]interface{}{
+				storage.UnitsField:     units,
+				storage.TimedField:     true,
+				storage.UTCOffsetField: int64(0),
+			},
+		}
+	}
+
+	mockStorer := mock_storage.NewMockStorer(ctrl)
+	mockStorer.EXPECT().QueryRecords(gomock.Any(), gomock.Any()).Return([]storage.Record{
+		// previous drinking day
+		record(time.Date(2022, 8, 5, 5, 0, 0, 0, time.UTC), 4),
+		record(time.Date(2022, 8, 5, 20, 0, 0, 0, time.UTC), 5),
+		record(time.Date(2022, 8, 5, 21, 0, 0, 0, time.UTC), 5),
+	}, nil)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/?date=2022-08-05", nil)
+
+	api := New(mockStorer, nil, WithBACEstimator(bac.New(config.Body{WeightKg: 80, Sex: "male"})))
+	api.BAC(w, r)
+
+	// validate status
+	status := w.Result().StatusCode
+	if status != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, status)
+	}
+
+	resp := bacResponse{}
+	if err := json.NewDecoder(w.Result().Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode resp body: %s", err)
+	}
+
+	// 5 units of 8g for an 80kg male with a distribution ratio of 0.68, eliminated at 0.015% per hour
+	drink := 5 * 8 / (80 * 1000 * 0.68) * 100
+	expectedPeak := drink - 0.015 + drink
+	expectedPeakTime := time.Date(2022, 8, 5, 21, 0, 0, 0, time.UTC)
+	if !resp.Peak.Time.Equal(expectedPeakTime) {
+		t.Fatalf("expected %s, got %s", expectedPeakTime, resp.Peak.Time)
+	}
+	if math.Abs(resp.Peak.BAC-expectedPeak) > 1e-9 {
+		t.Fatalf("expected %v, got %v", expectedPeak, resp.Peak.BAC)
+	}
+
+	expectedZeroAt := expectedPeakTime.Add(time.Duration(expectedPeak / 0.015 * float64(time.Hour)))
+	if diff := resp.ZeroAt.Sub(expectedZeroAt); diff > time.Second || diff < -time.Second {
+		t.Fatalf("expected %s, got %s", expectedZeroAt, resp.ZeroAt)
+	}
+
+	if first := resp.Points[0].Time; !first.Equal(time.Date(2022, 8, 5, 20, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected timeline to start at the first drink, got %s", first)
+	}
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/budget"
+	"github.com/jemgunay/canlendar-graph/storage"
+	"github.com/jemgunay/canlendar-graph/units"
+)
+
+type bankResponse struct {
+	Weeks            []budget.LedgerWeek `json:"weeks"`
+	Balances         []storage.Plot      `json:"balances"`
+	CarryOver        budget.CarryOver    `json:"carry_over"`
+	GuidelineProfile string              `json:"guideline_profile"`
+	Unit             units.Unit          `json:"unit"`
+}
+
+type bankBalanceResponse struct {
+	budget.LedgerWeek
+	CarryOver        budget.CarryOver `json:"carry_over"`
+	GuidelineProfile string           `json:"guideline_profile"`
+	Unit             units.Unit       `json:"unit"`
+}
+
+// bankLedger is a unit ledger calculated from request parameters, in UK units.
+type bankLedger struct {
+	weeks     []budget.LedgerWeek
+	carryOver budget.CarryOver
+	profile   string
+	unit      units.Unit
+}
+
+// Bank returns a running weekly unit ledger, where a fraction of each week's unused budget is carried over to the
+// following week up to a cap. The start time defaults to the first record in storage and the end time to now. The
+// weekly budget, carry-over fraction and carry-over cap (in the output unit) can be overridden with the budget,
+// carry_over_fraction and carry_over_cap parameters.
+func (a *API) Bank(w http.ResponseWriter, r *http.Request) {
+	ledger, status := a.bankLedger(r, false)
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+		return
+	}
+
+	resp := bankResponse{
+		Weeks:            make([]budget.LedgerWeek, 0, len(ledger.weeks)),
+		Balances:         ledger.unit.FromUKPlots(budget.Balances(ledger.weeks)),
+		CarryOver:        convertCarryOver(ledger.unit, ledger.carryOver),
+		GuidelineProfile: ledger.profile,
+		Unit:             ledger.unit,
+	}
+	for _, week := range ledger.weeks {
+		resp.Weeks = append(resp.Weeks, convertLedgerWeek(ledger.unit, week))
+	}
+
+	writeJSON(w, resp)
+}
+
+// BankBalance returns the current week of the running weekly unit ledger, including the units carried over from the
+// previous week and the current balance. It accepts the same parameters as Bank, excluding the end time.
+func (a *API) BankBalance(w http.ResponseWriter, r *http.Request) {
+	ledger, status := a.bankLedger(r, true)
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+		return
+	}
+
+	resp := bankBalanceResponse{
+		CarryOver:        convertCarryOver(ledger.unit, ledger.carryOver),
+		GuidelineProfile: ledger.profile,
+		Unit:             ledger.unit,
+	}
+	if len(ledger.weeks) > 0 {
+		resp.LedgerWeek = convertLedgerWeek(ledger.unit, ledger.weeks[len(ledger.weeks)-1])
+	}
+
+	writeJSON(w, resp)
+}
+
+// bankLedger calculates the unit ledger from the request parameters. The end time parameter is ignored if the ledger
+// is for the current week. A non-OK status is returned if the ledger could not be calculated.
+func (a *API) bankLedger(r *http.Request, current bool) (bankLedger, int) {
+	ctx := r.Context()
+
+	query := r.URL.Query()
+	startTime, _ := time.Parse(time.RFC3339, query.Get("start_time"))
+	endTime, err := time.Parse(time.RFC3339, query.Get("end_time"))
+	if err != nil || current {
+		endTime = a.now()
+	}
+
+	outputUnit, ok := parseUnit(query)
+	if !ok {
+		log.Printf("unsupported output unit: %s", outputUnit)
+		return bankLedger{}, http.StatusBadRequest
+	}
+
+	profile, ok := a.profile(ctx, query)
+	if !ok {
+		log.Printf("unsupported guideline profile: %s", query.Get("guideline"))
+		return bankLedger{}, http.StatusBadRequest
+	}
+
+	weeklyBudget := a.budgets.Budget(storage.Week, profile, endTime)
+	if b, ok, err := parseUnitParam(query, "budget", outputUnit); err != nil {
+		log.Printf("invalid budget provided: %s", query.Get("budget"))
+		return bankLedger{}, http.StatusBadRequest
+	} else if ok {
+		weeklyBudget = b
+	}
+
+	carryOver := a.budgets.CarryOver()
+	if f := query.Get("carry_over_fraction"); f != "" {
+		if carryOver.Fraction, err = strconv.ParseFloat(f, 64); err != nil {
+			log.Printf("invalid carry_over_fraction provided: %s", f)
+			return bankLedger{}, http.StatusBadRequest
+		}
+	}
+	if c, ok, err := parseUnitParam(query, "carry_over_cap", outputUnit); err != nil {
+		log.Printf("invalid carry_over_cap provided: %s", query.Get("carry_over_cap"))
+		return bankLedger{}, http.StatusBadRequest
+	} else if ok {
+		carryOver.Cap = c
+	}
+	if !carryOver.IsValid() {
+		log.Printf("invalid carry-over provided: %+v", carryOver)
+		return bankLedger{}, http.StatusBadRequest
+	}
+
+	if startTime.IsZero() {
+		startTime, err = a.storer.ReadFirstTimestamp(ctx)
+		if errors.Is(err, storage.ErrNoResults) {
+			// there are no records, so the ledger only covers the week containing the end time
+			return bankLedger{
+				weeks:     budget.Ledger(nil, weeklyBudget, carryOver, endTime, endTime),
+				carryOver: carryOver,
+				profile:   profile.Name,
+				unit:      outputUnit,
+			}, http.StatusOK
+		}
+		if err != nil {
+			log.Printf("failed to read first timestamp from storage: %s", err)
+			return bankLedger{}, http.StatusInternalServerError
+		}
+	}
+	startTime = storage.Week.PeriodStart(startTime)
+
+	weeks, err := a.queryPlots(ctx,
+		storage.WithAggregation(storage.Week),
+		storage.WithStartTime(startTime),
+		storage.WithEndTime(endTime),
+	)
+	if err != nil {
+		log.Printf("failed to query storage: %s", err)
+		return bankLedger{}, http.StatusBadRequest
+	}
+
+	return bankLedger{
+		weeks:     budget.Ledger(weeks, weeklyBudget, carryOver, startTime, endTime),
+		carryOver: carryOver,
+		profile:   profile.Name,
+		unit:      outputUnit,
+	}, http.StatusOK
+}
+
+// parseUnitParam parses a non-negative units parameter in the output unit and converts it to UK units. Returns false if
+// the parameter was not provided.
+func parseUnitParam(query url.Values, key string, unit units.Unit) (float64, bool, error) {
+	v := query.Get(key)
+	if v == "" {
+		return 0, false, nil
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, false, err
+	}
+	if f < 0 {
+		return 0, false, errors.New(key + " must not be negative")
+	}
+	return unit.ToUK(f), true, nil
+}
+
+// convertLedgerWeek converts a ledger week from UK units to the provided unit.
+func convertLedgerWeek(unit units.Unit, w budget.LedgerWeek) budget.LedgerWeek {
+	w.Budget = unit.FromUK(w.Budget)
+	w.CarriedOver = unit.FromUK(w.CarriedOver)
+	w.Allowance = unit.FromUK(w.Allowance)
+	w.Units = unit.FromUK(w.Units)
+	w.Balance = unit.FromUK(w.Balance)
+	return w
+}
+
+// convertCarryOver converts the carry-over cap from UK units to the provided unit.
+func convertCarryOver(unit units.Unit, c budget.CarryOver) budget.CarryOver {
+	c.Cap = unit.FromUK(c.Cap)
+	return c
+}
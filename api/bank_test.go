@@ -0,0 +1,163 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jemgunay/canlendar-graph/storage"
+
+	mock_storage "github.com/jemgunay/canlendar-graph/storage/mocks"
+)
+
+func TestAPI_Bank(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	start := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	week := func(n int) int64 {
+		return start.AddDate(0, 0, 7*n).UnixMilli()
+	}
+	weeks := []storage.Plot{{X: week(0), Y: 10}, {X: week(1), Y: 20}, {X: week(2), Y: 6}, {X: week(3), Y: 8}}
+
+	cases := []struct {
+		name     string
+		params   string
+		status   int
+		respBody string
+	}{
+		{
+			name:   "carry_over",
+			params: "&carry_over_fraction=0.5&carry_over_cap=4",
+			status: http.StatusOK,
+			respBody: `{"weeks":[` +
+				`{"start":"2022-08-01T00:00:00Z","budget":14,"carried_over":0,"allowance":14,"units":10,"balance":4},` +
+				`{"start":"2022-08-08T00:00:00Z","budget":14,"carried_over":2,"allowance":16,"units":20,"balance":-4},` +
+				`{"start":"2022-08-15T00:00:00Z","budget":14,"carried_over":0,"allowance":14,"units":6,"balance":8},` +
+				`{"start":"2022-08-22T00:00:00Z","budget":14,"carried_over":4,"allowance":18,"units":8,"balance":10}],` +
+				`"balances":[{"t":1659312000000,"y":4},{"t":1659916800000,"y":-4},{"t":1660521600000,"y":8},` +
+				`{"t":1661126400000,"y":10}],"carry_over":{"fraction":0.5,"cap":4},"guideline_profile":"uk",` +
+				`"unit":"uk_units"}`,
+		},
+		{
+			name:   "no_carry_over",
+			params: "&budget=12",
+			status: http.StatusOK,
+			respBody: `{"weeks":[` +
+				`{"start":"2022-08-01T00:00:00Z","budget":12,"carried_over":0,"allowance":12,"units":10,"balance":2},` +
+				`{"start":"2022-08-08T00:00:00Z","budget":12,"carried_over":0,"allowance":12,"units":20,"balance":-8},` +
+				`{"start":"2022-08-15T00:00:00Z","budget":12,"carried_over":0,"allowance":12,"units":6,"balance":6},` +
+				`{"start":"2022-08-22T00:00:00Z","budget":12,"carried_over":0,"allowance":12,"units":8,"balance":4}],` +
+				`"balances":[{"t":1659312000000,"y":2},{"t":1659916800000,"y":-8},{"t":1660521600000,"y":6},` +
+				`{"t":1661126400000,"y":4}],"carry_over":{"fraction":0,"cap":0},"guideline_profile":"uk",` +
+				`"unit":"uk_units"}`,
+		},
+		{
+			name:   "invalid_fraction",
+			params: "&carry_over_fraction=1.5",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "negative_cap",
+			params: "&carry_over_cap=-1",
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorer := mock_storage.NewMockStorer(ctrl)
+			if tt.status == http.StatusOK {
+				mockStorer.EXPECT().Query(gomock.Any(), gomock.Any()).Return(weeks, nil)
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/?start_time=2022-08-03T00:00:00Z"+tt.params, nil)
+
+			api := New(mockStorer, nil)
+			api.now = func() time.Time { return time.Date(2022, 8, 24, 12, 0, 0, 0, time.UTC) }
+			api.Bank(w, r)
+
+			// validate status
+			status := w.Result().StatusCode
+			if status != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			respBody := strings.TrimSpace(w.Body.String())
+			if respBody != tt.respBody {
+				t.Fatalf("expected '%s', got '%s'", tt.respBody, respBody)
+			}
+		})
+	}
+}
+
+func TestAPI_BankBalance(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	start := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+
+	mockStorer := mock_storage.NewMockStorer(ctrl)
+	mockStorer.EXPECT().ReadFirstTimestamp(gomock.Any(), gomock.Any()).Return(start.AddDate(0, 0, 2), nil)
+	mockStorer.EXPECT().Query(gomock.Any(), gomock.Any()).Return([]storage.Plot{
+		{X: start.UnixMilli(), Y: 4},
+		{X: start.AddDate(0, 0, 7).UnixMilli(), Y: 3},
+	}, nil)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/?carry_over_fraction=0.5&carry_over_cap=28&unit=grams", nil)
+
+	api := New(mockStorer, nil)
+	api.now = func() time.Time { return time.Date(2022, 8, 10, 12, 0, 0, 0, time.UTC) }
+	api.BankBalance(w, r)
+
+	// validate status
+	status := w.Result().StatusCode
+	if status != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, status)
+	}
+
+	// 10 units unused in the first week, where half would be carried over but is capped at 28g (3.5 units)
+	expected := `{"start":"2022-08-08T00:00:00Z","budget":112,"carried_over":28,"allowance":140,"units":24,` +
+		`"balance":116,"carry_over":{"fraction":0.5,"cap":28},"guideline_profile":"uk","unit":"grams"}`
+	respBody := strings.TrimSpace(w.Body.String())
+	if respBody != expected {
+		t.Fatalf("expected '%s', got '%s'", expected, respBody)
+	}
+}
+
+func TestAPI_BankBalance_NoRecords(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// without any records, the ledger only covers the current week
+	mockStorer := mock_storage.NewMockStorer(ctrl)
+	mockStorer.EXPECT().ReadFirstTimestamp(gomock.Any(), gomock.Any()).Return(time.Time{}, storage.ErrNoResults)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	api := New(mockStorer, nil)
+	api.now = func() time.Time { return time.Date(2022, 8, 10, 12, 0, 0, 0, time.UTC) }
+	api.BankBalance(w, r)
+
+	// validate status
+	status := w.Result().StatusCode
+	if status != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, status)
+	}
+
+	expected := `{"start":"2022-08-08T00:00:00Z","budget":14,"carried_over":0,"allowance":14,"units":0,` +
+		`"balance":14,"carry_over":{"fraction":0,"cap":0},"guideline_profile":"uk","unit":"uk_units"}`
+	respBody := strings.TrimSpace(w.Body.String())
+	if respBody != expected {
+		t.Fatalf("expected '%s', got '%s'", expected, respBody)
+	}
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/budget"
+	"github.com/jemgunay/canlendar-graph/storage"
+	"github.com/jemgunay/canlendar-graph/units"
+)
+
+// projectionHistoryWeeks is the number of weeks of history used to calculate weekday weighted projections.
+const projectionHistoryWeeks = 12
+
+type budgetResponse struct {
+	Period           storage.Aggregation `json:"period"`
+	Start            time.Time           `json:"start"`
+	End              time.Time           `json:"end"`
+	Budget           float64             `json:"budget"`
+	Consumed         float64             `json:"consumed"`
+	Remaining        float64             `json:"remaining"`
+	Projected        float64             `json:"projected"`
+	Projection       budget.Projection   `json:"projection"`
+	GuidelineProfile string              `json:"guideline_profile"`
+	Unit             units.Unit          `json:"unit"`
+}
+
+// Budget returns the units consumed so far in the current week or month, the units remaining within the budget and a
+// projection of the period-end total. The budget defaults to the configured budget or the guideline limit, but can be
+// overridden with the budget parameter (in the output unit).
+func (a *API) Budget(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	now := a.now().UTC()
+
+	query := r.URL.Query()
+	period := storage.Aggregation(query.Get("period"))
+	if period == "" {
+		period = storage.Week
+	}
+	if period != storage.Week && period != storage.Month {
+		log.Printf("unsupported budget period: %s", period)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	projection := a.budgets.Projection()
+	if p := query.Get("projection"); p != "" {
+		projection = budget.Projection(p)
+	}
+	if !projection.IsValid() {
+		log.Printf("unsupported projection: %s", projection)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	outputUnit, ok := parseUnit(query)
+	if !ok {
+		log.Printf("unsupported output unit: %s", outputUnit)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	profile, ok := a.profile(ctx, query)
+	if !ok {
+		log.Printf("unsupported guideline profile: %s", query.Get("guideline"))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp := budgetResponse{
+		Period:           period,
+		Start:            period.PeriodStart(now),
+		End:              period.PeriodEnd(now),
+		Projection:       projection,
+		GuidelineProfile: profile.Name,
+		Unit:             outputUnit,
+	}
+
+	resp.Budget = a.budgets.Budget(period, profile, resp.Start)
+	if b := query.Get("budget"); b != "" {
+		override, err := strconv.ParseFloat(b, 64)
+		if err != nil || override < 0 {
+			log.Printf("invalid budget provided: %s", b)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		resp.Budget = outputUnit.ToUK(override)
+	}
+
+	var err error
+	resp.Consumed, err = a.queryTotal(ctx,
+		storage.WithAggregation(period),
+		storage.WithStartTime(resp.Start),
+		storage.WithEndTime(now),
+	)
+	if err != nil {
+		log.Printf("failed to query storage: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	resp.Remaining = resp.Budget - resp.Consumed
+
+	switch projection {
+	case budget.Linear:
+		resp.Projected = budget.ProjectLinear(resp.Consumed, resp.Start, resp.End, now)
+	case budget.WeekdayWeighted:
+		history, err := a.queryPlots(ctx,
+			storage.WithAggregation(storage.Day),
+			storage.WithStartTime(resp.Start.AddDate(0, 0, -7*projectionHistoryWeeks)),
+			storage.WithEndTime(resp.Start),
+		)
+		if err != nil {
+			log.Printf("failed to query projection history from storage: %s", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		resp.Projected = budget.ProjectWeekdayWeighted(resp.Consumed, history, resp.End, now)
+	}
+
+	// convert from UK units to the output unit
+	resp.Budget = outputUnit.FromUK(resp.Budget)
+	resp.Consumed = outputUnit.FromUK(resp.Consumed)
+	resp.Remaining = outputUnit.FromUK(resp.Remaining)
+	resp.Projected = outputUnit.FromUK(resp.Projected)
+
+	writeJSON(w, resp)
+}
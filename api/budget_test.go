@@ -0,0 +1,107 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jemgunay/canlendar-graph/storage"
+
+	mock_storage "github.com/jemgunay/canlendar-graph/storage/mocks"
+)
+
+func TestAPI_Budget(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Wednesday midday
+	now := time.Date(2022, 8, 3, 12, 0, 0, 0, time.UTC)
+	weekStart := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+
+	plots := map[storage.Aggregation][]storage.Plot{
+		storage.Week: {
+			{X: weekStart.UnixMilli(), Y: 5},
+		},
+		storage.Day: {
+			// previous Thursday and Friday
+			{X: weekStart.AddDate(0, 0, -4).UnixMilli(), Y: 4},
+			{X: weekStart.AddDate(0, 0, -3).UnixMilli(), Y: 6},
+		},
+	}
+
+	cases := []struct {
+		name     string
+		params   string
+		status   int
+		respBody string
+	}{
+		{
+			name:   "week_linear",
+			status: http.StatusOK,
+			respBody: `{"period":"week","start":"2022-08-01T00:00:00Z","end":"2022-08-08T00:00:00Z","budget":14,` +
+				`"consumed":5,"remaining":9,"projected":14,"projection":"linear","guideline_profile":"uk","unit":"uk_units"}`,
+		},
+		{
+			name:   "week_weekday_weighted",
+			params: "?projection=weekday",
+			status: http.StatusOK,
+			respBody: `{"period":"week","start":"2022-08-01T00:00:00Z","end":"2022-08-08T00:00:00Z","budget":14,` +
+				`"consumed":5,"remaining":9,"projected":15,"projection":"weekday","guideline_profile":"uk","unit":"uk_units"}`,
+		},
+		{
+			name:   "week_budget_override",
+			params: "?budget=80&unit=grams",
+			status: http.StatusOK,
+			respBody: `{"period":"week","start":"2022-08-01T00:00:00Z","end":"2022-08-08T00:00:00Z","budget":80,` +
+				`"consumed":40,"remaining":40,"projected":112,"projection":"linear","guideline_profile":"uk","unit":"grams"}`,
+		},
+		{
+			name:   "unsupported_period",
+			params: "?period=day",
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorer := mock_storage.NewMockStorer(ctrl)
+			mockStorer.EXPECT().Query(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(ctx context.Context, opts ...storage.QueryOption) ([]storage.Plot, error) {
+					q, err := storage.NewQuery(opts...)
+					if err != nil {
+						return nil, err
+					}
+					return plots[q.Aggregation], nil
+				},
+			).AnyTimes()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/"+tt.params, nil)
+
+			api := New(mockStorer, nil)
+			api.now = func() time.Time { return now }
+			api.Budget(w, r)
+
+			// validate status
+			status := w.Result().StatusCode
+			if status != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, status)
+			}
+
+			respBody, err := io.ReadAll(w.Result().Body)
+			if err != nil {
+				t.Fatalf("failed to read resp body: %s", err)
+			}
+
+			respBody = bytes.TrimSpace(respBody)
+			if string(respBody) != tt.respBody {
+				t.Fatalf("expected '%s', got '%s'", tt.respBody, respBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,690 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jemgunay/canlendar-graph/calendar"
+	"github.com/jemgunay/canlendar-graph/collect"
+	"github.com/jemgunay/canlendar-graph/impute"
+	"github.com/jemgunay/canlendar-graph/storage"
+	"github.com/jemgunay/canlendar-graph/tenant"
+)
+
+type collectPayload struct {
+	StartTime time.Time `json:"start_time_override"`
+	EndTime   time.Time `json:"end_time"`
+	DryRun    bool      `json:"dry_run"`
+	Replace   bool      `json:"replace"`
+}
+
+// CollectOptions defines the range of calendar events collected. Collection resumes from each user's watermark if no
+// start time is provided, and continues up to the present and beyond if no end time is provided. If Replace is set,
+// the stored records within the range are deleted before the collected records are written, such that events removed
+// from the calendar are also removed from storage. Replacing requires both a start and end time. Every user is
+// collected unless the IDs of Users are provided.
+type CollectOptions struct {
+	StartTime time.Time
+	EndTime   time.Time
+	Replace   bool
+	Users     []string
+}
+
+// users returns the users to collect.
+func (o CollectOptions) users(directory *tenant.Directory) []tenant.User {
+	if len(o.Users) == 0 {
+		return directory.List()
+	}
+
+	var users []tenant.User
+	for _, user := range directory.List() {
+		for _, id := range o.Users {
+			if user.ID == id {
+				users = append(users, user)
+				break
+			}
+		}
+	}
+	return users
+}
+
+// validate validates the collect range.
+func (o CollectOptions) validate() error {
+	switch {
+	case !o.EndTime.IsZero() && !o.EndTime.After(o.StartTime):
+		return errors.New("end time must be after the start time")
+	case o.Replace && (o.StartTime.IsZero() || o.EndTime.IsZero()):
+		return errors.New("replacing records requires a start and end time")
+	}
+	return nil
+}
+
+// collectDryRun describes the records a collect job would write for a user, and the number of stored records which it
+// would replace.
+type collectDryRun struct {
+	User          string            `json:"user,omitempty"`
+	RangeStart    time.Time         `json:"range_start"`
+	RangeEnd      time.Time         `json:"range_end"`
+	EventsFetched int               `json:"events_fetched"`
+	Records       []collectedRecord `json:"records"`
+	Replaced      int               `json:"replaced,omitempty"`
+	Failures      []string          `json:"failures,omitempty"`
+}
+
+type collectedRecord struct {
+	Time   time.Time              `json:"time"`
+	Tags   map[string]string      `json:"tags"`
+	Fields map[string]interface{} `json:"fields"`
+}
+
+// Collect starts a job which scrapes the Google calendar API of every user for new events (i.e. those created since the
+// user's last scraped event) and writes them to storage. The job runs in the background, and its progress can be polled
+// with GetCollectJob. Only one job runs at a time, across all instances if a locker is configured. A dry run instead
+// returns the records which would be written, without writing them.
+func (a *API) Collect(w http.ResponseWriter, r *http.Request) {
+	// get start time override and collect options from body
+	payload := collectPayload{}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		log.Printf("unable to decode request body: %s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	opts := CollectOptions{
+		StartTime: payload.StartTime,
+		EndTime:   payload.EndTime,
+		Replace:   payload.Replace,
+	}
+	if err := opts.validate(); err != nil {
+		log.Printf("invalid collect range provided: %s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if payload.DryRun {
+		writeJSON(w, a.dryRunCollect(r.Context(), opts))
+		return
+	}
+
+	job, err := a.StartCollect(r.Context(), opts)
+	if err != nil {
+		log.Printf("failed to start collect job: %s", err)
+		switch {
+		case errors.Is(err, collect.ErrRunning), errors.Is(err, storage.ErrLocked):
+			w.WriteHeader(http.StatusConflict)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/collect/"+job.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	writeJSON(w, job)
+}
+
+// StartCollect starts a collect job in the background, returning the running job. An error wrapping collect.ErrRunning
+// or storage.ErrLocked is returned if a job is already running.
+func (a *API) StartCollect(ctx context.Context, opts CollectOptions) (collect.Job, error) {
+	run, err := a.jobs.Start()
+	if err != nil {
+		return collect.Job{}, err
+	}
+
+	if a.locker != nil {
+		if err := a.locker.Lock(ctx, collect.LockName, run.ID(), a.lockTTL); err != nil {
+			a.jobs.Discard(run)
+			return collect.Job{}, fmt.Errorf("failed to acquire collect lock: %w", err)
+		}
+	}
+
+	job, err := a.jobs.Get(ctx, run.ID())
+	if err != nil {
+		return collect.Job{}, err
+	}
+	a.saveJob(ctx, run)
+
+	// the job outlives the caller, so it must not use the caller's context
+	a.collecting.Add(1)
+	go func() {
+		defer a.collecting.Done()
+		a.runCollect(context.Background(), run, opts)
+	}()
+	return job, nil
+}
+
+// GetCollectJob gets the status and progress of a collect job.
+func (a *API) GetCollectJob(w http.ResponseWriter, r *http.Request) {
+	job, err := a.jobs.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("failed to get collect job: %s", err)
+		if errors.Is(err, collect.ErrNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, job)
+}
+
+// runCollect collects the calendar events of the users selected by the options. Collection continues for the remaining
+// users if it fails for one. The collect lock is refreshed on a ticker for the whole job, which is stopped if the lock
+// is lost, and released once the job has finished. Watch channels due for renewal are then renewed, as the watch
+// manager's renewal timer does not fire while the service is scaled to zero.
+func (a *API) runCollect(ctx context.Context, run *collect.Run, opts CollectOptions) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	refreshed := make(chan struct{})
+	if a.locker != nil {
+		go func() {
+			defer close(refreshed)
+			a.refreshLock(jobCtx, cancel, run)
+		}()
+	} else {
+		close(refreshed)
+	}
+
+	var failed bool
+	for _, user := range opts.users(a.users) {
+		if jobCtx.Err() != nil {
+			failed = true
+			break
+		}
+
+		if err := a.collectUser(tenant.NewContext(jobCtx, user), run, user, opts); err != nil {
+			log.Printf("failed to collect events for user '%s': %s", user.ID, err)
+			if user.ID != "" {
+				err = fmt.Errorf("user %s: %w", user.ID, err)
+			}
+			run.AddError(err)
+			failed = true
+		}
+		a.saveJob(jobCtx, run)
+	}
+
+	// stop refreshing before releasing the lock, such that a late refresh cannot reacquire it
+	cancel()
+	<-refreshed
+	if a.locker != nil {
+		if err := a.locker.Unlock(ctx, collect.LockName, run.ID()); err != nil {
+			log.Printf("failed to release collect lock: %s", err)
+		}
+	}
+	run.Finish(failed)
+	a.saveJob(ctx, run)
+	log.Printf("collect job %s finished", run.ID())
+
+	if a.watches != nil {
+		if _, err := a.watches.Register(ctx); err != nil {
+			log.Printf("failed to renew watch channels: %s", err)
+		}
+	}
+}
+
+// saveJob saves the job's progress such that it can be polled from other instances. Failures are only logged, as the
+// job can still be polled from this instance.
+func (a *API) saveJob(ctx context.Context, run *collect.Run) {
+	if err := run.Save(ctx); err != nil {
+		log.Printf("failed to save collect job %s: %s", run.ID(), err)
+	}
+}
+
+// refreshLock refreshes the collect lock every third of its TTL until the context is done. The job is cancelled if the
+// lock cannot be refreshed, as another instance may then acquire it.
+func (a *API) refreshLock(ctx context.Context, cancel context.CancelFunc, run *collect.Run) {
+	ticker := time.NewTicker(a.lockTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := a.locker.Lock(ctx, collect.LockName, run.ID(), a.lockTTL); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("failed to refresh collect lock: %s", err)
+			run.AddError(fmt.Errorf("failed to refresh collect lock: %w", err))
+			cancel()
+			return
+		}
+	}
+}
+
+// dryRunCollect collects the calendar events of every user without writing them to storage, returning the records
+// which would be written. Failures are reported per user rather than failing the dry run.
+func (a *API) dryRunCollect(ctx context.Context, opts CollectOptions) []collectDryRun {
+	users := opts.users(a.users)
+	dryRuns := make([]collectDryRun, 0, len(users))
+	for _, user := range users {
+		dryRun := collectDryRun{
+			User:    user.ID,
+			Records: []collectedRecord{},
+		}
+		if err := a.dryRunUser(tenant.NewContext(ctx, user), user, opts, &dryRun); err != nil {
+			log.Printf("failed to dry run collect for user '%s': %s", user.ID, err)
+			dryRun.Failures = append(dryRun.Failures, err.Error())
+		}
+		dryRuns = append(dryRuns, dryRun)
+	}
+	return dryRuns
+}
+
+// dryRunUser collects the user's calendar events within the range without writing them to storage, describing the
+// records which would be written, and replaced if set, in the dry run.
+func (a *API) dryRunUser(ctx context.Context, user tenant.User, opts CollectOptions, dryRun *collectDryRun) error {
+	start, _, err := a.collectStart(ctx, user, opts.StartTime)
+	if err != nil {
+		return err
+	}
+	dryRun.RangeStart = start.UTC()
+	dryRun.RangeEnd = a.collectEnd(opts)
+	if !dryRun.RangeStart.Before(dryRun.RangeEnd) {
+		return nil
+	}
+
+	result, err := a.fetchRecords(ctx, user, start, opts.EndTime)
+	if err != nil {
+		return err
+	}
+	dryRun.EventsFetched = result.events
+	for _, err := range result.failures {
+		dryRun.Failures = append(dryRun.Failures, err.Error())
+	}
+	dryRun.Records = toCollectedRecords(result.records)
+
+	if opts.Replace {
+		existing, err := a.storer.QueryRecords(ctx,
+			storage.WithStartTime(dryRun.RangeStart),
+			storage.WithEndTime(dryRun.RangeEnd),
+		)
+		if err != nil && !errors.Is(err, storage.ErrNoResults) {
+			return fmt.Errorf("failed to query replaced records: %w", err)
+		}
+		dryRun.Replaced = len(existing)
+	}
+	return nil
+}
+
+// collectUser collects the user's calendar events and writes them to storage, reporting progress to the run. The run
+// is recorded in the collect history if a run store is configured.
+func (a *API) collectUser(ctx context.Context, run *collect.Run, user tenant.User, opts CollectOptions) error {
+	startedAt := a.now().UTC()
+	history, err := a.syncUser(ctx, run, user, opts)
+	history.JobID = run.ID()
+	history.User = user.ID
+	history.StartedAt = startedAt
+	history.FinishedAt = a.now().UTC()
+	history.Succeeded = err == nil
+	if err != nil {
+		history.Failures = append(history.Failures, err.Error())
+	}
+
+	if a.runs != nil {
+		if err := a.runs.StoreRun(ctx, history); err != nil {
+			log.Printf("failed to store collect run: %s", err)
+			run.AddError(fmt.Errorf("failed to store collect run: %w", err))
+		}
+	}
+	return err
+}
+
+// syncUser collects the user's calendar events within the range and writes them to storage, returning the history of
+// the collected range. The user's watermark is advanced to the end of the collected range once every event within the
+// range has been collected.
+func (a *API) syncUser(ctx context.Context, run *collect.Run, user tenant.User,
+	opts CollectOptions) (storage.Run, error) {
+
+	var history storage.Run
+	start, watermark, err := a.collectStart(ctx, user, opts.StartTime)
+	if err != nil {
+		return history, err
+	}
+	history.RangeStart = start.UTC()
+	history.RangeEnd = a.collectEnd(opts)
+	if !history.RangeStart.Before(history.RangeEnd) {
+		log.Printf("no range to collect for user '%s' between %s and %s", user.ID,
+			history.RangeStart.Format(time.RFC3339), history.RangeEnd.Format(time.RFC3339))
+		return history, nil
+	}
+
+	result, err := a.fetchRecords(ctx, user, start, opts.EndTime)
+	if err != nil {
+		return history, err
+	}
+	run.AddPages(result.pages)
+	history.EventsFetched = result.events
+	for _, err := range result.failures {
+		run.AddError(err)
+		history.Failures = append(history.Failures, err.Error())
+	}
+	run.AddParsed(len(result.records))
+
+	// delete the stored records within the range, such that events removed from the calendar are removed from storage
+	if opts.Replace {
+		err := a.storer.Delete(ctx,
+			storage.WithStartTime(history.RangeStart),
+			storage.WithEndTime(history.RangeEnd),
+		)
+		if err != nil {
+			return history, fmt.Errorf("failed to delete replaced records from storage: %w", err)
+		}
+	}
+
+	// skip records which are already stored, such that they remain attributed to the run which wrote them
+	records, err := a.changedRecords(ctx, result.records)
+	if err != nil {
+		return history, fmt.Errorf("failed to replace changed records in storage: %w", err)
+	}
+
+	// persist new events to storage, tagged with the run which wrote them
+	if len(records) > 0 {
+		for i := range records {
+			records[i].Tags[storage.RunTag] = run.ID()
+		}
+		if err := a.storer.Store(ctx, records...); err != nil {
+			return history, fmt.Errorf("failed to persist events to storage: %w", err)
+		}
+		run.AddWritten(len(records))
+		history.RecordsWritten = len(records)
+		history.FirstRecord, history.LastRecord = recordSpan(records)
+	}
+
+	return history, a.advanceWatermark(ctx, user, watermark, history)
+}
+
+// changedRecords compares the records against the stored records with the same times and events, returning the
+// records which are not already stored. Stored records which have changed are deleted, as records written by different
+// runs are stored separately rather than overwriting each other. Stored records without an event, i.e. written before
+// events were tagged, are replaced along with every other record at their time, as they cannot be matched to an event.
+func (a *API) changedRecords(ctx context.Context, records []storage.Record) ([]storage.Record, error) {
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	first, last := recordSpan(records)
+	stored, err := a.storer.QueryRecords(ctx,
+		storage.WithStartTime(first),
+		storage.WithEndTime(last.Add(time.Nanosecond)),
+	)
+	if err != nil && !errors.Is(err, storage.ErrNoResults) {
+		return nil, fmt.Errorf("failed to query stored records: %w", err)
+	}
+
+	storedByKey := make(map[storage.RecordKey][]storage.Record, len(stored))
+	for _, record := range stored {
+		storedByKey[record.Key()] = append(storedByKey[record.Key()], record)
+	}
+
+	// replace every record at the times of untagged records
+	replaced := make(map[int64]bool)
+	for _, record := range records {
+		t := record.Time.UnixNano()
+		if replaced[t] || len(storedByKey[storage.RecordKey{Time: t}]) == 0 {
+			continue
+		}
+		err := a.storer.Delete(ctx,
+			storage.WithStartTime(record.Time),
+			storage.WithEndTime(record.Time.Add(time.Nanosecond)),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete untagged records: %w", err)
+		}
+		replaced[t] = true
+	}
+
+	changed := make([]storage.Record, 0, len(records))
+	for _, record := range records {
+		if replaced[record.Time.UnixNano()] {
+			changed = append(changed, record)
+			continue
+		}
+
+		existing := storedByKey[record.Key()]
+		if len(existing) == 1 && existing[0].SameValues(record) {
+			continue
+		}
+
+		if len(existing) > 0 {
+			err := a.storer.Delete(ctx,
+				storage.WithStartTime(record.Time),
+				storage.WithEndTime(record.Time.Add(time.Nanosecond)),
+				storage.WithEvent(record.Tags[storage.EventTag]),
+			)
+			if err != nil {
+				return nil, fmt.Errorf("failed to delete changed record: %w", err)
+			}
+		}
+		changed = append(changed, record)
+	}
+	return changed, nil
+}
+
+// recordSpan returns the times of the first and last records.
+func recordSpan(records []storage.Record) (time.Time, time.Time) {
+	var first, last time.Time
+	for _, record := range records {
+		if first.IsZero() || record.Time.Before(first) {
+			first = record.Time
+		}
+		if record.Time.After(last) {
+			last = record.Time
+		}
+	}
+	return first.UTC(), last.UTC()
+}
+
+// fetched is the result of fetching and parsing a user's calendar events.
+type fetched struct {
+	pages    int
+	events   int
+	records  []storage.Record
+	failures []error
+}
+
+// fetchRecords fetches the user's calendar events between the start and end times, and parses them into records.
+// Events which cannot be read are reported as failures rather than failing the fetch.
+func (a *API) fetchRecords(ctx context.Context, user tenant.User, start, end time.Time) (fetched, error) {
+	// calendar fetchers are created on first use
+	fetcher, err := a.calendars.Fetcher(user.CalendarID, user.Timezone)
+	if err != nil {
+		return fetched{}, fmt.Errorf("failed to create calendar fetcher: %w", err)
+	}
+
+	// fetch calendar events for time range
+	eventIter, err := fetcher.Fetch(ctx, start, end)
+	if err != nil {
+		if err == calendar.ErrNoEventsFound {
+			log.Printf("no new events found for user '%s' since %s", user.ID, start.Format(time.RFC3339))
+			return fetched{}, nil
+		}
+		return fetched{}, fmt.Errorf("failed to fetch calendar events: %w", err)
+	}
+	result := fetched{
+		pages:  eventIter.Pages(),
+		events: eventIter.Count(),
+	}
+
+	// read all calendar events
+	events := make([]calendar.Event, 0, result.events)
+	for {
+		ev, err := eventIter.Next()
+		if err != nil {
+			if errors.Is(err, calendar.ErrNoMoreEvents) {
+				break
+			}
+			log.Printf("failed to read event: %s", err)
+			result.failures = append(result.failures, fmt.Errorf("failed to read event: %w", err))
+			continue
+		}
+		events = append(events, ev)
+	}
+
+	// impute units for events logged as unknown
+	if err := a.imputeUnits(ctx, start, events); err != nil {
+		return fetched{}, fmt.Errorf("failed to impute unknown units: %w", err)
+	}
+
+	// process calendar events into records
+	result.records = make([]storage.Record, 0, len(events))
+	for _, ev := range events {
+		_, offset := ev.Date.Zone()
+		result.records = append(result.records, storage.Record{
+			Time: ev.Date,
+			Tags: map[string]string{
+				storage.ImputedTag: strconv.FormatBool(ev.Unknown),
+				storage.EventTag:   ev.ID,
+			},
+			Fields: map[string]interface{}{
+				storage.UnitsField:     ev.Units,
+				storage.TimedField:     ev.Timed,
+				storage.UTCOffsetField: offset,
+			},
+		})
+	}
+	return result, nil
+}
+
+// collectStart resolves the start of the user's collect range, returning it along with the user's current watermark.
+// If no start time is provided, collection resumes from the watermark. If there is no watermark, i.e. events were
+// collected before watermarks were introduced, collection resumes from the day after the last written storage record,
+// or from the start of time if there are no records.
+func (a *API) collectStart(ctx context.Context, user tenant.User, start time.Time) (time.Time, time.Time, error) {
+	watermark, err := a.readWatermark(ctx, user)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("failed to read collection watermark: %w", err)
+	}
+
+	if start.IsZero() {
+		start = watermark
+	}
+	if start.IsZero() {
+		if start, err = a.getLastTimestamp(ctx); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("failed to read last written timestamp from storage: %w", err)
+		}
+
+		// add 24h to ensure we don't recollect the last event
+		start = start.Add(time.Hour * 24)
+	}
+	return start, watermark, nil
+}
+
+// collectEnd resolves the end of the collect range, which is the present if no end time is provided or the end time is
+// in the future. Events up to a future end time are yet to be logged, so the range, and therefore the watermark, must
+// not extend past the present.
+func (a *API) collectEnd(opts CollectOptions) time.Time {
+	now := a.now().UTC()
+	if opts.EndTime.IsZero() || opts.EndTime.After(now) {
+		return now
+	}
+	return opts.EndTime.UTC()
+}
+
+// readWatermark reads the user's collection watermark. The zero time is returned if no run store is configured or the
+// user has no watermark.
+func (a *API) readWatermark(ctx context.Context, user tenant.User) (time.Time, error) {
+	if a.runs == nil {
+		return time.Time{}, nil
+	}
+
+	watermark, err := a.runs.ReadWatermark(ctx, user.ID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNoResults) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	return watermark, nil
+}
+
+// advanceWatermark advances the user's watermark to the end of the collected range. The watermark is left unchanged if
+// the range started after the current watermark, as the events between them have not been collected, or if the range
+// ended before it.
+func (a *API) advanceWatermark(ctx context.Context, user tenant.User, watermark time.Time, history storage.Run) error {
+	if a.runs == nil {
+		return nil
+	}
+	if !watermark.IsZero() && (history.RangeStart.After(watermark) || !history.RangeEnd.After(watermark)) {
+		return nil
+	}
+
+	if err := a.runs.StoreWatermark(ctx, user.ID, history.RangeEnd); err != nil {
+		return fmt.Errorf("failed to store collection watermark: %w", err)
+	}
+	return nil
+}
+
+// getLastTimestamp reads the last written unit timestamp from storage. If there were no results in storage, then the
+// value representing the start of time is returned.
+func (a *API) getLastTimestamp(ctx context.Context) (time.Time, error) {
+	startTime, err := a.storer.ReadLastTimestamp(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrNoResults) {
+			// default to the start of time
+			return time.Time{}, nil
+		}
+
+		return time.Time{}, err
+	}
+
+	return startTime, nil
+}
+
+// imputeUnits sets the units for each event logged as unknown using the configured imputer. The imputer's history is
+// built from observed records in storage preceding the fetched range, in addition to the observed events fetched from
+// the start of the range. Stored records within the range are excluded, as they are re-collected from the events.
+func (a *API) imputeUnits(ctx context.Context, start time.Time, events []calendar.Event) error {
+	var first time.Time
+	for _, ev := range events {
+		if ev.Unknown && (first.IsZero() || ev.Date.Before(first)) {
+			first = ev.Date
+		}
+	}
+
+	// no unknown events to impute
+	if first.IsZero() {
+		return nil
+	}
+
+	history := impute.History{}
+	historyStart := first.AddDate(0, 0, -a.imputer.LookbackDays())
+	if a.imputer.RequiresHistory() && historyStart.Before(start) {
+		plots, err := a.storer.Query(ctx,
+			storage.WithAggregation(storage.Day),
+			storage.WithStartTime(historyStart),
+			storage.WithEndTime(start),
+			storage.WithImputed(false),
+		)
+		if err != nil && !errors.Is(err, storage.ErrNoResults) {
+			return err
+		}
+		history = impute.NewHistory(plots)
+	}
+
+	// events which start before the range are already included in the stored history
+	for _, ev := range events {
+		if !ev.Unknown && !ev.Date.Before(start) {
+			history.Add(ev.Date, ev.Units)
+		}
+	}
+
+	for i := range events {
+		if events[i].Unknown {
+			events[i].Units = a.imputer.Impute(events[i].Date, history)
+		}
+	}
+
+	return nil
+}
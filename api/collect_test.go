@@ -0,0 +1,1036 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/jemgunay/canlendar-graph/calendar"
+	"github.com/jemgunay/canlendar-graph/collect"
+	"github.com/jemgunay/canlendar-graph/config"
+	"github.com/jemgunay/canlendar-graph/impute"
+	"github.com/jemgunay/canlendar-graph/storage"
+
+	mock_calendar "github.com/jemgunay/canlendar-graph/calendar/mocks"
+	mock_collect "github.com/jemgunay/canlendar-graph/collect/mocks"
+	mock_storage "github.com/jemgunay/canlendar-graph/storage/mocks"
+)
+
+func TestAPI_Collect(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Now()
+
+	// generate events for the calendar event iterator
+	mockIter := mock_calendar.NewMockEventIterator(ctrl)
+	eventCount := 100
+	mockIter.EXPECT().Count().Return(eventCount)
+	mockIter.EXPECT().Pages().Return(1)
+	var current int
+	mockIter.EXPECT().Next().DoAndReturn(
+		func() (calendar.Event, error) {
+			if current == eventCount {
+				return calendar.Event{}, calendar.ErrNoMoreEvents
+			}
+
+			ev := calendar.Event{
+				Date:  now.Add(-time.Hour * 24 * time.Duration(current)),
+				Units: float64(current % 10),
+			}
+			current++
+			return ev, nil
+		},
+	).AnyTimes()
+
+	mockFetcher := mock_calendar.NewMockFetcher(ctrl)
+	mockFetcher.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(mockIter, nil)
+	mockCalendar := mock_calendar.NewMockProvider(ctrl)
+	mockCalendar.EXPECT().Fetcher(gomock.Any(), gomock.Any()).Return(mockFetcher, nil)
+
+	mockStorer := mock_storage.NewMockStorer(ctrl)
+	mockStorer.EXPECT().ReadLastTimestamp(gomock.Any(), gomock.Any()).Return(time.Time{}, storage.ErrNoResults)
+	mockStorer.EXPECT().QueryRecords(gomock.Any(), gomock.Any()).Return(nil, storage.ErrNoResults)
+	var storedCount int
+	mockStorer.EXPECT().Store(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, records ...storage.Record) error {
+		storedCount++
+		return nil
+	})
+
+	api := New(mockStorer, mockCalendar)
+	job := collectAndWait(t, api, `{}`)
+
+	if job.Status != collect.Succeeded {
+		t.Fatalf("expected %s, got %s", collect.Succeeded, job.Status)
+	}
+	expectedProgress := collect.Progress{PagesFetched: 1, RecordsParsed: eventCount, RecordsWritten: eventCount}
+	if !reflect.DeepEqual(job.Progress, expectedProgress) {
+		t.Fatalf("expected %+v, got %+v", expectedProgress, job.Progress)
+	}
+
+	expectedStoredCount := 1
+	if storedCount != expectedStoredCount {
+		t.Fatalf("expected %d, got %d", expectedStoredCount, storedCount)
+	}
+}
+
+func TestAPI_Collect_Impute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	day := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	events := []calendar.Event{
+		{Date: day, Units: 4},
+		{Date: day.AddDate(0, 0, 1), Units: 8},
+		{Date: day.AddDate(0, 0, 2), Unknown: true},
+	}
+
+	cases := []struct {
+		name     string
+		conf     config.Impute
+		history  []storage.Plot
+		expected float64
+	}{
+		{
+			name:     "fixed",
+			conf:     config.Impute{Strategy: "fixed", FixedUnits: 14},
+			expected: 14,
+		},
+		{
+			name:     "exclude",
+			conf:     config.Impute{Strategy: "exclude", FixedUnits: 14},
+			expected: 0,
+		},
+		{
+			name: "median",
+			conf: config.Impute{Strategy: "median", FixedUnits: 14, LookbackDays: 90},
+			history: []storage.Plot{
+				{X: day.AddDate(0, 0, -3).UnixMilli(), Y: 2},
+				{X: day.AddDate(0, 0, -2).UnixMilli(), Y: 0},
+				{X: day.AddDate(0, 0, -1).UnixMilli(), Y: 6},
+			},
+			expected: 5,
+		},
+		{
+			// the stored records of the re-collected range are replaced by the fetched events rather than added to them
+			name: "median_recollect",
+			conf: config.Impute{Strategy: "median", FixedUnits: 14, LookbackDays: 90},
+			history: []storage.Plot{
+				{X: day.AddDate(0, 0, -3).UnixMilli(), Y: 2},
+				{X: day.AddDate(0, 0, -1).UnixMilli(), Y: 6},
+				{X: day.UnixMilli(), Y: 4},
+				{X: day.AddDate(0, 0, 1).UnixMilli(), Y: 8},
+			},
+			expected: 5,
+		},
+		{
+			name: "weekday_average",
+			conf: config.Impute{Strategy: "weekday_average", FixedUnits: 14, WeekdayWindow: 2},
+			history: []storage.Plot{
+				{X: day.AddDate(0, 0, -5).UnixMilli(), Y: 3},
+				{X: day.AddDate(0, 0, -12).UnixMilli(), Y: 7},
+			},
+			expected: 5,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockIter := mock_calendar.NewMockEventIterator(ctrl)
+			mockIter.EXPECT().Count().Return(len(events))
+			mockIter.EXPECT().Pages().Return(1)
+			var current int
+			mockIter.EXPECT().Next().DoAndReturn(
+				func() (calendar.Event, error) {
+					if current == len(events) {
+						return calendar.Event{}, calendar.ErrNoMoreEvents
+					}
+					current++
+					return events[current-1], nil
+				},
+			).AnyTimes()
+
+			mockFetcher := mock_calendar.NewMockFetcher(ctrl)
+			mockFetcher.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(mockIter, nil)
+			mockCalendar := mock_calendar.NewMockProvider(ctrl)
+			mockCalendar.EXPECT().Fetcher(gomock.Any(), gomock.Any()).Return(mockFetcher, nil)
+
+			mockStorer := mock_storage.NewMockStorer(ctrl)
+			mockStorer.EXPECT().Query(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, options ...storage.QueryOption) ([]storage.Plot, error) {
+					q, err := storage.NewQuery(options...)
+					if err != nil {
+						t.Fatalf("unexpected query: %s", err)
+					}
+					var plots []storage.Plot
+					for _, plot := range tt.history {
+						if x := time.UnixMilli(plot.X); !x.Before(q.StartTime) && x.Before(q.EndTime) {
+							plots = append(plots, plot)
+						}
+					}
+					return plots, nil
+				},
+			).AnyTimes()
+			mockStorer.EXPECT().QueryRecords(gomock.Any(), gomock.Any()).Return(nil, storage.ErrNoResults)
+			var stored []storage.Record
+			mockStorer.EXPECT().Store(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, records ...storage.Record) error {
+				stored = records
+				return nil
+			})
+
+			api := New(mockStorer, mockCalendar, WithImputer(impute.New(tt.conf)))
+			job := collectAndWait(t, api, `{"start_time_override": "2022-08-01T00:00:00Z"}`)
+
+			if job.Status != collect.Succeeded {
+				t.Fatalf("expected %s, got %s", collect.Succeeded, job.Status)
+			}
+
+			if len(stored) != len(events) {
+				t.Fatalf("expected %d, got %d", len(events), len(stored))
+			}
+
+			imputed := stored[len(stored)-1]
+			if units := imputed.Fields["units"]; units != tt.expected {
+				t.Fatalf("expected %v, got %v", tt.expected, units)
+			}
+			if tag := imputed.Tags[storage.ImputedTag]; tag != "true" {
+				t.Fatalf("expected %s, got %s", "true", tag)
+			}
+			if tag := stored[0].Tags[storage.ImputedTag]; tag != "false" {
+				t.Fatalf("expected %s, got %s", "false", tag)
+			}
+		})
+	}
+}
+
+func TestAPI_Collect_Lock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cases := []struct {
+		name    string
+		lockErr error
+		status  int
+	}{
+		{
+			name:   "acquired",
+			status: http.StatusAccepted,
+		},
+		{
+			name:    "locked",
+			lockErr: storage.ErrLocked,
+			status:  http.StatusConflict,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockFetcher := mock_calendar.NewMockFetcher(ctrl)
+			mockFetcher.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, calendar.ErrNoEventsFound).AnyTimes()
+			mockCalendar := mock_calendar.NewMockProvider(ctrl)
+			mockCalendar.EXPECT().Fetcher(gomock.Any(), gomock.Any()).Return(mockFetcher, nil).AnyTimes()
+
+			mockStorer := mock_storage.NewMockStorer(ctrl)
+			mockStorer.EXPECT().ReadLastTimestamp(gomock.Any(), gomock.Any()).Return(time.Time{}, storage.ErrNoResults).AnyTimes()
+
+			// the lock is acquired by the request, and released once the job has finished
+			mockLocker := mock_storage.NewMockLocker(ctrl)
+			var owner string
+			mockLocker.EXPECT().Lock(gomock.Any(), "collect", gomock.Any(), time.Minute).DoAndReturn(
+				func(_ context.Context, _, o string, _ time.Duration) error {
+					owner = o
+					return tt.lockErr
+				},
+			)
+			if tt.lockErr == nil {
+				mockLocker.EXPECT().Unlock(gomock.Any(), "collect", gomock.Any()).Return(nil)
+			}
+
+			api := New(mockStorer, mockCalendar, WithLocker(mockLocker, time.Minute))
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{}`))
+			api.Collect(w, r)
+			api.collecting.Wait()
+
+			// validate status
+			status := w.Result().StatusCode
+			if status != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, status)
+			}
+
+			// jobs which fail to acquire the lock are discarded
+			_, err := api.jobs.Get(context.Background(), owner)
+			if held := tt.lockErr == nil; held != (err == nil) {
+				t.Fatalf("expected job to exist to be %t, got error %v", held, err)
+			}
+		})
+	}
+}
+
+func TestAPI_Collect_LockRefresh(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	const ttl = 30 * time.Millisecond
+
+	cases := []struct {
+		name       string
+		refreshErr error
+		status     collect.Status
+	}{
+		{
+			name:   "refreshed",
+			status: collect.Succeeded,
+		},
+		{
+			name:       "lost",
+			refreshErr: storage.ErrLocked,
+			status:     collect.Failed,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			// fetching takes several lock refresh intervals, unless the job is cancelled
+			mockFetcher := mock_calendar.NewMockFetcher(ctrl)
+			mockFetcher.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+				func(ctx context.Context, _, _ time.Time) (calendar.EventIterator, error) {
+					select {
+					case <-ctx.Done():
+						return nil, ctx.Err()
+					case <-time.After(5 * ttl):
+						return nil, calendar.ErrNoEventsFound
+					}
+				},
+			)
+			mockCalendar := mock_calendar.NewMockProvider(ctrl)
+			mockCalendar.EXPECT().Fetcher(gomock.Any(), gomock.Any()).Return(mockFetcher, nil)
+
+			mockStorer := mock_storage.NewMockStorer(ctrl)
+			mockStorer.EXPECT().ReadLastTimestamp(gomock.Any(), gomock.Any()).Return(time.Time{}, storage.ErrNoResults)
+
+			// the lock is acquired by the request, then refreshed on a ticker while the user's events are fetched
+			mockLocker := mock_storage.NewMockLocker(ctrl)
+			var locks int
+			mockLocker.EXPECT().Lock(gomock.Any(), "collect", gomock.Any(), ttl).DoAndReturn(
+				func(_ context.Context, _, _ string, _ time.Duration) error {
+					locks++
+					if locks > 1 {
+						return tt.refreshErr
+					}
+					return nil
+				},
+			).MinTimes(2)
+			mockLocker.EXPECT().Unlock(gomock.Any(), "collect", gomock.Any()).Return(nil)
+
+			api := New(mockStorer, mockCalendar, WithLocker(mockLocker, ttl))
+			job := collectAndWait(t, api, `{}`)
+
+			if job.Status != tt.status {
+				t.Fatalf("expected %s, got %s", tt.status, job.Status)
+			}
+			if tt.refreshErr != nil && (len(job.Progress.Errors) == 0 ||
+				job.Progress.Errors[0] != "failed to refresh collect lock: "+tt.refreshErr.Error()) {
+				t.Fatalf("expected lock refresh error, got %v", job.Progress.Errors)
+			}
+		})
+	}
+}
+
+func TestAPI_Collect_Watermark(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Date(2022, 8, 10, 12, 0, 0, 0, time.UTC)
+	watermark := time.Date(2022, 8, 9, 12, 0, 0, 0, time.UTC)
+	lastRecord := time.Date(2022, 8, 5, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name       string
+		body       string
+		watermark  time.Time
+		fetchErr   error
+		rangeStart time.Time
+		rangeEnd   time.Time
+		advances   bool
+		succeeded  bool
+	}{
+		{
+			name:       "resumes_from_watermark",
+			body:       `{}`,
+			watermark:  watermark,
+			fetchErr:   calendar.ErrNoEventsFound,
+			rangeStart: watermark,
+			advances:   true,
+			succeeded:  true,
+		},
+		{
+			name:       "no_watermark",
+			body:       `{}`,
+			fetchErr:   calendar.ErrNoEventsFound,
+			rangeStart: lastRecord.Add(24 * time.Hour),
+			advances:   true,
+			succeeded:  true,
+		},
+		{
+			name:       "override_before_watermark",
+			body:       `{"start_time_override": "2022-08-01T00:00:00Z"}`,
+			watermark:  watermark,
+			fetchErr:   calendar.ErrNoEventsFound,
+			rangeStart: time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC),
+			advances:   true,
+			succeeded:  true,
+		},
+		{
+			name:       "override_after_watermark",
+			body:       `{"start_time_override": "2022-08-10T00:00:00Z"}`,
+			watermark:  watermark,
+			fetchErr:   calendar.ErrNoEventsFound,
+			rangeStart: time.Date(2022, 8, 10, 0, 0, 0, 0, time.UTC),
+			succeeded:  true,
+		},
+		{
+			name:       "bounded_before_watermark",
+			body:       `{"start_time_override": "2022-08-01T00:00:00Z", "end_time": "2022-08-05T00:00:00Z"}`,
+			watermark:  watermark,
+			fetchErr:   calendar.ErrNoEventsFound,
+			rangeStart: time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC),
+			rangeEnd:   time.Date(2022, 8, 5, 0, 0, 0, 0, time.UTC),
+			succeeded:  true,
+		},
+		{
+			name:       "fetch_failed",
+			body:       `{}`,
+			watermark:  watermark,
+			fetchErr:   errors.New("calendar unavailable"),
+			rangeStart: watermark,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockFetcher := mock_calendar.NewMockFetcher(ctrl)
+			mockFetcher.EXPECT().Fetch(gomock.Any(), tt.rangeStart, tt.rangeEnd).Return(nil, tt.fetchErr)
+			mockCalendar := mock_calendar.NewMockProvider(ctrl)
+			mockCalendar.EXPECT().Fetcher(gomock.Any(), gomock.Any()).Return(mockFetcher, nil)
+
+			mockStorer := mock_storage.NewMockStorer(ctrl)
+			mockStorer.EXPECT().ReadLastTimestamp(gomock.Any(), gomock.Any()).Return(lastRecord, nil).AnyTimes()
+
+			mockRuns := mock_storage.NewMockRunStore(ctrl)
+			if tt.watermark.IsZero() {
+				mockRuns.EXPECT().ReadWatermark(gomock.Any(), "").Return(time.Time{}, storage.ErrNoResults)
+			} else {
+				mockRuns.EXPECT().ReadWatermark(gomock.Any(), "").Return(tt.watermark, nil)
+			}
+			if tt.advances {
+				mockRuns.EXPECT().StoreWatermark(gomock.Any(), "", now).Return(nil)
+			}
+			var history storage.Run
+			mockRuns.EXPECT().StoreRun(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, run storage.Run) error {
+				history = run
+				return nil
+			})
+
+			api := New(mockStorer, mockCalendar, WithRunStore(mockRuns))
+			api.now = func() time.Time { return now }
+			job := collectAndWait(t, api, tt.body)
+
+			// validate the recorded run
+			if history.JobID != job.ID || history.Succeeded != tt.succeeded {
+				t.Fatalf("expected run of job %s with success %t, got %+v", job.ID, tt.succeeded, history)
+			}
+			rangeEnd := tt.rangeEnd
+			if rangeEnd.IsZero() {
+				rangeEnd = now
+			}
+			if !history.RangeStart.Equal(tt.rangeStart) || !history.RangeEnd.Equal(rangeEnd) {
+				t.Fatalf("expected range %s to %s, got %s to %s", tt.rangeStart, rangeEnd, history.RangeStart,
+					history.RangeEnd)
+			}
+			if failed := len(history.Failures) > 0; failed == tt.succeeded {
+				t.Fatalf("expected failures to be recorded to be %t, got %v", !tt.succeeded, history.Failures)
+			}
+		})
+	}
+}
+
+func TestAPI_Collect_Range(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	start := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2022, 8, 8, 0, 0, 0, 0, time.UTC)
+	events := []calendar.Event{
+		{Date: start.Add(20 * time.Hour), Units: 4},
+		{Date: start.AddDate(0, 0, 3), Units: 2},
+	}
+
+	cases := []struct {
+		name    string
+		body    string
+		status  int
+		replace bool
+		dryRun  bool
+	}{
+		{
+			name:   "bounded",
+			body:   `{"start_time_override": "2022-08-01T00:00:00Z", "end_time": "2022-08-08T00:00:00Z"}`,
+			status: http.StatusAccepted,
+		},
+		{
+			name:    "replace",
+			body:    `{"start_time_override": "2022-08-01T00:00:00Z", "end_time": "2022-08-08T00:00:00Z", "replace": true}`,
+			status:  http.StatusAccepted,
+			replace: true,
+		},
+		{
+			name:    "dry_run_replace",
+			body:    `{"start_time_override": "2022-08-01T00:00:00Z", "end_time": "2022-08-08T00:00:00Z", "replace": true, "dry_run": true}`,
+			status:  http.StatusOK,
+			replace: true,
+			dryRun:  true,
+		},
+		{
+			name:   "end_before_start",
+			body:   `{"start_time_override": "2022-08-08T00:00:00Z", "end_time": "2022-08-01T00:00:00Z"}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unbounded_replace",
+			body:   `{"start_time_override": "2022-08-01T00:00:00Z", "replace": true}`,
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockIter := mock_calendar.NewMockEventIterator(ctrl)
+			mockIter.EXPECT().Count().Return(len(events)).AnyTimes()
+			mockIter.EXPECT().Pages().Return(1).AnyTimes()
+			var current int
+			mockIter.EXPECT().Next().DoAndReturn(
+				func() (calendar.Event, error) {
+					if current == len(events) {
+						return calendar.Event{}, calendar.ErrNoMoreEvents
+					}
+					current++
+					return events[current-1], nil
+				},
+			).AnyTimes()
+
+			mockFetcher := mock_calendar.NewMockFetcher(ctrl)
+			mockFetcher.EXPECT().Fetch(gomock.Any(), start, end).Return(mockIter, nil).AnyTimes()
+			mockCalendar := mock_calendar.NewMockProvider(ctrl)
+			mockCalendar.EXPECT().Fetcher(gomock.Any(), gomock.Any()).Return(mockFetcher, nil).AnyTimes()
+
+			// the stored records within the range are deleted before the records are written, and are only counted
+			// by a dry run
+			mockStorer := mock_storage.NewMockStorer(ctrl)
+			var calls []string
+			validateRange := func(options []storage.QueryOption) {
+				q, err := storage.NewRecordQuery(options...)
+				if err != nil || !q.StartTime.Equal(start) || !q.EndTime.Equal(end) {
+					t.Fatalf("expected range %s to %s, got %+v (%v)", start, end, q, err)
+				}
+			}
+			mockStorer.EXPECT().Delete(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, options ...storage.QueryOption) error {
+					validateRange(options)
+					calls = append(calls, "delete")
+					return nil
+				},
+			).AnyTimes()
+			mockStorer.EXPECT().Store(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, records ...storage.Record) error {
+					calls = append(calls, "store")
+					return nil
+				},
+			).AnyTimes()
+			mockStorer.EXPECT().QueryRecords(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, options ...storage.QueryOption) ([]storage.Record, error) {
+					// records are otherwise queried to skip those which are already stored
+					if !tt.dryRun {
+						return nil, storage.ErrNoResults
+					}
+					validateRange(options)
+					calls = append(calls, "query")
+					return make([]storage.Record, 3), nil
+				},
+			).AnyTimes()
+
+			api := New(mockStorer, mockCalendar)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(tt.body))
+			api.Collect(w, r)
+			api.collecting.Wait()
+
+			// validate status
+			status := w.Result().StatusCode
+			if status != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, status)
+			}
+
+			var expectedCalls []string
+			switch {
+			case tt.dryRun:
+				expectedCalls = []string{"query"}
+			case tt.replace:
+				expectedCalls = []string{"delete", "store"}
+			case status == http.StatusAccepted:
+				expectedCalls = []string{"store"}
+			}
+			if !reflect.DeepEqual(calls, expectedCalls) {
+				t.Fatalf("expected %v, got %v", expectedCalls, calls)
+			}
+			if !tt.dryRun {
+				return
+			}
+
+			var dryRuns []collectDryRun
+			if err := json.NewDecoder(w.Body).Decode(&dryRuns); err != nil {
+				t.Fatalf("failed to decode response: %s", err)
+			}
+			if len(dryRuns) != 1 {
+				t.Fatalf("expected %d, got %d", 1, len(dryRuns))
+			}
+			dryRun := dryRuns[0]
+			if len(dryRun.Records) != len(events) || dryRun.Replaced != 3 || dryRun.EventsFetched != len(events) {
+				t.Fatalf("expected %d records replacing %d, got %+v", len(events), 3, dryRun)
+			}
+			if !dryRun.Records[0].Time.Equal(events[0].Date) || dryRun.Records[0].Fields[storage.UnitsField] != 4.0 {
+				t.Fatalf("unexpected record: %+v", dryRun.Records[0])
+			}
+		})
+	}
+}
+
+func TestAPI_Collect_Changed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	day := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	events := []calendar.Event{
+		{ID: "first", Date: day, Units: 4},
+		{ID: "second", Date: day.AddDate(0, 0, 1), Units: 8},
+		{ID: "third", Date: day.AddDate(0, 0, 2), Units: 2},
+	}
+	// the first event is unchanged, the second has changed and the third is new
+	stored := []storage.Record{
+		{
+			Time:   day,
+			Tags:   map[string]string{storage.ImputedTag: "false", storage.EventTag: "first", storage.RunTag: "previous"},
+			Fields: map[string]interface{}{storage.UnitsField: 4.0, storage.TimedField: false, storage.UTCOffsetField: int64(0)},
+		},
+		{
+			Time:   day.AddDate(0, 0, 1),
+			Tags:   map[string]string{storage.ImputedTag: "false", storage.EventTag: "second"},
+			Fields: map[string]interface{}{storage.UnitsField: 6.0, storage.TimedField: false, storage.UTCOffsetField: int64(0)},
+		},
+	}
+
+	mockIter := mock_calendar.NewMockEventIterator(ctrl)
+	mockIter.EXPECT().Count().Return(len(events))
+	mockIter.EXPECT().Pages().Return(1)
+	var current int
+	mockIter.EXPECT().Next().DoAndReturn(
+		func() (calendar.Event, error) {
+			if current == len(events) {
+				return calendar.Event{}, calendar.ErrNoMoreEvents
+			}
+			current++
+			return events[current-1], nil
+		},
+	).AnyTimes()
+
+	mockFetcher := mock_calendar.NewMockFetcher(ctrl)
+	mockFetcher.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(mockIter, nil)
+	mockCalendar := mock_calendar.NewMockProvider(ctrl)
+	mockCalendar.EXPECT().Fetcher(gomock.Any(), gomock.Any()).Return(mockFetcher, nil)
+
+	mockStorer := mock_storage.NewMockStorer(ctrl)
+	mockStorer.EXPECT().QueryRecords(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, options ...storage.QueryOption) ([]storage.Record, error) {
+			q, err := storage.NewRecordQuery(options...)
+			if err != nil || !q.StartTime.Equal(day) || !q.EndTime.Equal(day.AddDate(0, 0, 2).Add(time.Nanosecond)) {
+				t.Fatalf("unexpected query: %+v (%v)", q, err)
+			}
+			return stored, nil
+		},
+	)
+	mockStorer.EXPECT().Delete(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, options ...storage.QueryOption) error {
+			q, err := storage.NewRecordQuery(options...)
+			if err != nil || !q.StartTime.Equal(day.AddDate(0, 0, 1)) || q.EndTime.Sub(q.StartTime) != time.Nanosecond ||
+				q.Event != "second" {
+				t.Fatalf("unexpected delete: %+v (%v)", q, err)
+			}
+			return nil
+		},
+	)
+	var written []storage.Record
+	mockStorer.EXPECT().Store(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, records ...storage.Record) error {
+			written = records
+			return nil
+		},
+	)
+
+	mockRuns := mock_storage.NewMockRunStore(ctrl)
+	mockRuns.EXPECT().ReadWatermark(gomock.Any(), "").Return(day, nil)
+	mockRuns.EXPECT().StoreWatermark(gomock.Any(), "", gomock.Any()).Return(nil)
+	var history storage.Run
+	mockRuns.EXPECT().StoreRun(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, run storage.Run) error {
+		history = run
+		return nil
+	})
+
+	api := New(mockStorer, mockCalendar, WithRunStore(mockRuns))
+	job := collectAndWait(t, api, `{}`)
+
+	if job.Status != collect.Succeeded || job.Progress.RecordsWritten != 2 {
+		t.Fatalf("expected succeeded job with 2 records written, got %+v", job)
+	}
+	if len(written) != 2 || !written[0].Time.Equal(events[1].Date) || !written[1].Time.Equal(events[2].Date) {
+		t.Fatalf("expected changed and new records to be written, got %+v", written)
+	}
+	for _, record := range written {
+		if run := record.Tags[storage.RunTag]; run != job.ID {
+			t.Fatalf("expected %s, got %s", job.ID, run)
+		}
+	}
+	if !history.FirstRecord.Equal(events[1].Date) || !history.LastRecord.Equal(events[2].Date) {
+		t.Fatalf("expected records between %s and %s, got %+v", events[1].Date, events[2].Date, history)
+	}
+}
+
+func TestAPI_Collect_FutureEndTime(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Date(2022, 8, 10, 12, 0, 0, 0, time.UTC)
+	start := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC)
+
+	// the bounded collect fetches up to the future end time, and the incremental collect resumes from the present
+	mockFetcher := mock_calendar.NewMockFetcher(ctrl)
+	gomock.InOrder(
+		mockFetcher.EXPECT().Fetch(gomock.Any(), start, end).Return(nil, calendar.ErrNoEventsFound),
+		mockFetcher.EXPECT().Fetch(gomock.Any(), now, time.Time{}).Return(nil, calendar.ErrNoEventsFound),
+	)
+	mockCalendar := mock_calendar.NewMockProvider(ctrl)
+	mockCalendar.EXPECT().Fetcher(gomock.Any(), gomock.Any()).Return(mockFetcher, nil).Times(2)
+
+	var watermark time.Time
+	mockRuns := mock_storage.NewMockRunStore(ctrl)
+	mockRuns.EXPECT().ReadWatermark(gomock.Any(), "").DoAndReturn(func(context.Context, string) (time.Time, error) {
+		if watermark.IsZero() {
+			return time.Time{}, storage.ErrNoResults
+		}
+		return watermark, nil
+	}).Times(2)
+	mockRuns.EXPECT().StoreWatermark(gomock.Any(), "", gomock.Any()).DoAndReturn(
+		func(_ context.Context, _ string, t time.Time) error {
+			watermark = t
+			return nil
+		},
+	).Times(2)
+	var history storage.Run
+	mockRuns.EXPECT().StoreRun(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, run storage.Run) error {
+		history = run
+		return nil
+	}).Times(2)
+
+	api := New(mock_storage.NewMockStorer(ctrl), mockCalendar, WithRunStore(mockRuns))
+	api.now = func() time.Time { return now }
+
+	job := collectAndWait(t, api, `{"start_time_override": "2022-08-01T00:00:00Z", "end_time": "2022-09-01T00:00:00Z"}`)
+	if job.Status != collect.Succeeded || !history.RangeEnd.Equal(now) || !watermark.Equal(now) {
+		t.Fatalf("expected range and watermark to end at %s, got %+v and %s", now, history, watermark)
+	}
+
+	api.now = func() time.Time { return now.Add(time.Hour) }
+	job = collectAndWait(t, api, `{}`)
+	if job.Status != collect.Succeeded || !history.RangeStart.Equal(now) {
+		t.Fatalf("expected incremental collect to start at %s, got %+v", now, history)
+	}
+}
+
+func TestAPI_Collect_SameTime(t *testing.T) {
+	// all-day events on the same date share a time, and are distinguished by their event IDs
+	day := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	events := []calendar.Event{
+		{ID: "first", Date: day, Units: 4},
+		{ID: "second", Date: day, Units: 3},
+	}
+	record := func(event string, units float64) storage.Record {
+		tags := map[string]string{storage.ImputedTag: "false"}
+		if event != "" {
+			tags[storage.EventTag] = event
+		}
+		return storage.Record{
+			Time:   day,
+			Tags:   tags,
+			Fields: map[string]interface{}{storage.UnitsField: units, storage.TimedField: false, storage.UTCOffsetField: int64(0)},
+		}
+	}
+
+	cases := []struct {
+		name    string
+		stored  []storage.Record
+		deleted []string
+		written []string
+	}{
+		{
+			name:   "unchanged",
+			stored: []storage.Record{record("first", 4), record("second", 3)},
+		},
+		{
+			name:    "changed",
+			stored:  []storage.Record{record("first", 4), record("second", 2)},
+			deleted: []string{"second"},
+			written: []string{"second"},
+		},
+		{
+			name:    "new",
+			stored:  []storage.Record{record("first", 4)},
+			written: []string{"second"},
+		},
+		{
+			// records written before events were tagged cannot be matched, so the whole time is replaced
+			name:    "untagged",
+			stored:  []storage.Record{record("", 7)},
+			deleted: []string{""},
+			written: []string{"first", "second"},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockIter := mock_calendar.NewMockEventIterator(ctrl)
+			mockIter.EXPECT().Count().Return(len(events))
+			mockIter.EXPECT().Pages().Return(1)
+			var current int
+			mockIter.EXPECT().Next().DoAndReturn(
+				func() (calendar.Event, error) {
+					if current == len(events) {
+						return calendar.Event{}, calendar.ErrNoMoreEvents
+					}
+					current++
+					return events[current-1], nil
+				},
+			).AnyTimes()
+
+			mockFetcher := mock_calendar.NewMockFetcher(ctrl)
+			mockFetcher.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(mockIter, nil)
+			mockCalendar := mock_calendar.NewMockProvider(ctrl)
+			mockCalendar.EXPECT().Fetcher(gomock.Any(), gomock.Any()).Return(mockFetcher, nil)
+
+			mockStorer := mock_storage.NewMockStorer(ctrl)
+			mockStorer.EXPECT().QueryRecords(gomock.Any(), gomock.Any()).Return(tt.stored, nil)
+			var deleted []string
+			mockStorer.EXPECT().Delete(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, options ...storage.QueryOption) error {
+					q, err := storage.NewRecordQuery(options...)
+					if err != nil || !q.StartTime.Equal(day) || q.EndTime.Sub(q.StartTime) != time.Nanosecond {
+						t.Fatalf("unexpected delete: %+v (%v)", q, err)
+					}
+					deleted = append(deleted, q.Event)
+					return nil
+				},
+			).AnyTimes()
+			var written []string
+			mockStorer.EXPECT().Store(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, records ...storage.Record) error {
+					for _, record := range records {
+						written = append(written, record.Tags[storage.EventTag])
+					}
+					return nil
+				},
+			).AnyTimes()
+
+			mockRuns := mock_storage.NewMockRunStore(ctrl)
+			mockRuns.EXPECT().ReadWatermark(gomock.Any(), "").Return(day, nil)
+			mockRuns.EXPECT().StoreWatermark(gomock.Any(), "", gomock.Any()).Return(nil).AnyTimes()
+			mockRuns.EXPECT().StoreRun(gomock.Any(), gomock.Any()).Return(nil)
+
+			api := New(mockStorer, mockCalendar, WithRunStore(mockRuns))
+			job := collectAndWait(t, api, `{}`)
+
+			if job.Status != collect.Succeeded {
+				t.Fatalf("expected succeeded job, got %+v", job)
+			}
+			if !reflect.DeepEqual(deleted, tt.deleted) {
+				t.Fatalf("expected deleted events %v, got %v", tt.deleted, deleted)
+			}
+			if !reflect.DeepEqual(written, tt.written) {
+				t.Fatalf("expected written events %v, got %v", tt.written, written)
+			}
+		})
+	}
+}
+
+func TestAPI_GetCollectJob(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// jobs started by other instances are read from the job store
+	remote := collect.Job{ID: "remote", Status: collect.Succeeded, Progress: collect.Progress{PagesFetched: 3}}
+	mockJobs := mock_collect.NewMockStore(ctrl)
+	mockJobs.EXPECT().ReadJob(gomock.Any(), "remote").Return(remote, nil)
+	mockJobs.EXPECT().ReadJob(gomock.Any(), "unknown").Return(collect.Job{}, collect.ErrNotFound)
+	mockJobs.EXPECT().ReadJob(gomock.Any(), "unavailable").Return(collect.Job{}, errors.New("influx unavailable"))
+
+	api := New(nil, nil, WithJobStore(mockJobs))
+
+	// a job is already running
+	run, err := api.jobs.Start()
+	if err != nil {
+		t.Fatalf("failed to start job: %s", err)
+	}
+	run.AddPages(2)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{}`))
+	api.Collect(w, r)
+	if status := w.Result().StatusCode; status != http.StatusConflict {
+		t.Fatalf("expected %d, got %d", http.StatusConflict, status)
+	}
+
+	cases := []struct {
+		name   string
+		id     string
+		status int
+		// expected job status and pages fetched
+		jobStatus collect.Status
+		pages     int
+	}{
+		{
+			name:      "running",
+			id:        run.ID(),
+			status:    http.StatusOK,
+			jobStatus: collect.Running,
+			pages:     2,
+		},
+		{
+			name:      "other_instance",
+			id:        "remote",
+			status:    http.StatusOK,
+			jobStatus: collect.Succeeded,
+			pages:     3,
+		},
+		{
+			name:   "not_found",
+			id:     "unknown",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "store_error",
+			id:     "unavailable",
+			status: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r = mux.SetURLVars(r, map[string]string{"id": tt.id})
+			api.GetCollectJob(w, r)
+
+			// validate status
+			status := w.Result().StatusCode
+			if status != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			job := collect.Job{}
+			if err := json.NewDecoder(w.Body).Decode(&job); err != nil {
+				t.Fatalf("failed to decode response: %s", err)
+			}
+			if job.Status != tt.jobStatus || job.Progress.PagesFetched != tt.pages {
+				t.Fatalf("expected %s job with %d pages, got %+v", tt.jobStatus, tt.pages, job)
+			}
+		})
+	}
+}
+
+func TestAPI_Collect_JobStore(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockFetcher := mock_calendar.NewMockFetcher(ctrl)
+	mockFetcher.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, calendar.ErrNoEventsFound).Times(2)
+	mockCalendar := mock_calendar.NewMockProvider(ctrl)
+	mockCalendar.EXPECT().Fetcher(gomock.Any(), gomock.Any()).Return(mockFetcher, nil).Times(2)
+
+	mockStorer := mock_storage.NewMockStorer(ctrl)
+	mockStorer.EXPECT().ReadLastTimestamp(gomock.Any(), gomock.Any()).Return(time.Time{}, storage.ErrNoResults).Times(2)
+
+	// the job is saved once it has started, after each user, and once it has finished
+	mockJobs := mock_collect.NewMockStore(ctrl)
+	var saved []collect.Status
+	mockJobs.EXPECT().StoreJob(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, job collect.Job) error {
+			saved = append(saved, job.Status)
+			return nil
+		},
+	).Times(4)
+
+	api := New(mockStorer, mockCalendar, WithUsers(newTestUsers(t)), WithJobStore(mockJobs))
+	job := collectAndWait(t, api, `{}`)
+
+	if job.Status != collect.Succeeded {
+		t.Fatalf("expected %s, got %s", collect.Succeeded, job.Status)
+	}
+	expected := []collect.Status{collect.Running, collect.Running, collect.Running, collect.Succeeded}
+	if !reflect.DeepEqual(saved, expected) {
+		t.Fatalf("expected %v, got %v", expected, saved)
+	}
+}
+
+// collectAndWait starts a collect job and waits for it to finish, returning the finished job.
+func collectAndWait(t *testing.T, api *API, body string) collect.Job {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+	api.Collect(w, r)
+
+	// validate status
+	status := w.Result().StatusCode
+	if status != http.StatusAccepted {
+		t.Fatalf("expected %d, got %d", http.StatusAccepted, status)
+	}
+
+	job := collect.Job{}
+	if err := json.NewDecoder(w.Body).Decode(&job); err != nil {
+		t.Fatalf("failed to decode response: %s", err)
+	}
+	if location := w.Result().Header.Get("Location"); location != "/api/v1/collect/"+job.ID {
+		t.Fatalf("unexpected location: %s", location)
+	}
+
+	api.collecting.Wait()
+	job, err := api.jobs.Get(context.Background(), job.ID)
+	if err != nil {
+		t.Fatalf("failed to get job: %s", err)
+	}
+	return job
+}
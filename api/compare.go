@@ -0,0 +1,76 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/stats"
+	"github.com/jemgunay/canlendar-graph/storage"
+	"github.com/jemgunay/canlendar-graph/units"
+)
+
+type compareResponse struct {
+	Aggregation storage.Aggregation   `json:"aggregation"`
+	Current     []storage.Plot        `json:"current"`
+	Reference   []storage.Plot        `json:"reference"`
+	Changes     []stats.AlignedChange `json:"changes"`
+	Total       stats.Change          `json:"total"`
+	Unit        units.Unit            `json:"unit"`
+}
+
+// Compare compares the units consumed within a current time range (start_time and end_time) against a reference time
+// range (reference_start_time and reference_end_time) for the given aggregation. The aggregated periods of each range
+// are aligned by their position within the range, e.g. to compare this month against the same month last year.
+func (a *API) Compare(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	query := r.URL.Query()
+	aggregation := storage.Aggregation(query.Get("aggregation"))
+
+	outputUnit, ok := parseUnit(query)
+	if !ok {
+		log.Printf("unsupported output unit: %s", outputUnit)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	ranges := make(map[string][]storage.Plot, 2)
+	for _, prefix := range []string{"", "reference_"} {
+		startTime, _ := time.Parse(time.RFC3339, query.Get(prefix+"start_time"))
+		endTime, _ := time.Parse(time.RFC3339, query.Get(prefix+"end_time"))
+
+		// both ranges require explicit start times in order to be aligned
+		opts := []storage.QueryOption{
+			storage.WithAggregation(aggregation),
+			storage.WithStartTime(startTime),
+			storage.WithEndTime(endTime),
+		}
+		if q, err := storage.NewQuery(opts...); err != nil || q.StartTime.IsZero() {
+			log.Printf("invalid %squery range provided: %v", prefix, err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		plots, err := a.queryPlots(ctx, opts...)
+		if err != nil {
+			log.Printf("failed to query storage: %s", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if plots == nil {
+			plots = []storage.Plot{}
+		}
+		ranges[prefix] = outputUnit.FromUKPlots(plots)
+	}
+
+	current, reference := ranges[""], ranges["reference_"]
+	writeJSON(w, compareResponse{
+		Aggregation: aggregation,
+		Current:     current,
+		Reference:   reference,
+		Changes:     stats.Compare(current, reference),
+		Total:       stats.NewChange(stats.Total(current), stats.Total(reference)),
+		Unit:        outputUnit,
+	})
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jemgunay/canlendar-graph/storage"
+
+	mock_storage "github.com/jemgunay/canlendar-graph/storage/mocks"
+)
+
+func TestAPI_Compare(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cases := []struct {
+		name     string
+		params   string
+		status   int
+		respBody string
+	}{
+		{
+			name: "day",
+			params: "?aggregation=day&start_time=2022-08-01T00:00:00Z&end_time=2022-08-04T00:00:00Z" +
+				"&reference_start_time=2022-07-01T00:00:00Z&reference_end_time=2022-07-04T00:00:00Z",
+			status: http.StatusOK,
+			respBody: `{"aggregation":"day","current":[{"t":1,"y":4},{"t":2,"y":2},{"t":3,"y":6}],` +
+				`"reference":[{"t":-3,"y":2},{"t":-2,"y":0}],` +
+				`"changes":[{"t":1,"reference_t":-3,"current":4,"reference":2,"delta":2,"percentage_change":100},` +
+				`{"t":2,"reference_t":-2,"current":2,"reference":0,"delta":2,"percentage_change":null}],` +
+				`"total":{"current":12,"reference":2,"delta":10,"percentage_change":500},"unit":"uk_units"}`,
+		},
+		{
+			name:   "missing_reference_range",
+			params: "?aggregation=day&start_time=2022-08-01T00:00:00Z&end_time=2022-08-04T00:00:00Z",
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorer := mock_storage.NewMockStorer(ctrl)
+			mockStorer.EXPECT().Query(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(ctx context.Context, opts ...storage.QueryOption) ([]storage.Plot, error) {
+					q, err := storage.NewQuery(opts...)
+					if err != nil {
+						return nil, err
+					}
+					if q.StartTime.Month() == 8 {
+						return []storage.Plot{{X: 1, Y: 4}, {X: 2, Y: 2}, {X: 3, Y: 6}}, nil
+					}
+					return []storage.Plot{{X: -3, Y: 2}, {X: -2, Y: 0}}, nil
+				},
+			).AnyTimes()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/"+tt.params, nil)
+
+			api := New(mockStorer, nil)
+			api.Compare(w, r)
+
+			// validate status
+			status := w.Result().StatusCode
+			if status != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, status)
+			}
+
+			respBody, err := io.ReadAll(w.Result().Body)
+			if err != nil {
+				t.Fatalf("failed to read resp body: %s", err)
+			}
+
+			respBody = bytes.TrimSpace(respBody)
+			if string(respBody) != tt.respBody {
+				t.Fatalf("expected '%s', got '%s'", tt.respBody, respBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/stats"
+	"github.com/jemgunay/canlendar-graph/storage"
+	"github.com/jemgunay/canlendar-graph/units"
+)
+
+type distributionResponse struct {
+	Daily    stats.Distribution          `json:"daily"`
+	Weekdays []stats.WeekdayDistribution `json:"weekdays"`
+	Hours    []stats.HourDistribution    `json:"hours"`
+	Unit     units.Unit                  `json:"unit"`
+}
+
+// Distribution returns the distribution of daily unit totals overall and by weekday, and the distribution of event
+// units by local hour of the day. The end time defaults to now, and the start time to the first record in storage.
+func (a *API) Distribution(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	query := r.URL.Query()
+	startTime, _ := time.Parse(time.RFC3339, query.Get("start_time"))
+	endTime, err := time.Parse(time.RFC3339, query.Get("end_time"))
+	if err != nil {
+		endTime = a.now()
+	}
+
+	outputUnit, ok := parseUnit(query)
+	if !ok {
+		log.Printf("unsupported output unit: %s", outputUnit)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if startTime.IsZero() {
+		startTime, err = a.storer.ReadFirstTimestamp(ctx)
+		if errors.Is(err, storage.ErrNoResults) {
+			// there are no records to distribute
+			writeJSON(w, distributionResponse{
+				Daily:    stats.NewDistribution(nil),
+				Weekdays: stats.DistributeByWeekday(nil),
+				Hours:    stats.DistributeByHour(nil),
+				Unit:     outputUnit,
+			})
+			return
+		}
+		if err != nil {
+			log.Printf("failed to read first timestamp from storage: %s", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	records, err := a.queryRecords(ctx,
+		storage.WithStartTime(startTime),
+		storage.WithEndTime(endTime),
+	)
+	if err != nil {
+		log.Printf("failed to query records from storage: %s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	totals := stats.DailyTotals(records, startTime, endTime)
+	dailyTotals := make([]float64, 0, len(totals))
+	for _, units := range totals {
+		dailyTotals = append(dailyTotals, units)
+	}
+
+	resp := distributionResponse{
+		Daily:    stats.NewDistribution(dailyTotals),
+		Weekdays: stats.DistributeByWeekday(totals),
+		Hours:    stats.DistributeByHour(records),
+		Unit:     outputUnit,
+	}
+
+	// convert from UK units to the output unit
+	convertDistribution(&resp.Daily, outputUnit)
+	for i := range resp.Weekdays {
+		convertDistribution(&resp.Weekdays[i].Distribution, outputUnit)
+	}
+	for i := range resp.Hours {
+		convertDistribution(&resp.Hours[i].Distribution, outputUnit)
+	}
+
+	writeJSON(w, resp)
+}
+
+// convertDistribution converts a distribution from UK units to the provided unit.
+func convertDistribution(d *stats.Distribution, unit units.Unit) {
+	d.Total = unit.FromUK(d.Total)
+	d.Mean = unit.FromUK(d.Mean)
+	d.P50 = unit.FromUK(d.P50)
+	d.P75 = unit.FromUK(d.P75)
+	d.P90 = unit.FromUK(d.P90)
+}
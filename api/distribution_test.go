@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jemgunay/canlendar-graph/stats"
+	"github.com/jemgunay/canlendar-graph/storage"
+
+	mock_storage "github.com/jemgunay/canlendar-graph/storage/mocks"
+)
+
+func TestAPI_Distribution(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	record := func(t time.Time, units float64, timed bool, offset int) storage.Record {
+		return storage.Record{
+			Time: t,
+			Fields: map[string]interface{}{
+				storage.UnitsField:     units,
+				storage.TimedField:     timed,
+				storage.UTCOffsetField: int64(offset),
+			},
+		}
+	}
+
+	mockStorer := mock_storage.NewMockStorer(ctrl)
+	mockStorer.EXPECT().QueryRecords(gomock.Any(), gomock.Any()).Return([]storage.Record{
+		// Monday 21:00 local time
+		record(time.Date(2022, 8, 1, 20, 0, 0, 0, time.UTC), 4, true, 3600),
+		// Tuesday 00:30 local time
+		record(time.Date(2022, 8, 1, 23, 30, 0, 0, time.UTC), 2, true, 3600),
+		// Friday, with no time of day
+		record(time.Date(2022, 8, 5, 0, 0, 0, 0, time.UTC), 6, false, 0),
+	}, nil)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/?start_time=2022-08-01T00:00:00Z&end_time=2022-08-08T00:00:00Z", nil)
+
+	api := New(mockStorer, nil)
+	api.Distribution(w, r)
+
+	// validate status
+	status := w.Result().StatusCode
+	if status != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, status)
+	}
+
+	resp := distributionResponse{}
+	if err := json.NewDecoder(w.Result().Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode resp body: %s", err)
+	}
+
+	expectedDaily := stats.Distribution{Count: 7, Total: 12, Mean: 12.0 / 7, P50: 0, P75: 3, P90: 4.8}
+	// round to avoid floating point interpolation error
+	resp.Daily.P90 = math.Round(resp.Daily.P90*100) / 100
+	if resp.Daily != expectedDaily {
+		t.Fatalf("expected %+v, got %+v", expectedDaily, resp.Daily)
+	}
+
+	expectedWeekdays := map[string]float64{"Monday": 4, "Tuesday": 2, "Wednesday": 0, "Friday": 6}
+	for _, wd := range resp.Weekdays {
+		if expected, ok := expectedWeekdays[wd.Weekday]; ok && wd.Total != expected {
+			t.Fatalf("expected %v for %s, got %v", expected, wd.Weekday, wd.Total)
+		}
+	}
+	if resp.Weekdays[0].Weekday != "Monday" {
+		t.Fatalf("expected %s, got %s", "Monday", resp.Weekdays[0].Weekday)
+	}
+
+	expectedHours := map[int]float64{0: 2, 21: 4}
+	for _, h := range resp.Hours {
+		if h.Total != expectedHours[h.Hour] {
+			t.Fatalf("expected %v for hour %d, got %v", expectedHours[h.Hour], h.Hour, h.Total)
+		}
+	}
+}
+
+func TestAPI_Distribution_NoRecords(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// without a start time or any records, there are no days to distribute
+	mockStorer := mock_storage.NewMockStorer(ctrl)
+	mockStorer.EXPECT().ReadFirstTimestamp(gomock.Any(), gomock.Any()).Return(time.Time{}, storage.ErrNoResults)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	api := New(mockStorer, nil)
+	api.Distribution(w, r)
+
+	// validate status
+	status := w.Result().StatusCode
+	if status != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, status)
+	}
+
+	resp := distributionResponse{}
+	if err := json.NewDecoder(w.Result().Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode resp body: %s", err)
+	}
+	if resp.Daily != (stats.Distribution{}) || len(resp.Weekdays) != 7 || len(resp.Hours) != 24 {
+		t.Fatalf("expected empty distributions, got %+v", resp)
+	}
+	for _, wd := range resp.Weekdays {
+		if wd.Count != 0 {
+			t.Fatalf("expected no days for %s, got %d", wd.Weekday, wd.Count)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/jemgunay/canlendar-graph/goal"
+	"github.com/jemgunay/canlendar-graph/storage"
+)
+
+type goalResponse struct {
+	goal.Goal
+	Evaluation goal.Evaluation `json:"evaluation"`
+}
+
+// ListGoals lists every goal along with its evaluation.
+func (a *API) ListGoals(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if a.goals == nil {
+		log.Printf("no goal store configured")
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	goals, err := a.goals.List(ctx)
+	if err != nil {
+		log.Printf("failed to list goals: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]goalResponse, 0, len(goals))
+	for _, g := range goals {
+		evaluation, err := a.evaluateGoal(ctx, g)
+		if err != nil {
+			log.Printf("failed to evaluate goal %s: %s", g.ID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		resp = append(resp, goalResponse{Goal: g, Evaluation: evaluation})
+	}
+
+	writeJSON(w, resp)
+}
+
+// GetGoal gets a single goal along with its evaluation.
+func (a *API) GetGoal(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if a.goals == nil {
+		log.Printf("no goal store configured")
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	g, err := a.goals.Get(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		writeGoalStoreError(w, err)
+		return
+	}
+
+	evaluation, err := a.evaluateGoal(ctx, g)
+	if err != nil {
+		log.Printf("failed to evaluate goal %s: %s", g.ID, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, goalResponse{Goal: g, Evaluation: evaluation})
+}
+
+// CreateGoal creates a goal from the request body.
+func (a *API) CreateGoal(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if a.goals == nil {
+		log.Printf("no goal store configured")
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	g, ok := decodeGoal(w, r)
+	if !ok {
+		return
+	}
+
+	g, err := a.goals.Create(ctx, g)
+	if err != nil {
+		log.Printf("failed to create goal: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, g)
+}
+
+// UpdateGoal replaces a goal with the request body.
+func (a *API) UpdateGoal(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if a.goals == nil {
+		log.Printf("no goal store configured")
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	g, ok := decodeGoal(w, r)
+	if !ok {
+		return
+	}
+
+	g.ID = mux.Vars(r)["id"]
+	if err := a.goals.Update(ctx, g); err != nil {
+		writeGoalStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, g)
+}
+
+// DeleteGoal deletes a goal.
+func (a *API) DeleteGoal(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if a.goals == nil {
+		log.Printf("no goal store configured")
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	if err := a.goals.Delete(ctx, mux.Vars(r)["id"]); err != nil {
+		writeGoalStoreError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeGoal decodes and validates a goal from the request body, writing a bad request status if it is invalid.
+func decodeGoal(w http.ResponseWriter, r *http.Request) (goal.Goal, bool) {
+	g := goal.Goal{}
+	if err := json.NewDecoder(r.Body).Decode(&g); err != nil {
+		log.Printf("unable to decode request body: %s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return goal.Goal{}, false
+	}
+
+	g.Normalise()
+	if err := g.Validate(); err != nil {
+		log.Printf("invalid goal provided: %s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return goal.Goal{}, false
+	}
+	return g, true
+}
+
+// writeGoalStoreError writes the status for a goal store error.
+func writeGoalStoreError(w http.ResponseWriter, err error) {
+	if errors.Is(err, goal.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	log.Printf("goal store request failed: %s", err)
+	w.WriteHeader(http.StatusInternalServerError)
+}
+
+// evaluateGoal evaluates a goal against the units in storage.
+func (a *API) evaluateGoal(ctx context.Context, g goal.Goal) (goal.Evaluation, error) {
+	now := a.now()
+
+	var days []storage.Plot
+	if !g.Start.After(now) {
+		var err error
+		days, err = a.queryPlots(ctx,
+			storage.WithAggregation(storage.Day),
+			storage.WithStartTime(g.Start),
+			storage.WithEndTime(g.QueryEnd(now)),
+		)
+		if err != nil {
+			return goal.Evaluation{}, err
+		}
+	}
+
+	return goal.Evaluate(g, days, now), nil
+}
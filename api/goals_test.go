@@ -0,0 +1,266 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/jemgunay/canlendar-graph/goal"
+	"github.com/jemgunay/canlendar-graph/storage"
+
+	mock_goal "github.com/jemgunay/canlendar-graph/goal/mocks"
+	mock_storage "github.com/jemgunay/canlendar-graph/storage/mocks"
+)
+
+func TestAPI_GetGoal(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	date := func(day int) time.Time {
+		return time.Date(2022, 8, day, 0, 0, 0, 0, time.UTC)
+	}
+	end := date(20)
+	// a Wednesday
+	now := time.Date(2022, 8, 17, 12, 0, 0, 0, time.UTC)
+
+	// units per day from the 1st of the month
+	days := func(units ...float64) []storage.Plot {
+		plots := make([]storage.Plot, 0, len(units))
+		for i, u := range units {
+			plots = append(plots, storage.Plot{X: date(i + 1).UnixMilli(), Y: u})
+		}
+		return plots
+	}
+
+	cases := []struct {
+		name     string
+		goal     goal.Goal
+		days     []storage.Plot
+		status   goal.Status
+		periods  int
+		met      int
+		failed   int
+		current  int
+		longest  int
+		progress float64
+	}{
+		{
+			name:     "dry_range_in_progress",
+			goal:     goal.Goal{ID: "a", Type: goal.DryRange, Start: date(10), End: &end},
+			days:     days(5, 5, 5, 5, 5, 5, 5, 5, 5, 0, 0, 0, 0, 0, 0, 0, 0),
+			status:   goal.InProgress,
+			periods:  8,
+			met:      7,
+			current:  7,
+			longest:  7,
+			progress: 70,
+		},
+		{
+			name:     "dry_range_failed",
+			goal:     goal.Goal{ID: "a", Type: goal.DryRange, Start: date(10), End: &end},
+			days:     days(5, 5, 5, 5, 5, 5, 5, 5, 5, 0, 0, 3, 0, 0, 0, 0, 0),
+			status:   goal.Failed,
+			periods:  8,
+			met:      6,
+			failed:   1,
+			current:  4,
+			longest:  4,
+			progress: 60,
+		},
+		{
+			name:     "max_weekly_units",
+			goal:     goal.Goal{ID: "a", Type: goal.MaxWeeklyUnits, Start: date(1), Target: 14},
+			days:     days(5, 0, 5, 0, 0, 0, 0, 10, 0, 10, 0, 0, 0, 0, 5, 0, 0),
+			status:   goal.InProgress,
+			periods:  3,
+			met:      1,
+			failed:   1,
+			current:  0,
+			longest:  1,
+			progress: 5.0 / 14 * 100,
+		},
+		{
+			name:     "min_weekly_dry_days",
+			goal:     goal.Goal{ID: "a", Type: goal.MinWeeklyDryDays, Start: date(1), Target: 3},
+			days:     days(1, 1, 0, 1, 1, 0, 1, 0, 0, 2, 0, 2, 0, 2, 0, 4, 0),
+			status:   goal.InProgress,
+			periods:  3,
+			met:      1,
+			failed:   1,
+			current:  1,
+			longest:  1,
+			progress: 1.0 / 3 * 100,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockGoals := mock_goal.NewMockStorer(ctrl)
+			mockGoals.EXPECT().Get(gomock.Any(), "a").Return(tt.goal, nil)
+
+			mockStorer := mock_storage.NewMockStorer(ctrl)
+			mockStorer.EXPECT().Query(gomock.Any(), gomock.Any()).Return(tt.days, nil)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r = mux.SetURLVars(r, map[string]string{"id": "a"})
+
+			api := New(mockStorer, nil, WithGoalStore(mockGoals))
+			api.now = func() time.Time { return now }
+			api.GetGoal(w, r)
+
+			// validate status
+			status := w.Result().StatusCode
+			if status != http.StatusOK {
+				t.Fatalf("expected %d, got %d", http.StatusOK, status)
+			}
+
+			resp := goalResponse{}
+			if err := json.NewDecoder(w.Result().Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode resp body: %s", err)
+			}
+
+			e := resp.Evaluation
+			if e.Status != tt.status {
+				t.Fatalf("expected status %s, got %s", tt.status, e.Status)
+			}
+			if len(e.Periods) != tt.periods {
+				t.Fatalf("expected %d periods, got %d", tt.periods, len(e.Periods))
+			}
+			if e.Met != tt.met || e.Failed != tt.failed {
+				t.Fatalf("expected %d met and %d failed, got %d met and %d failed", tt.met, tt.failed, e.Met, e.Failed)
+			}
+			if e.CurrentStreak != tt.current || e.LongestStreak != tt.longest {
+				t.Fatalf("expected streaks of %d (current) and %d (longest), got %d and %d", tt.current, tt.longest,
+					e.CurrentStreak, e.LongestStreak)
+			}
+			if math.Abs(e.ProgressPercentage-tt.progress) > 1e-9 {
+				t.Fatalf("expected progress %v, got %v", tt.progress, e.ProgressPercentage)
+			}
+		})
+	}
+}
+
+func TestAPI_CreateGoal(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cases := []struct {
+		name     string
+		reqBody  string
+		expected *goal.Goal
+		status   int
+		respBody string
+	}{
+		{
+			name:    "success",
+			reqBody: `{"name":"Dry January","type":"dry_range","start":"2023-01-01T09:00:00Z","end":"2023-02-01T00:00:00Z"}`,
+			expected: &goal.Goal{
+				Name:  "Dry January",
+				Type:  goal.DryRange,
+				Start: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			status: http.StatusCreated,
+			respBody: `{"id":"a","name":"Dry January","type":"dry_range","start":"2023-01-01T00:00:00Z",` +
+				`"end":"2023-02-01T00:00:00Z","target":0}`,
+		},
+		{
+			name:    "dry_range_without_end",
+			reqBody: `{"name":"Dry forever","type":"dry_range","start":"2023-01-01T00:00:00Z"}`,
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:    "too_many_dry_days",
+			reqBody: `{"type":"min_weekly_dry_days","start":"2023-01-01T00:00:00Z","target":8}`,
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:    "unsupported_type",
+			reqBody: `{"type":"sober_sundays","start":"2023-01-01T00:00:00Z"}`,
+			status:  http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockGoals := mock_goal.NewMockStorer(ctrl)
+			if tt.expected != nil {
+				mockGoals.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ interface{}, g goal.Goal) (goal.Goal, error) {
+						if g.Name != tt.expected.Name || g.Type != tt.expected.Type || !g.Start.Equal(tt.expected.Start) {
+							t.Fatalf("unexpected goal: %+v", g)
+						}
+						g.ID = "a"
+						return g, nil
+					})
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.reqBody))
+
+			api := New(nil, nil, WithGoalStore(mockGoals))
+			api.CreateGoal(w, r)
+
+			// validate status
+			status := w.Result().StatusCode
+			if status != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, status)
+			}
+			if tt.status != http.StatusCreated {
+				return
+			}
+
+			respBody := strings.TrimSpace(w.Body.String())
+			if respBody != tt.respBody {
+				t.Fatalf("expected '%s', got '%s'", tt.respBody, respBody)
+			}
+		})
+	}
+}
+
+func TestAPI_DeleteGoal(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cases := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{
+			name:   "success",
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "not_found",
+			err:    goal.ErrNotFound,
+			status: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockGoals := mock_goal.NewMockStorer(ctrl)
+			mockGoals.EXPECT().Delete(gomock.Any(), "a").Return(tt.err)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodDelete, "/", nil)
+			r = mux.SetURLVars(r, map[string]string{"id": "a"})
+
+			api := New(nil, nil, WithGoalStore(mockGoals))
+			api.DeleteGoal(w, r)
+
+			// validate status
+			status := w.Result().StatusCode
+			if status != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, status)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/heatmap"
+	"github.com/jemgunay/canlendar-graph/storage"
+	"github.com/jemgunay/canlendar-graph/units"
+)
+
+type heatmapResponse struct {
+	Year             int                `json:"year"`
+	Weeks            []heatmap.Week     `json:"weeks"`
+	Thresholds       heatmap.Thresholds `json:"thresholds"`
+	GuidelineProfile string             `json:"guideline_profile"`
+	Unit             units.Unit         `json:"unit"`
+}
+
+// Heatmap returns a year's daily unit totals as a grid of weeks by weekdays, where each day is bucketed by intensity
+// relative to the guideline. The year defaults to the current year. Days after today are omitted.
+func (a *API) Heatmap(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	now := a.now().UTC()
+
+	query := r.URL.Query()
+	year := now.Year()
+	if y := query.Get("year"); y != "" {
+		var err error
+		if year, err = strconv.Atoi(y); err != nil {
+			log.Printf("invalid year provided: %s", y)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	outputUnit, ok := parseUnit(query)
+	if !ok {
+		log.Printf("unsupported output unit: %s", outputUnit)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	profile, ok := a.profile(ctx, query)
+	if !ok {
+		log.Printf("unsupported guideline profile: %s", query.Get("guideline"))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	start := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(1, 0, 0)
+	if today := storage.Day.PeriodEnd(now); today.Before(end) {
+		end = today
+	}
+	if !start.Before(end) {
+		log.Printf("heatmap year is in the future: %d", year)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	days, err := a.queryPlots(ctx,
+		storage.WithAggregation(storage.Day),
+		storage.WithStartTime(start),
+		storage.WithEndTime(end),
+	)
+	if err != nil {
+		log.Printf("failed to query storage: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	thresholds := heatmap.NewThresholds(profile)
+	weeks := heatmap.Build(days, start, end, thresholds)
+
+	// convert from UK units to the output unit
+	for _, week := range weeks {
+		for _, day := range week.Days {
+			if day != nil {
+				day.Units = outputUnit.FromUK(day.Units)
+			}
+		}
+	}
+
+	writeJSON(w, heatmapResponse{
+		Year:  year,
+		Weeks: weeks,
+		Thresholds: heatmap.Thresholds{
+			Low:      outputUnit.FromUK(thresholds.Low),
+			Moderate: outputUnit.FromUK(thresholds.Moderate),
+			Heavy:    outputUnit.FromUK(thresholds.Heavy),
+		},
+		GuidelineProfile: profile.Name,
+		Unit:             outputUnit,
+	})
+}
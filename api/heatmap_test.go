@@ -0,0 +1,61 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jemgunay/canlendar-graph/storage"
+
+	mock_storage "github.com/jemgunay/canlendar-graph/storage/mocks"
+)
+
+func TestAPI_Heatmap(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	day := func(d int) int64 {
+		return time.Date(2022, 1, d, 0, 0, 0, 0, time.UTC).UnixMilli()
+	}
+
+	mockStorer := mock_storage.NewMockStorer(ctrl)
+	mockStorer.EXPECT().Query(gomock.Any(), gomock.Any()).Return([]storage.Plot{
+		{X: day(1), Y: 10}, {X: day(2), Y: 1}, {X: day(3), Y: 4}, {X: day(4), Y: 0}, {X: day(5), Y: 5},
+	}, nil)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/?year=2022", nil)
+
+	api := New(mockStorer, nil)
+	api.now = func() time.Time { return time.Date(2022, 1, 5, 12, 0, 0, 0, time.UTC) }
+	api.Heatmap(w, r)
+
+	// validate status
+	status := w.Result().StatusCode
+	if status != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, status)
+	}
+
+	respBody, err := io.ReadAll(w.Result().Body)
+	if err != nil {
+		t.Fatalf("failed to read resp body: %s", err)
+	}
+
+	expected := `{"year":2022,"weeks":[` +
+		`{"start":"2021-12-27T00:00:00Z","days":[null,null,null,null,null,` +
+		`{"date":"2022-01-01","units":10,"bucket":"binge","level":4},` +
+		`{"date":"2022-01-02","units":1,"bucket":"low","level":1}]},` +
+		`{"start":"2022-01-03T00:00:00Z","days":[` +
+		`{"date":"2022-01-03","units":4,"bucket":"moderate","level":2},` +
+		`{"date":"2022-01-04","units":0,"bucket":"none","level":0},` +
+		`{"date":"2022-01-05","units":5,"bucket":"heavy","level":3},null,null,null,null]}],` +
+		`"thresholds":{"low":2,"moderate":4,"heavy":6},"guideline_profile":"uk","unit":"uk_units"}`
+	respBody = bytes.TrimSpace(respBody)
+	if string(respBody) != expected {
+		t.Fatalf("expected '%s', got '%s'", expected, respBody)
+	}
+}
@@ -0,0 +1,320 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jemgunay/canlendar-graph/collect"
+	"github.com/jemgunay/canlendar-graph/storage"
+	"github.com/jemgunay/canlendar-graph/tenant"
+)
+
+// collectRun summarises a collect run across every user.
+type collectRun struct {
+	JobID          string    `json:"job_id"`
+	StartedAt      time.Time `json:"started_at"`
+	FinishedAt     time.Time `json:"finished_at"`
+	Users          []string  `json:"users,omitempty"`
+	RecordsWritten int       `json:"records_written"`
+	Succeeded      bool      `json:"succeeded"`
+	Undone         bool      `json:"undone"`
+}
+
+type collectHistory struct {
+	Runs       []storage.Run      `json:"runs"`
+	Gaps       []collectGap       `json:"gaps"`
+	Watermarks []collectWatermark `json:"watermarks"`
+}
+
+// collectGap is a time range between successful runs of a user which was not collected.
+type collectGap struct {
+	User  string    `json:"user,omitempty"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+type collectWatermark struct {
+	User      string    `json:"user,omitempty"`
+	Watermark time.Time `json:"watermark"`
+}
+
+// GetCollectHistory gets the collect runs which started within the time range (start_time and end_time), the ranges
+// between successful runs which were not collected, and each user's collection watermark. The history can be
+// restricted to a single user with the user query parameter.
+func (a *API) GetCollectHistory(w http.ResponseWriter, r *http.Request) {
+	if a.runs == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+	ctx := r.Context()
+
+	query := r.URL.Query()
+	startTime, endTime, ok := a.historyRange(query)
+	if !ok {
+		log.Printf("invalid history range provided: %s to %s", query.Get("start_time"), query.Get("end_time"))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	users := a.users.List()
+	if id := query.Get(UserParam); id != "" {
+		user, err := a.users.Get(id)
+		if err != nil {
+			log.Printf("failed to resolve user: %s", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		users = []tenant.User{user}
+	}
+
+	runs, err := a.runs.QueryRuns(ctx, startTime, endTime)
+	if err != nil && !errors.Is(err, storage.ErrNoResults) {
+		log.Printf("failed to query collect runs: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	resp := collectHistory{
+		Runs:       []storage.Run{},
+		Watermarks: []collectWatermark{},
+	}
+	for _, user := range users {
+		userRuns := make([]storage.Run, 0)
+		for _, run := range runs {
+			if run.User == user.ID {
+				userRuns = append(userRuns, run)
+			}
+		}
+		resp.Runs = append(resp.Runs, userRuns...)
+		resp.Gaps = append(resp.Gaps, findGaps(user.ID, userRuns)...)
+
+		watermark, err := a.readWatermark(ctx, user)
+		if err != nil {
+			log.Printf("failed to read collection watermark: %s", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if !watermark.IsZero() {
+			resp.Watermarks = append(resp.Watermarks, collectWatermark{User: user.ID, Watermark: watermark})
+		}
+	}
+	if resp.Gaps == nil {
+		resp.Gaps = []collectGap{}
+	}
+	sort.SliceStable(resp.Runs, func(i, j int) bool {
+		return resp.Runs[i].StartedAt.Before(resp.Runs[j].StartedAt)
+	})
+
+	writeJSON(w, resp)
+}
+
+// findGaps finds the time ranges which were not collected between the ranges of a user's successful runs. Failed runs
+// are ignored, as their ranges may not have been collected, as are runs which have been undone.
+func findGaps(user string, runs []storage.Run) []collectGap {
+	succeeded := make([]storage.Run, 0, len(runs))
+	for _, run := range runs {
+		if run.Succeeded && !run.Undone {
+			succeeded = append(succeeded, run)
+		}
+	}
+	sort.Slice(succeeded, func(i, j int) bool {
+		return succeeded[i].RangeStart.Before(succeeded[j].RangeStart)
+	})
+
+	var (
+		gaps      []collectGap
+		collected time.Time
+	)
+	for i, run := range succeeded {
+		if i > 0 && run.RangeStart.After(collected) {
+			gaps = append(gaps, collectGap{
+				User:  user,
+				Start: collected,
+				End:   run.RangeStart,
+			})
+		}
+		if run.RangeEnd.After(collected) {
+			collected = run.RangeEnd
+		}
+	}
+	return gaps
+}
+
+// GetCollectRuns lists the collect runs which started within the time range (start_time and end_time), summarising
+// each run across every user.
+func (a *API) GetCollectRuns(w http.ResponseWriter, r *http.Request) {
+	if a.runs == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	query := r.URL.Query()
+	startTime, endTime, ok := a.historyRange(query)
+	if !ok {
+		log.Printf("invalid runs range provided: %s to %s", query.Get("start_time"), query.Get("end_time"))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	runs, err := a.runs.QueryRuns(r.Context(), startTime, endTime)
+	if err != nil && !errors.Is(err, storage.ErrNoResults) {
+		log.Printf("failed to query collect runs: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// runs are stored per user, and are ordered by start time
+	summaries := []collectRun{}
+	index := make(map[string]int)
+	for _, run := range runs {
+		i, ok := index[run.JobID]
+		if !ok {
+			i = len(summaries)
+			index[run.JobID] = i
+			summaries = append(summaries, collectRun{
+				JobID:     run.JobID,
+				StartedAt: run.StartedAt,
+				Succeeded: true,
+				Undone:    true,
+			})
+		}
+
+		summary := &summaries[i]
+		if run.User != "" {
+			summary.Users = append(summary.Users, run.User)
+		}
+		if run.FinishedAt.After(summary.FinishedAt) {
+			summary.FinishedAt = run.FinishedAt
+		}
+		summary.RecordsWritten += run.RecordsWritten
+		summary.Succeeded = summary.Succeeded && run.Succeeded
+		summary.Undone = summary.Undone && run.Undone
+	}
+
+	writeJSON(w, summaries)
+}
+
+// UndoCollectRun deletes every record written by a collect run, and rolls back the watermark of each user the run
+// collected successfully to the start of the run's range, such that the range is collected again by the next
+// incremental collect job. Records which the run found to have changed, and so replaced, are not restored until the
+// range is collected again.
+func (a *API) UndoCollectRun(w http.ResponseWriter, r *http.Request) {
+	if a.runs == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+	ctx := r.Context()
+	id := mux.Vars(r)["id"]
+
+	if job, err := a.jobs.Get(ctx, id); err == nil && job.Status == collect.Running {
+		log.Printf("cannot undo running collect job %s", id)
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	runs, err := a.runs.QueryRuns(ctx, time.Unix(0, 0).UTC(), a.now())
+	if err != nil && !errors.Is(err, storage.ErrNoResults) {
+		log.Printf("failed to query collect runs: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	undone := make([]storage.Run, 0)
+	for _, run := range runs {
+		if run.JobID == id {
+			undone = append(undone, run)
+		}
+	}
+	if len(undone) == 0 {
+		log.Printf("collect run not found: %s", id)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	// prevent collect jobs from writing to the watermarks while they are rolled back
+	if a.locker != nil {
+		owner := "undo-" + id
+		if err := a.locker.Lock(ctx, collect.LockName, owner, a.lockTTL); err != nil {
+			log.Printf("failed to acquire collect lock: %s", err)
+			if errors.Is(err, storage.ErrLocked) {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		defer func() {
+			if err := a.locker.Unlock(ctx, collect.LockName, owner); err != nil {
+				log.Printf("failed to release collect lock: %s", err)
+			}
+		}()
+	}
+
+	for i := range undone {
+		if err := a.undoRun(ctx, &undone[i]); err != nil {
+			log.Printf("failed to undo collect run %s for user '%s': %s", id, undone[i].User, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writeJSON(w, undone)
+}
+
+// undoRun deletes the records written by a user's run, rolls back their watermark if the run succeeded, and records
+// that the run has been undone. Runs which have already been undone are skipped.
+func (a *API) undoRun(ctx context.Context, run *storage.Run) error {
+	if run.Undone {
+		return nil
+	}
+	user := tenant.User{ID: run.User}
+
+	if run.RecordsWritten > 0 {
+		err := a.storer.Delete(tenant.NewContext(ctx, user),
+			storage.WithStartTime(run.FirstRecord),
+			storage.WithEndTime(run.LastRecord.Add(time.Nanosecond)),
+			storage.WithRun(run.JobID),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to delete records: %w", err)
+		}
+	}
+
+	if run.Succeeded {
+		watermark, err := a.readWatermark(ctx, user)
+		if err != nil {
+			return fmt.Errorf("failed to read collection watermark: %w", err)
+		}
+		if watermark.After(run.RangeStart) {
+			if err := a.runs.StoreWatermark(ctx, user.ID, run.RangeStart); err != nil {
+				return fmt.Errorf("failed to roll back collection watermark: %w", err)
+			}
+		}
+	}
+
+	run.Undone = true
+	if err := a.runs.StoreRun(ctx, *run); err != nil {
+		return fmt.Errorf("failed to store undone run: %w", err)
+	}
+	return nil
+}
+
+// historyRange parses the time range of a history query. The end time defaults to the present, and the start time to
+// the unix epoch, as the start of time cannot be represented by storage. Returns false if the range is invalid.
+func (a *API) historyRange(query url.Values) (time.Time, time.Time, bool) {
+	startTime, _ := time.Parse(time.RFC3339, query.Get("start_time"))
+	endTime, err := time.Parse(time.RFC3339, query.Get("end_time"))
+	if err != nil {
+		endTime = a.now()
+	}
+	if startTime.IsZero() {
+		startTime = time.Unix(0, 0).UTC()
+	}
+	return startTime, endTime, endTime.After(startTime)
+}
@@ -0,0 +1,313 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/jemgunay/canlendar-graph/storage"
+	"github.com/jemgunay/canlendar-graph/tenant"
+
+	mock_storage "github.com/jemgunay/canlendar-graph/storage/mocks"
+)
+
+func TestAPI_GetCollectHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	day := func(d int) time.Time {
+		return time.Date(2022, 8, d, 0, 0, 0, 0, time.UTC)
+	}
+	runs := []storage.Run{
+		{JobID: "1", User: "alice", StartedAt: day(2), RangeStart: day(1), RangeEnd: day(2), Succeeded: true},
+		{JobID: "1", User: "bob", StartedAt: day(2), RangeStart: day(1), RangeEnd: day(5), Succeeded: true},
+		{JobID: "2", User: "alice", StartedAt: day(3), RangeStart: day(2), RangeEnd: day(3), Failures: []string{"failed"}},
+		{JobID: "3", User: "alice", StartedAt: day(5), RangeStart: day(4), RangeEnd: day(5), Succeeded: true},
+	}
+
+	cases := []struct {
+		name       string
+		params     string
+		noStore    bool
+		status     int
+		runs       []string
+		gaps       []collectGap
+		watermarks []collectWatermark
+	}{
+		{
+			name:   "all_users",
+			status: http.StatusOK,
+			runs:   []string{"alice:1", "bob:1", "alice:2", "alice:3"},
+			gaps: []collectGap{
+				{User: "alice", Start: day(2), End: day(4)},
+			},
+			watermarks: []collectWatermark{
+				{User: "alice", Watermark: day(5)},
+			},
+		},
+		{
+			name:       "single_user",
+			params:     "?user=bob",
+			status:     http.StatusOK,
+			runs:       []string{"bob:1"},
+			gaps:       []collectGap{},
+			watermarks: []collectWatermark{},
+		},
+		{
+			name:   "unknown_user",
+			params: "?user=carol",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "invalid_range",
+			params: "?start_time=2022-08-05T00:00:00Z&end_time=2022-08-01T00:00:00Z",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:    "no_store",
+			noStore: true,
+			status:  http.StatusNotImplemented,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRuns := mock_storage.NewMockRunStore(ctrl)
+			mockRuns.EXPECT().QueryRuns(gomock.Any(), gomock.Any(), gomock.Any()).Return(runs, nil).AnyTimes()
+			mockRuns.EXPECT().ReadWatermark(gomock.Any(), "alice").Return(day(5), nil).AnyTimes()
+			mockRuns.EXPECT().ReadWatermark(gomock.Any(), "bob").Return(time.Time{}, storage.ErrNoResults).AnyTimes()
+
+			opts := []Option{WithUsers(newTestUsers(t))}
+			if !tt.noStore {
+				opts = append(opts, WithRunStore(mockRuns))
+			}
+			api := New(nil, nil, opts...)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/"+tt.params, nil)
+			api.GetCollectHistory(w, r)
+
+			// validate status
+			status := w.Result().StatusCode
+			if status != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			resp := collectHistory{}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %s", err)
+			}
+
+			respRuns := make([]string, 0, len(resp.Runs))
+			for _, run := range resp.Runs {
+				respRuns = append(respRuns, run.User+":"+run.JobID)
+			}
+			if !reflect.DeepEqual(respRuns, tt.runs) {
+				t.Fatalf("expected %v, got %v", tt.runs, respRuns)
+			}
+			if !reflect.DeepEqual(resp.Gaps, tt.gaps) {
+				t.Fatalf("expected %+v, got %+v", tt.gaps, resp.Gaps)
+			}
+			if !reflect.DeepEqual(resp.Watermarks, tt.watermarks) {
+				t.Fatalf("expected %+v, got %+v", tt.watermarks, resp.Watermarks)
+			}
+		})
+	}
+}
+
+func TestAPI_GetCollectRuns(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	start := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	mockRuns := mock_storage.NewMockRunStore(ctrl)
+	mockRuns.EXPECT().QueryRuns(gomock.Any(), gomock.Any(), gomock.Any()).Return([]storage.Run{
+		{JobID: "1", User: "alice", StartedAt: start, FinishedAt: start.Add(time.Minute), RecordsWritten: 2, Succeeded: true},
+		{JobID: "1", User: "bob", StartedAt: start, FinishedAt: start.Add(2 * time.Minute), RecordsWritten: 3},
+		{JobID: "2", User: "alice", StartedAt: start.Add(time.Hour), FinishedAt: start.Add(time.Hour), Succeeded: true, Undone: true},
+	}, nil)
+
+	api := New(nil, nil, WithUsers(newTestUsers(t)), WithRunStore(mockRuns))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	api.GetCollectRuns(w, r)
+
+	// validate status
+	status := w.Result().StatusCode
+	if status != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, status)
+	}
+
+	var runs []collectRun
+	if err := json.NewDecoder(w.Body).Decode(&runs); err != nil {
+		t.Fatalf("failed to decode response: %s", err)
+	}
+	expected := []collectRun{
+		{
+			JobID:          "1",
+			StartedAt:      start,
+			FinishedAt:     start.Add(2 * time.Minute),
+			Users:          []string{"alice", "bob"},
+			RecordsWritten: 5,
+		},
+		{
+			JobID:      "2",
+			StartedAt:  start.Add(time.Hour),
+			FinishedAt: start.Add(time.Hour),
+			Users:      []string{"alice"},
+			Succeeded:  true,
+			Undone:     true,
+		},
+	}
+	if !reflect.DeepEqual(runs, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, runs)
+	}
+}
+
+func TestAPI_UndoCollectRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	day := func(d int) time.Time {
+		return time.Date(2022, 8, d, 0, 0, 0, 0, time.UTC)
+	}
+	runs := []storage.Run{
+		{
+			JobID:          "written",
+			User:           "alice",
+			StartedAt:      day(5),
+			RangeStart:     day(2),
+			RangeEnd:       day(5),
+			RecordsWritten: 2,
+			FirstRecord:    day(2),
+			LastRecord:     day(4),
+			Succeeded:      true,
+		},
+		{
+			JobID:     "written",
+			User:      "bob",
+			StartedAt: day(5),
+			Failures:  []string{"failed"},
+		},
+		{
+			JobID:     "undone",
+			User:      "alice",
+			StartedAt: day(6),
+			Succeeded: true,
+			Undone:    true,
+		},
+	}
+
+	cases := []struct {
+		name    string
+		id      string
+		noStore bool
+		running bool
+		deletes bool
+		status  int
+	}{
+		{
+			name:    "undo",
+			id:      "written",
+			deletes: true,
+			status:  http.StatusOK,
+		},
+		{
+			name:   "already_undone",
+			id:     "undone",
+			status: http.StatusOK,
+		},
+		{
+			name:   "not_found",
+			id:     "unknown",
+			status: http.StatusNotFound,
+		},
+		{
+			name:    "running",
+			running: true,
+			status:  http.StatusConflict,
+		},
+		{
+			name:    "no_store",
+			id:      "written",
+			noStore: true,
+			status:  http.StatusNotImplemented,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorer := mock_storage.NewMockStorer(ctrl)
+			mockRuns := mock_storage.NewMockRunStore(ctrl)
+			mockRuns.EXPECT().QueryRuns(gomock.Any(), gomock.Any(), gomock.Any()).Return(runs, nil).AnyTimes()
+			var stored []storage.Run
+			if tt.deletes {
+				// only alice's records are deleted, as bob's run wrote none
+				mockStorer.EXPECT().Delete(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(ctx context.Context, options ...storage.QueryOption) error {
+						user, _ := tenant.FromContext(ctx)
+						q, err := storage.NewRecordQuery(options...)
+						if err != nil || q.User != "alice" || user.ID != "alice" || q.Run != "written" ||
+							!q.StartTime.Equal(day(2)) || !q.EndTime.Equal(day(4).Add(time.Nanosecond)) {
+							t.Fatalf("unexpected delete: %+v (%v)", q, err)
+						}
+						return nil
+					},
+				)
+				// alice's watermark is rolled back to the start of the run's range
+				mockRuns.EXPECT().ReadWatermark(gomock.Any(), "alice").Return(day(5), nil)
+				mockRuns.EXPECT().StoreWatermark(gomock.Any(), "alice", day(2)).Return(nil)
+				mockRuns.EXPECT().StoreRun(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ context.Context, run storage.Run) error {
+						stored = append(stored, run)
+						return nil
+					},
+				).Times(2)
+			}
+
+			opts := []Option{WithUsers(newTestUsers(t))}
+			if !tt.noStore {
+				opts = append(opts, WithRunStore(mockRuns))
+			}
+			api := New(mockStorer, nil, opts...)
+			id := tt.id
+			if tt.running {
+				run, err := api.jobs.Start()
+				if err != nil {
+					t.Fatalf("failed to start job: %s", err)
+				}
+				id = run.ID()
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodDelete, "/", nil)
+			r = mux.SetURLVars(r, map[string]string{"id": id})
+			api.UndoCollectRun(w, r)
+
+			// validate status
+			status := w.Result().StatusCode
+			if status != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, status)
+			}
+			if !tt.deletes {
+				return
+			}
+
+			for _, run := range stored {
+				if !run.Undone || run.JobID != "written" {
+					t.Fatalf("expected run to be stored as undone, got %+v", run)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/collect"
+	"github.com/jemgunay/canlendar-graph/reconcile"
+	"github.com/jemgunay/canlendar-graph/storage"
+	"github.com/jemgunay/canlendar-graph/tenant"
+)
+
+type reconcilePayload struct {
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Repair    bool      `json:"repair"`
+}
+
+// reconcileResult describes how a user's stored records differ from their calendar.
+type reconcileResult struct {
+	User       string              `json:"user,omitempty"`
+	Matched    int                 `json:"matched"`
+	Missing    []collectedRecord   `json:"missing"`
+	Extra      []collectedRecord   `json:"extra"`
+	Mismatched []reconcileMismatch `json:"mismatched"`
+	Repaired   bool                `json:"repaired"`
+	Failures   []string            `json:"failures,omitempty"`
+}
+
+type reconcileMismatch struct {
+	Calendar collectedRecord `json:"calendar"`
+	Stored   collectedRecord `json:"stored"`
+}
+
+// Reconcile compares the records derived from every user's calendar events within the time range (start_time and
+// end_time) against their stored records, reporting records which are missing from storage, extra records which are
+// no longer in the calendar, and records whose values differ. If repair is set, storage is brought in line with the
+// calendar; repaired records are not attributed to a collect run. The comparison can be restricted to a single user
+// with the user query parameter.
+func (a *API) Reconcile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	payload := reconcilePayload{}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		log.Printf("unable to decode request body: %s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if payload.StartTime.IsZero() || !payload.EndTime.After(payload.StartTime) {
+		log.Printf("invalid reconcile range provided: %s to %s", payload.StartTime, payload.EndTime)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	users := a.users.List()
+	if id := r.URL.Query().Get(UserParam); id != "" {
+		user, err := a.users.Get(id)
+		if err != nil {
+			log.Printf("failed to resolve user: %s", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		users = []tenant.User{user}
+	}
+
+	// prevent collect jobs from writing to storage while it is repaired
+	if payload.Repair && a.locker != nil {
+		owner := "reconcile-" + strconv.FormatInt(a.now().UnixNano(), 10)
+		if err := a.locker.Lock(ctx, collect.LockName, owner, a.lockTTL); err != nil {
+			log.Printf("failed to acquire collect lock: %s", err)
+			if errors.Is(err, storage.ErrLocked) {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		defer func() {
+			if err := a.locker.Unlock(ctx, collect.LockName, owner); err != nil {
+				log.Printf("failed to release collect lock: %s", err)
+			}
+		}()
+	}
+
+	results := make([]reconcileResult, 0, len(users))
+	for _, user := range users {
+		result := reconcileResult{User: user.ID}
+		if err := a.reconcileUser(tenant.NewContext(ctx, user), user, payload, &result); err != nil {
+			log.Printf("failed to reconcile user '%s': %s", user.ID, err)
+			result.Failures = append(result.Failures, err.Error())
+		}
+		results = append(results, result)
+	}
+
+	writeJSON(w, results)
+}
+
+// reconcileUser compares the user's calendar against their stored records, describing the differences in the result,
+// and repairs storage if requested. Storage is not repaired if any of the user's events could not be read, as the
+// stored records of those events would otherwise be deleted.
+func (a *API) reconcileUser(ctx context.Context, user tenant.User, payload reconcilePayload,
+	result *reconcileResult) error {
+
+	fetched, err := a.fetchRecords(ctx, user, payload.StartTime, payload.EndTime)
+	if err != nil {
+		return err
+	}
+	for _, err := range fetched.failures {
+		result.Failures = append(result.Failures, err.Error())
+	}
+
+	// events which start before the range are fetched if they end within it
+	calendarRecords := make([]storage.Record, 0, len(fetched.records))
+	for _, record := range fetched.records {
+		if !record.Time.Before(payload.StartTime) && record.Time.Before(payload.EndTime) {
+			calendarRecords = append(calendarRecords, record)
+		}
+	}
+
+	stored, err := a.storer.QueryRecords(ctx,
+		storage.WithStartTime(payload.StartTime),
+		storage.WithEndTime(payload.EndTime),
+	)
+	if err != nil && !errors.Is(err, storage.ErrNoResults) {
+		return fmt.Errorf("failed to query stored records: %w", err)
+	}
+
+	report := reconcile.Diff(calendarRecords, stored)
+	result.Matched = len(report.Matched)
+	result.Missing = toCollectedRecords(report.Missing)
+	result.Extra = toCollectedRecords(report.Extra)
+	result.Mismatched = make([]reconcileMismatch, 0, len(report.Mismatched))
+	for _, mismatch := range report.Mismatched {
+		result.Mismatched = append(result.Mismatched, reconcileMismatch{
+			Calendar: toCollectedRecord(mismatch.Calendar),
+			Stored:   toCollectedRecord(mismatch.Stored),
+		})
+	}
+
+	if !payload.Repair || report.InSync() {
+		return nil
+	}
+	if len(fetched.failures) > 0 {
+		return errors.New("storage was not repaired as some events could not be read")
+	}
+
+	deletions, writes := report.Repairs()
+	for _, deletion := range deletions {
+		err := a.storer.Delete(ctx,
+			storage.WithStartTime(deletion.Time),
+			storage.WithEndTime(deletion.Time.Add(time.Nanosecond)),
+			storage.WithEvent(deletion.Event),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to delete stored records: %w", err)
+		}
+	}
+	if len(writes) > 0 {
+		if err := a.storer.Store(ctx, writes...); err != nil {
+			return fmt.Errorf("failed to persist repaired records to storage: %w", err)
+		}
+	}
+	result.Repaired = true
+	return nil
+}
+
+// toCollectedRecords converts records into their JSON representation.
+func toCollectedRecords(records []storage.Record) []collectedRecord {
+	collected := make([]collectedRecord, 0, len(records))
+	for _, record := range records {
+		collected = append(collected, toCollectedRecord(record))
+	}
+	return collected
+}
+
+// toCollectedRecord converts a record into its JSON representation.
+func toCollectedRecord(record storage.Record) collectedRecord {
+	return collectedRecord{
+		Time:   record.Time,
+		Tags:   record.Tags,
+		Fields: record.Fields,
+	}
+}
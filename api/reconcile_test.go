@@ -0,0 +1,177 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jemgunay/canlendar-graph/calendar"
+	"github.com/jemgunay/canlendar-graph/storage"
+
+	mock_calendar "github.com/jemgunay/canlendar-graph/calendar/mocks"
+	mock_storage "github.com/jemgunay/canlendar-graph/storage/mocks"
+)
+
+func TestAPI_Reconcile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	day := func(d int) time.Time {
+		return time.Date(2022, 8, d, 0, 0, 0, 0, time.UTC)
+	}
+	stored := func(d int, event string, units float64, imputed string) storage.Record {
+		return storage.Record{
+			Time:   day(d),
+			Tags:   map[string]string{storage.ImputedTag: imputed, storage.EventTag: event, storage.RunTag: "run"},
+			Fields: map[string]interface{}{storage.UnitsField: units, storage.TimedField: false, storage.UTCOffsetField: int64(0)},
+		}
+	}
+
+	// the first event matches, the second has changed, the third is missing from storage and the fourth is imputed
+	// with different units, which still matches. The stored record on the fifth day is no longer in the calendar
+	events := []calendar.Event{
+		{ID: "first", Date: day(1), Units: 4},
+		{ID: "second", Date: day(2), Units: 8},
+		{ID: "third", Date: day(3), Units: 2},
+		{ID: "fourth", Date: day(4), Unknown: true},
+	}
+	storedRecords := []storage.Record{
+		stored(1, "first", 4, "false"),
+		stored(2, "second", 6, "false"),
+		stored(4, "fourth", 3, "true"),
+		stored(5, "fifth", 5, "false"),
+	}
+
+	cases := []struct {
+		name      string
+		body      string
+		readErr   bool
+		status    int
+		repaired  bool
+		deletions []time.Time
+		writes    []time.Time
+	}{
+		{
+			name:   "report",
+			body:   `{"start_time": "2022-08-01T00:00:00Z", "end_time": "2022-08-08T00:00:00Z"}`,
+			status: http.StatusOK,
+		},
+		{
+			name:      "repair",
+			body:      `{"start_time": "2022-08-01T00:00:00Z", "end_time": "2022-08-08T00:00:00Z", "repair": true}`,
+			status:    http.StatusOK,
+			repaired:  true,
+			deletions: []time.Time{day(2), day(5)},
+			writes:    []time.Time{day(2), day(3)},
+		},
+		{
+			name:    "repair_unreadable_event",
+			body:    `{"start_time": "2022-08-01T00:00:00Z", "end_time": "2022-08-08T00:00:00Z", "repair": true}`,
+			readErr: true,
+			status:  http.StatusOK,
+		},
+		{
+			name:   "invalid_range",
+			body:   `{"start_time": "2022-08-08T00:00:00Z", "end_time": "2022-08-01T00:00:00Z"}`,
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockIter := mock_calendar.NewMockEventIterator(ctrl)
+			mockIter.EXPECT().Count().Return(len(events)).AnyTimes()
+			mockIter.EXPECT().Pages().Return(1).AnyTimes()
+			var current int
+			mockIter.EXPECT().Next().DoAndReturn(
+				func() (calendar.Event, error) {
+					if current == len(events) {
+						if tt.readErr && current == len(events) {
+							current++
+							return calendar.Event{}, errors.New("invalid units")
+						}
+						return calendar.Event{}, calendar.ErrNoMoreEvents
+					}
+					if current > len(events) {
+						return calendar.Event{}, calendar.ErrNoMoreEvents
+					}
+					current++
+					return events[current-1], nil
+				},
+			).AnyTimes()
+
+			mockFetcher := mock_calendar.NewMockFetcher(ctrl)
+			mockFetcher.EXPECT().Fetch(gomock.Any(), day(1), day(8)).Return(mockIter, nil).AnyTimes()
+			mockCalendar := mock_calendar.NewMockProvider(ctrl)
+			mockCalendar.EXPECT().Fetcher(gomock.Any(), gomock.Any()).Return(mockFetcher, nil).AnyTimes()
+
+			mockStorer := mock_storage.NewMockStorer(ctrl)
+			mockStorer.EXPECT().QueryRecords(gomock.Any(), gomock.Any()).Return(storedRecords, nil).AnyTimes()
+			var deletions, writes []time.Time
+			mockStorer.EXPECT().Delete(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, options ...storage.QueryOption) error {
+					q, err := storage.NewRecordQuery(options...)
+					if err != nil || q.EndTime.Sub(q.StartTime) != time.Nanosecond || q.Event == "" {
+						t.Fatalf("unexpected delete: %+v (%v)", q, err)
+					}
+					deletions = append(deletions, q.StartTime)
+					return nil
+				},
+			).AnyTimes()
+			mockStorer.EXPECT().Store(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, records ...storage.Record) error {
+					for _, record := range records {
+						writes = append(writes, record.Time)
+					}
+					return nil
+				},
+			).AnyTimes()
+
+			api := New(mockStorer, mockCalendar)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(tt.body))
+			api.Reconcile(w, r)
+
+			// validate status
+			status := w.Result().StatusCode
+			if status != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, status)
+			}
+			if status != http.StatusOK {
+				return
+			}
+
+			var results []reconcileResult
+			if err := json.NewDecoder(w.Body).Decode(&results); err != nil {
+				t.Fatalf("failed to decode response: %s", err)
+			}
+			if len(results) != 1 {
+				t.Fatalf("expected %d, got %d", 1, len(results))
+			}
+			result := results[0]
+			if result.Matched != 2 || len(result.Missing) != 1 || len(result.Extra) != 1 || len(result.Mismatched) != 1 {
+				t.Fatalf("expected 2 matched, 1 missing, 1 extra and 1 mismatched, got %+v", result)
+			}
+			if !result.Missing[0].Time.Equal(day(3)) || !result.Extra[0].Time.Equal(day(5)) ||
+				result.Mismatched[0].Calendar.Fields[storage.UnitsField] != 8.0 ||
+				result.Mismatched[0].Stored.Fields[storage.UnitsField] != 6.0 {
+				t.Fatalf("unexpected differences: %+v", result)
+			}
+			if result.Repaired != tt.repaired || (tt.readErr && len(result.Failures) != 2) {
+				t.Fatalf("expected repaired to be %t, got %+v", tt.repaired, result)
+			}
+			if !reflect.DeepEqual(deletions, tt.deletions) || !reflect.DeepEqual(writes, tt.writes) {
+				t.Fatalf("expected deletions %v and writes %v, got %v and %v", tt.deletions, tt.writes, deletions,
+					writes)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/session"
+	"github.com/jemgunay/canlendar-graph/storage"
+	"github.com/jemgunay/canlendar-graph/units"
+)
+
+type sessionsResponse struct {
+	Sessions         []session.Session   `json:"sessions"`
+	Binges           []storage.Plot      `json:"binges"`
+	Aggregation      storage.Aggregation `json:"aggregation"`
+	BingeThreshold   float64             `json:"binge_threshold"`
+	GuidelineProfile string              `json:"guideline_profile"`
+	Unit             units.Unit          `json:"unit"`
+}
+
+// Sessions lists the drinking sessions within a time range, flagging binges according to the guideline profile, and
+// counts the binges within each aggregation period (defaults to week). The end time defaults to now, and the start time
+// to the first record in storage.
+func (a *API) Sessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	query := r.URL.Query()
+	startTime, _ := time.Parse(time.RFC3339, query.Get("start_time"))
+	endTime, err := time.Parse(time.RFC3339, query.Get("end_time"))
+	if err != nil {
+		endTime = a.now()
+	}
+
+	aggregation := storage.Week
+	if agg := query.Get("aggregation"); agg != "" {
+		aggregation = storage.Aggregation(agg)
+	}
+	if !aggregation.IsValid() {
+		log.Printf("unsupported aggregation: %s", aggregation)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	outputUnit, ok := parseUnit(query)
+	if !ok {
+		log.Printf("unsupported output unit: %s", outputUnit)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	profile, ok := a.profile(ctx, query)
+	if !ok {
+		log.Printf("unsupported guideline profile: %s", query.Get("guideline"))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp := sessionsResponse{
+		Sessions:         []session.Session{},
+		Binges:           []storage.Plot{},
+		Aggregation:      aggregation,
+		BingeThreshold:   outputUnit.FromUK(profile.BingeUnits()),
+		GuidelineProfile: profile.Name,
+		Unit:             outputUnit,
+	}
+
+	if startTime.IsZero() {
+		startTime, err = a.storer.ReadFirstTimestamp(ctx)
+		if errors.Is(err, storage.ErrNoResults) {
+			// there are no records to detect sessions from
+			writeJSON(w, resp)
+			return
+		}
+		if err != nil {
+			log.Printf("failed to read first timestamp from storage: %s", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	records, err := a.queryRecords(ctx,
+		storage.WithStartTime(startTime),
+		storage.WithEndTime(endTime),
+	)
+	if err != nil {
+		log.Printf("failed to query records from storage: %s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	sessions := a.sessions.Detect(records, profile.BingeUnits())
+	resp.Binges = session.CountBinges(sessions, aggregation, startTime, endTime)
+
+	// convert from UK units to the output unit
+	for _, s := range sessions {
+		s.Units = outputUnit.FromUK(s.Units)
+		resp.Sessions = append(resp.Sessions, s)
+	}
+
+	writeJSON(w, resp)
+}
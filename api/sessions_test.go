@@ -0,0 +1,103 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jemgunay/canlendar-graph/storage"
+
+	mock_storage "github.com/jemgunay/canlendar-graph/storage/mocks"
+)
+
+func TestAPI_Sessions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	record := func(t time.Time, units float64, timed bool) storage.Record {
+		return storage.Record{
+			Time: t,
+			Fields: map[string]interface{}{
+				storage.UnitsField:     units,
+				storage.TimedField:     timed,
+				storage.UTCOffsetField: int64(0),
+			},
+		}
+	}
+
+	mockStorer := mock_storage.NewMockStorer(ctrl)
+	mockStorer.EXPECT().QueryRecords(gomock.Any(), gomock.Any()).Return([]storage.Record{
+		record(time.Date(2022, 8, 5, 19, 0, 0, 0, time.UTC), 3, true),
+		record(time.Date(2022, 8, 5, 20, 30, 0, 0, time.UTC), 3, true),
+		record(time.Date(2022, 8, 5, 22, 0, 0, 0, time.UTC), 3, true),
+		// before the drinking day cutoff, so part of the previous session
+		record(time.Date(2022, 8, 6, 1, 0, 0, 0, time.UTC), 1, true),
+		record(time.Date(2022, 8, 6, 20, 0, 0, 0, time.UTC), 2, true),
+		record(time.Date(2022, 8, 10, 0, 0, 0, 0, time.UTC), 5, false),
+	}, nil)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/?start_time=2022-08-01T00:00:00Z&end_time=2022-08-15T00:00:00Z", nil)
+
+	api := New(mockStorer, nil)
+	api.Sessions(w, r)
+
+	// validate status
+	status := w.Result().StatusCode
+	if status != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, status)
+	}
+
+	respBody, err := io.ReadAll(w.Result().Body)
+	if err != nil {
+		t.Fatalf("failed to read resp body: %s", err)
+	}
+
+	expected := `{"sessions":[` +
+		`{"start":"2022-08-05T19:00:00Z","end":"2022-08-06T01:00:00Z","drinking_day":"2022-08-05","events":4,"units":10,"duration_minutes":360,"timed":true,"binge":true},` +
+		`{"start":"2022-08-06T20:00:00Z","end":"2022-08-06T20:00:00Z","drinking_day":"2022-08-06","events":1,"units":2,"duration_minutes":0,"timed":true,"binge":false},` +
+		`{"start":"2022-08-10T00:00:00Z","end":"2022-08-10T00:00:00Z","drinking_day":"2022-08-10","events":1,"units":5,"duration_minutes":0,"timed":false,"binge":false}],` +
+		`"binges":[{"t":1659312000000,"y":1},{"t":1659916800000,"y":0}],` +
+		`"aggregation":"week","binge_threshold":6,"guideline_profile":"uk","unit":"uk_units"}`
+	respBody = bytes.TrimSpace(respBody)
+	if string(respBody) != expected {
+		t.Fatalf("expected '%s', got '%s'", expected, respBody)
+	}
+}
+
+func TestAPI_Sessions_NoRecords(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// without a start time or any records, there are no periods to count binges in
+	mockStorer := mock_storage.NewMockStorer(ctrl)
+	mockStorer.EXPECT().ReadFirstTimestamp(gomock.Any(), gomock.Any()).Return(time.Time{}, storage.ErrNoResults)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	api := New(mockStorer, nil)
+	api.Sessions(w, r)
+
+	// validate status
+	status := w.Result().StatusCode
+	if status != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, status)
+	}
+
+	respBody, err := io.ReadAll(w.Result().Body)
+	if err != nil {
+		t.Fatalf("failed to read resp body: %s", err)
+	}
+
+	expected := `{"sessions":[],"binges":[],"aggregation":"week","binge_threshold":6,"guideline_profile":"uk",` +
+		`"unit":"uk_units"}`
+	respBody = bytes.TrimSpace(respBody)
+	if string(respBody) != expected {
+		t.Fatalf("expected '%s', got '%s'", expected, respBody)
+	}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/auth"
+	"github.com/jemgunay/canlendar-graph/storage"
+)
+
+// defaultShareTTL is how long a share link is valid for if no expiry is provided.
+const defaultShareTTL = 7 * 24 * time.Hour
+
+type sharePayload struct {
+	Aggregation storage.Aggregation `json:"aggregation"`
+	StartTime   time.Time           `json:"start_time"`
+	EndTime     time.Time           `json:"end_time"`
+	// ExpiresIn is a duration string, e.g. "72h".
+	ExpiresIn string `json:"expires_in"`
+}
+
+type shareResponse struct {
+	Token       string              `json:"token"`
+	Path        string              `json:"path"`
+	User        string              `json:"user,omitempty"`
+	Aggregation storage.Aggregation `json:"aggregation"`
+	StartTime   time.Time           `json:"start_time"`
+	EndTime     time.Time           `json:"end_time"`
+	ExpiresAt   time.Time           `json:"expires_at"`
+}
+
+// CreateShare mints an expiring, read-only share link scoped to an aggregation and date range. The end time defaults to
+// now and the link expires after 7 days (or the maximum expiry, if shorter) unless an expiry is provided.
+func (a *API) CreateShare(w http.ResponseWriter, r *http.Request) {
+	if a.shares == nil {
+		log.Printf("no share signer configured")
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	payload := sharePayload{}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		log.Printf("unable to decode request body: %s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if !payload.Aggregation.IsValid() {
+		log.Printf("unsupported aggregation provided: %s", payload.Aggregation)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	now := a.now()
+	if payload.EndTime.IsZero() {
+		payload.EndTime = now
+	}
+	if payload.StartTime.IsZero() || !payload.StartTime.Before(payload.EndTime) {
+		log.Printf("invalid share range provided: %s to %s", payload.StartTime, payload.EndTime)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	ttl := defaultShareTTL
+	if ttl > a.shares.MaxTTL() {
+		ttl = a.shares.MaxTTL()
+	}
+	if payload.ExpiresIn != "" {
+		var err error
+		if ttl, err = time.ParseDuration(payload.ExpiresIn); err != nil {
+			log.Printf("invalid expires_in provided: %s", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	if ttl <= 0 || ttl > a.shares.MaxTTL() {
+		log.Printf("share expiry must be between 0 and %s: %s", a.shares.MaxTTL(), ttl)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	user, err := currentUser(r.Context(), a.users)
+	if err != nil {
+		log.Printf("failed to resolve user: %s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	share := auth.Share{
+		User:        user.ID,
+		Aggregation: payload.Aggregation.String(),
+		Start:       payload.StartTime.UTC(),
+		End:         payload.EndTime.UTC(),
+		Expiry:      now.Add(ttl).UTC().Truncate(time.Second),
+	}
+	token, err := a.shares.Sign(share)
+	if err != nil {
+		log.Printf("failed to sign share: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	resp := shareResponse{
+		Token:       token,
+		Path:        "/?" + url.Values{auth.ShareParam: {token}}.Encode(),
+		User:        share.User,
+		Aggregation: payload.Aggregation,
+		StartTime:   share.Start,
+		EndTime:     share.End,
+		ExpiresAt:   share.Expiry,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, resp)
+}
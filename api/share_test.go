@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/auth"
+	"github.com/jemgunay/canlendar-graph/config"
+)
+
+func TestAPI_CreateShare(t *testing.T) {
+	signer, err := auth.NewShareSigner(config.Share{
+		Secret:      "0123456789abcdef0123456789abcdef",
+		MaxTTLHours: 720,
+	})
+	if err != nil {
+		t.Fatalf("failed to create share signer: %s", err)
+	}
+
+	// share tokens are verified against the current time
+	now := time.Now().UTC().Truncate(time.Second)
+
+	cases := []struct {
+		name    string
+		signer  *auth.ShareSigner
+		reqBody string
+		status  int
+		share   auth.Share
+	}{
+		{
+			name:    "success",
+			signer:  signer,
+			reqBody: `{"aggregation":"week","start_time":"2022-06-01T00:00:00Z","end_time":"2022-08-01T00:00:00Z","expires_in":"48h"}`,
+			status:  http.StatusCreated,
+			share: auth.Share{
+				Aggregation: "week",
+				Start:       time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC),
+				End:         time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC),
+				Expiry:      now.Add(48 * time.Hour),
+			},
+		},
+		{
+			name:    "default_end_and_expiry",
+			signer:  signer,
+			reqBody: `{"aggregation":"month","start_time":"2022-01-01T00:00:00Z"}`,
+			status:  http.StatusCreated,
+			share: auth.Share{
+				Aggregation: "month",
+				Start:       time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+				End:         now,
+				Expiry:      now.Add(defaultShareTTL),
+			},
+		},
+		{
+			name:    "expiry_exceeds_max",
+			signer:  signer,
+			reqBody: `{"aggregation":"week","start_time":"2022-06-01T00:00:00Z","expires_in":"721h"}`,
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:    "invalid_aggregation",
+			signer:  signer,
+			reqBody: `{"aggregation":"decade","start_time":"2022-06-01T00:00:00Z","expires_in":"1h"}`,
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:    "missing_start",
+			signer:  signer,
+			reqBody: `{"aggregation":"week","expires_in":"1h"}`,
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:    "no_signer",
+			reqBody: `{"aggregation":"week","start_time":"2022-06-01T00:00:00Z"}`,
+			status:  http.StatusNotImplemented,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/share", strings.NewReader(tt.reqBody))
+
+			api := New(nil, nil, WithShareSigner(tt.signer))
+			api.now = func() time.Time { return now }
+			api.CreateShare(w, r)
+
+			// validate status
+			status := w.Result().StatusCode
+			if status != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, status)
+			}
+			if tt.status != http.StatusCreated {
+				return
+			}
+
+			resp := shareResponse{}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %s", err)
+			}
+			if resp.Path != "/?share="+resp.Token {
+				t.Fatalf("unexpected share path: %s", resp.Path)
+			}
+
+			share, err := tt.signer.Verify(resp.Token)
+			if err != nil {
+				t.Fatalf("failed to verify share token: %s", err)
+			}
+			if !share.Start.Equal(tt.share.Start) || !share.End.Equal(tt.share.End) ||
+				!share.Expiry.Equal(tt.share.Expiry) || share.Aggregation != tt.share.Aggregation {
+				t.Fatalf("expected '%+v', got '%+v'", tt.share, share)
+			}
+		})
+	}
+}
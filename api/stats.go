@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/guideline"
+	"github.com/jemgunay/canlendar-graph/stats"
+	"github.com/jemgunay/canlendar-graph/storage"
+	"github.com/jemgunay/canlendar-graph/units"
+)
+
+type statsResponse struct {
+	stats.Summary
+	WeeksByTier      *guideline.TierSummary `json:"weeks_by_tier,omitempty"`
+	MonthsByTier     *guideline.TierSummary `json:"months_by_tier,omitempty"`
+	GuidelineProfile string                 `json:"guideline_profile"`
+	Unit             units.Unit             `json:"unit"`
+}
+
+// Stats summarises the units consumed within a time range.
+func (a *API) Stats(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	query := r.URL.Query()
+	startTime, _ := time.Parse(time.RFC3339, query.Get("start_time"))
+	endTime, _ := time.Parse(time.RFC3339, query.Get("end_time"))
+
+	outputUnit, ok := parseUnit(query)
+	if !ok {
+		log.Printf("unsupported output unit: %s", outputUnit)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	profile, ok := a.profile(ctx, query)
+	if !ok {
+		log.Printf("unsupported guideline profile: %s", query.Get("guideline"))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// query each aggregation required to summarise the time range
+	plots := make(map[storage.Aggregation][]storage.Plot, 3)
+	for _, aggregation := range []storage.Aggregation{storage.Day, storage.Week, storage.Month} {
+		var err error
+		plots[aggregation], err = a.queryPlots(ctx,
+			storage.WithAggregation(aggregation),
+			storage.WithStartTime(startTime),
+			storage.WithEndTime(endTime),
+		)
+		if err != nil {
+			log.Printf("failed to query storage: %s", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	entries, err := a.queryEntries(ctx, startTime, endTime)
+	if err != nil {
+		log.Printf("failed to query entry counts from storage: %s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	imputed, err := a.queryImputedEntries(ctx, startTime, endTime)
+	if err != nil {
+		log.Printf("failed to query imputed entry counts from storage: %s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	summary := stats.Summarise(
+		outputUnit.FromUKPlots(plots[storage.Day]),
+		entries,
+		imputed,
+		outputUnit.FromUKPlots(plots[storage.Week]),
+		outputUnit.FromUKPlots(plots[storage.Month]),
+		outputUnit.FromUK(profile.WeeklyUnits()),
+	)
+
+	writeJSON(w, statsResponse{
+		Summary:          summary,
+		WeeksByTier:      guideline.SummariseTiers(profile.Classify(storage.Week, plots[storage.Week])),
+		MonthsByTier:     guideline.SummariseTiers(profile.Classify(storage.Month, plots[storage.Month])),
+		GuidelineProfile: profile.Name,
+		Unit:             outputUnit,
+	})
+}
+
+// queryPlots queries storage for plots, treating no results as an empty set of plots.
+func (a *API) queryPlots(ctx context.Context, opts ...storage.QueryOption) ([]storage.Plot, error) {
+	plots, err := a.storer.Query(ctx, opts...)
+	if err != nil && !errors.Is(err, storage.ErrNoResults) {
+		return nil, err
+	}
+	return plots, nil
+}
+
+// queryEntries queries storage for the number of logged entries per day if days with no logged entries are to be
+// treated as unknown. Returns nil if days with no logged entries are to be assumed dry.
+func (a *API) queryEntries(ctx context.Context, startTime, endTime time.Time) ([]storage.Plot, error) {
+	if a.unlogged != stats.Unknown {
+		return nil, nil
+	}
+
+	entries, err := a.queryPlots(ctx,
+		storage.WithAggregation(storage.Day),
+		storage.WithAggregateFunc(storage.Count),
+		storage.WithStartTime(startTime),
+		storage.WithEndTime(endTime),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// no results means no days were logged
+	if entries == nil {
+		entries = []storage.Plot{}
+	}
+	return entries, nil
+}
+
+// queryImputedEntries queries storage for the number of imputed entries per day, such that days with unknown entries
+// are not mistaken for dry days when their entries were excluded.
+func (a *API) queryImputedEntries(ctx context.Context, startTime, endTime time.Time) ([]storage.Plot, error) {
+	return a.queryPlots(ctx,
+		storage.WithAggregation(storage.Day),
+		storage.WithAggregateFunc(storage.Count),
+		storage.WithImputed(true),
+		storage.WithStartTime(startTime),
+		storage.WithEndTime(endTime),
+	)
+}
+
+// queryRecords queries storage for raw records, treating no results as an empty set of records.
+func (a *API) queryRecords(ctx context.Context, opts ...storage.QueryOption) ([]storage.Record, error) {
+	records, err := a.storer.QueryRecords(ctx, opts...)
+	if err != nil && !errors.Is(err, storage.ErrNoResults) {
+		return nil, err
+	}
+	return records, nil
+}
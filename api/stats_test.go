@@ -0,0 +1,93 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jemgunay/canlendar-graph/storage"
+
+	mock_storage "github.com/jemgunay/canlendar-graph/storage/mocks"
+)
+
+func TestAPI_Stats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	start := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	day := func(n int) int64 {
+		return start.AddDate(0, 0, n).UnixMilli()
+	}
+
+	plots := map[storage.Aggregation][]storage.Plot{
+		storage.Day: {
+			{X: day(0), Y: 4}, {X: day(1), Y: 0}, {X: day(2), Y: 0}, {X: day(3), Y: 0}, {X: day(4), Y: 10},
+			{X: day(5), Y: 6}, {X: day(6), Y: 0}, {X: day(7), Y: 0}, {X: day(8), Y: 2}, {X: day(9), Y: 0},
+			{X: day(10), Y: 0}, {X: day(11), Y: 0}, {X: day(12), Y: 0}, {X: day(13), Y: 2},
+		},
+		storage.Week: {
+			{X: day(0), Y: 20}, {X: day(7), Y: 4},
+		},
+		storage.Month: {
+			{X: day(0), Y: 24},
+		},
+	}
+
+	// the tenth day's only entry was unknown and excluded, so it has no units but is not a dry day
+	imputed := []storage.Plot{
+		{X: day(9), Y: 1},
+	}
+
+	mockStorer := mock_storage.NewMockStorer(ctrl)
+	mockStorer.EXPECT().Query(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, opts ...storage.QueryOption) ([]storage.Plot, error) {
+			q, err := storage.NewQuery(opts...)
+			if err != nil {
+				return nil, err
+			}
+			if q.AggregateFunc == storage.Count {
+				if q.Imputed == nil || !*q.Imputed {
+					t.Fatalf("unexpected entry count query: %+v", q)
+				}
+				return imputed, nil
+			}
+			return plots[q.Aggregation], nil
+		},
+	).Times(4)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/?start_time=2022-08-01T00:00:00Z&end_time=2022-08-15T00:00:00Z", nil)
+
+	api := New(mockStorer, nil)
+	api.Stats(w, r)
+
+	// validate status
+	status := w.Result().StatusCode
+	if status != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, status)
+	}
+
+	respBody, err := io.ReadAll(w.Result().Body)
+	if err != nil {
+		t.Fatalf("failed to read resp body: %s", err)
+	}
+
+	expected := `{"total_units":24,"mean_per_week":12,"mean_per_drinking_day":4.8,"days":14,"drinking_days":5,` +
+		`"dry_days":8,"unknown_days":1,"dry_days_percentage":57.14285714285714,` +
+		`"heaviest_day":{"start":"2022-08-05T00:00:00Z","units":10},` +
+		`"heaviest_week":{"start":"2022-08-01T00:00:00Z","units":20},` +
+		`"heaviest_month":{"start":"2022-08-01T00:00:00Z","units":24},` +
+		`"weeks_over_guideline":1,"weeks_over_guideline_percentage":50,` +
+		`"longest_dry_streak":{"start":"2022-08-02T00:00:00Z","end":"2022-08-04T00:00:00Z","days":3},` +
+		`"weeks_by_tier":{"lower":1,"increasing":1,"higher":0},"months_by_tier":{"lower":1,"increasing":0,"higher":0},` +
+		`"guideline_profile":"uk","unit":"uk_units"}`
+	respBody = bytes.TrimSpace(respBody)
+	if string(respBody) != expected {
+		t.Fatalf("expected '%s', got '%s'", expected, respBody)
+	}
+}
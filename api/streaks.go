@@ -0,0 +1,90 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/stats"
+	"github.com/jemgunay/canlendar-graph/storage"
+)
+
+type streaksResponse struct {
+	Current *stats.Streak  `json:"current,omitempty"`
+	Longest *stats.Streak  `json:"longest,omitempty"`
+	Streaks []stats.Streak `json:"streaks"`
+	Policy  stats.Policy   `json:"policy"`
+}
+
+// Streaks returns the current dry streak, the longest dry streak and every dry streak of at least min_days (defaults
+// to 1) within a time range. The end time defaults to now, and the start time to the first record in storage.
+func (a *API) Streaks(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	query := r.URL.Query()
+	startTime, _ := time.Parse(time.RFC3339, query.Get("start_time"))
+	endTime, err := time.Parse(time.RFC3339, query.Get("end_time"))
+	if err != nil {
+		endTime = time.Now()
+	}
+
+	minDays := 1
+	if m := query.Get("min_days"); m != "" {
+		if minDays, err = strconv.Atoi(m); err != nil || minDays < 1 {
+			log.Printf("invalid min_days provided: %s", m)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	days, err := a.queryPlots(ctx,
+		storage.WithAggregation(storage.Day),
+		storage.WithStartTime(startTime),
+		storage.WithEndTime(endTime),
+	)
+	if err != nil {
+		log.Printf("failed to query storage: %s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	entries, err := a.queryEntries(ctx, startTime, endTime)
+	if err != nil {
+		log.Printf("failed to query entry counts from storage: %s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	imputed, err := a.queryImputedEntries(ctx, startTime, endTime)
+	if err != nil {
+		log.Printf("failed to query imputed entry counts from storage: %s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp := streaksResponse{
+		Streaks: []stats.Streak{},
+		Policy:  a.unlogged,
+	}
+
+	// the current streak is the streak which includes the final day of the range, regardless of its length
+	all := stats.DryStreaks(days, entries, imputed, 1)
+	if len(all) > 0 {
+		longest := stats.Longest(all)
+		resp.Longest = &longest
+
+		last := all[len(all)-1]
+		if len(days) > 0 && last.End.Equal(time.UnixMilli(days[len(days)-1].X).UTC()) {
+			resp.Current = &last
+		}
+	}
+
+	for _, streak := range all {
+		if streak.Days >= minDays {
+			resp.Streaks = append(resp.Streaks, streak)
+		}
+	}
+
+	writeJSON(w, resp)
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jemgunay/canlendar-graph/stats"
+	"github.com/jemgunay/canlendar-graph/storage"
+
+	mock_storage "github.com/jemgunay/canlendar-graph/storage/mocks"
+)
+
+func TestAPI_Streaks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	start := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	day := func(n int) int64 {
+		return start.AddDate(0, 0, n).UnixMilli()
+	}
+
+	units := []storage.Plot{
+		{X: day(0), Y: 4}, {X: day(1), Y: 0}, {X: day(2), Y: 0}, {X: day(3), Y: 0}, {X: day(4), Y: 2},
+		{X: day(5), Y: 0}, {X: day(6), Y: 0},
+	}
+	// the third day has no logged entries
+	entries := []storage.Plot{
+		{X: day(0), Y: 1}, {X: day(1), Y: 1}, {X: day(2), Y: 0}, {X: day(3), Y: 1}, {X: day(4), Y: 1},
+		{X: day(5), Y: 1}, {X: day(6), Y: 1},
+	}
+
+	cases := []struct {
+		name     string
+		policy   stats.Policy
+		params   string
+		imputed  []storage.Plot
+		status   int
+		respBody string
+	}{
+		{
+			name:   "assume_dry",
+			policy: stats.AssumeDry,
+			status: http.StatusOK,
+			respBody: `{"current":{"start":"2022-08-06T00:00:00Z","end":"2022-08-07T00:00:00Z","days":2},` +
+				`"longest":{"start":"2022-08-02T00:00:00Z","end":"2022-08-04T00:00:00Z","days":3},` +
+				`"streaks":[{"start":"2022-08-02T00:00:00Z","end":"2022-08-04T00:00:00Z","days":3},` +
+				`{"start":"2022-08-06T00:00:00Z","end":"2022-08-07T00:00:00Z","days":2}],"policy":"dry"}`,
+		},
+		{
+			name:   "assume_dry_min_days",
+			policy: stats.AssumeDry,
+			params: "&min_days=3",
+			status: http.StatusOK,
+			respBody: `{"current":{"start":"2022-08-06T00:00:00Z","end":"2022-08-07T00:00:00Z","days":2},` +
+				`"longest":{"start":"2022-08-02T00:00:00Z","end":"2022-08-04T00:00:00Z","days":3},` +
+				`"streaks":[{"start":"2022-08-02T00:00:00Z","end":"2022-08-04T00:00:00Z","days":3}],"policy":"dry"}`,
+		},
+		{
+			name:   "unknown",
+			policy: stats.Unknown,
+			status: http.StatusOK,
+			respBody: `{"current":{"start":"2022-08-06T00:00:00Z","end":"2022-08-07T00:00:00Z","days":2},` +
+				`"longest":{"start":"2022-08-06T00:00:00Z","end":"2022-08-07T00:00:00Z","days":2},` +
+				`"streaks":[{"start":"2022-08-02T00:00:00Z","end":"2022-08-02T00:00:00Z","days":1},` +
+				`{"start":"2022-08-04T00:00:00Z","end":"2022-08-04T00:00:00Z","days":1},` +
+				`{"start":"2022-08-06T00:00:00Z","end":"2022-08-07T00:00:00Z","days":2}],"policy":"unknown"}`,
+		},
+		{
+			name:   "excluded_imputed",
+			policy: stats.AssumeDry,
+			// the final day's only entry was unknown and excluded, so it has no units but is not a dry day
+			imputed: []storage.Plot{{X: day(6), Y: 1}},
+			status:  http.StatusOK,
+			respBody: `{"longest":{"start":"2022-08-02T00:00:00Z","end":"2022-08-04T00:00:00Z","days":3},` +
+				`"streaks":[{"start":"2022-08-02T00:00:00Z","end":"2022-08-04T00:00:00Z","days":3},` +
+				`{"start":"2022-08-06T00:00:00Z","end":"2022-08-06T00:00:00Z","days":1}],"policy":"dry"}`,
+		},
+		{
+			name:   "invalid_min_days",
+			policy: stats.AssumeDry,
+			params: "&min_days=0",
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorer := mock_storage.NewMockStorer(ctrl)
+			mockStorer.EXPECT().Query(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(ctx context.Context, opts ...storage.QueryOption) ([]storage.Plot, error) {
+					q, err := storage.NewQuery(opts...)
+					if err != nil {
+						return nil, err
+					}
+					switch {
+					case q.AggregateFunc == storage.Count && q.Imputed != nil && *q.Imputed:
+						return tt.imputed, nil
+					case q.AggregateFunc == storage.Count:
+						return entries, nil
+					}
+					return units, nil
+				},
+			).AnyTimes()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/?end_time=2022-08-08T00:00:00Z"+tt.params, nil)
+
+			api := New(mockStorer, nil, WithUnloggedPolicy(tt.policy))
+			api.Streaks(w, r)
+
+			// validate status
+			status := w.Result().StatusCode
+			if status != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, status)
+			}
+
+			respBody, err := io.ReadAll(w.Result().Body)
+			if err != nil {
+				t.Fatalf("failed to read resp body: %s", err)
+			}
+
+			respBody = bytes.TrimSpace(respBody)
+			if string(respBody) != tt.respBody {
+				t.Fatalf("expected '%s', got '%s'", tt.respBody, respBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/stats"
+	"github.com/jemgunay/canlendar-graph/storage"
+	"github.com/jemgunay/canlendar-graph/taper"
+)
+
+// defaultBaselineWeeks is the number of complete weeks before the plan starts which are averaged to determine the
+// baseline if none is provided.
+const defaultBaselineWeeks = 4
+
+type taperPayload struct {
+	Start         time.Time  `json:"start"`
+	Baseline      float64    `json:"baseline"`
+	BaselineWeeks int        `json:"baseline_weeks"`
+	Target        float64    `json:"target"`
+	Weeks         int        `json:"weeks"`
+	Step          taper.Step `json:"step"`
+}
+
+type taperResponse struct {
+	taper.Plan
+	Targets   []storage.Plot   `json:"targets"`
+	Adherence *taper.Adherence `json:"adherence,omitempty"`
+}
+
+// CreateTaperPlan generates a weekly reduction plan from the recent weekly average down to a target, replacing any
+// existing plan. The plan starts in the current week unless a start is provided, and the baseline is averaged over the
+// complete weeks before the plan starts unless a baseline is provided.
+func (a *API) CreateTaperPlan(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if a.taper == nil {
+		log.Printf("no tapering plan store configured")
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	payload := taperPayload{
+		BaselineWeeks: defaultBaselineWeeks,
+		Step:          taper.Linear,
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		log.Printf("unable to decode request body: %s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if payload.Start.IsZero() {
+		payload.Start = a.now()
+	}
+	plan := taper.Plan{
+		Start:    storage.Week.PeriodStart(payload.Start),
+		Baseline: payload.Baseline,
+		Target:   payload.Target,
+		Weeks:    payload.Weeks,
+		Step:     payload.Step,
+	}
+
+	if plan.Baseline == 0 {
+		if payload.BaselineWeeks < 1 {
+			log.Printf("invalid baseline_weeks provided: %d", payload.BaselineWeeks)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		weeks, err := a.queryPlots(ctx,
+			storage.WithAggregation(storage.Week),
+			storage.WithStartTime(plan.Start.AddDate(0, 0, -7*payload.BaselineWeeks)),
+			storage.WithEndTime(plan.Start),
+		)
+		if err != nil {
+			log.Printf("failed to query baseline from storage: %s", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		// weeks missing from storage had no units
+		plan.Baseline = stats.Total(weeks) / float64(payload.BaselineWeeks)
+	}
+
+	if err := plan.Validate(); err != nil {
+		log.Printf("invalid tapering plan: %s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := a.taper.Save(ctx, plan); err != nil {
+		log.Printf("failed to store tapering plan: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, taperResponse{
+		Plan:    plan,
+		Targets: plan.Targets(),
+	})
+}
+
+// GetTaperPlan gets the current tapering plan along with the week by week adherence to it.
+func (a *API) GetTaperPlan(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if a.taper == nil {
+		log.Printf("no tapering plan store configured")
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	plan, err := a.taper.Get(ctx)
+	if err != nil {
+		writeTaperStoreError(w, err)
+		return
+	}
+
+	var weeks []storage.Plot
+	now := a.now()
+	if !plan.Start.After(now) {
+		weeks, err = a.queryPlots(ctx,
+			storage.WithAggregation(storage.Week),
+			storage.WithStartTime(plan.Start),
+			storage.WithEndTime(plan.End()),
+		)
+		if err != nil {
+			log.Printf("failed to query storage: %s", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	adherence := plan.Adherence(weeks, now)
+	writeJSON(w, taperResponse{
+		Plan:      plan,
+		Targets:   plan.Targets(),
+		Adherence: &adherence,
+	})
+}
+
+// DeleteTaperPlan deletes the current tapering plan.
+func (a *API) DeleteTaperPlan(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if a.taper == nil {
+		log.Printf("no tapering plan store configured")
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	if err := a.taper.Delete(ctx); err != nil {
+		writeTaperStoreError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeTaperStoreError writes the status for a tapering plan store error.
+func writeTaperStoreError(w http.ResponseWriter, err error) {
+	if errors.Is(err, taper.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	log.Printf("tapering plan store request failed: %s", err)
+	w.WriteHeader(http.StatusInternalServerError)
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jemgunay/canlendar-graph/storage"
+	"github.com/jemgunay/canlendar-graph/taper"
+
+	mock_storage "github.com/jemgunay/canlendar-graph/storage/mocks"
+	mock_taper "github.com/jemgunay/canlendar-graph/taper/mocks"
+)
+
+func TestAPI_CreateTaperPlan(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// a Wednesday
+	now := time.Date(2022, 8, 17, 12, 0, 0, 0, time.UTC)
+	start := time.Date(2022, 8, 15, 0, 0, 0, 0, time.UTC)
+	week := func(n int) int64 {
+		return start.AddDate(0, 0, 7*n).UnixMilli()
+	}
+
+	// averages 20 units a week, where one week had no units
+	baseline := []storage.Plot{{X: week(-4), Y: 30}, {X: week(-3), Y: 25}, {X: week(-1), Y: 25}}
+
+	cases := []struct {
+		name     string
+		reqBody  string
+		query    bool
+		saved    *taper.Plan
+		status   int
+		respBody string
+	}{
+		{
+			name:    "linear",
+			reqBody: `{"target":10,"weeks":5}`,
+			query:   true,
+			saved:   &taper.Plan{Start: start, Baseline: 20, Target: 10, Weeks: 5, Step: taper.Linear},
+			status:  http.StatusCreated,
+			respBody: `{"start":"2022-08-15T00:00:00Z","baseline":20,"target":10,"weeks":5,"step":"linear",` +
+				`"targets":[{"t":1660521600000,"y":18},{"t":1661126400000,"y":16},{"t":1661731200000,"y":14},` +
+				`{"t":1662336000000,"y":12},{"t":1662940800000,"y":10}]}`,
+		},
+		{
+			name:    "percentage",
+			reqBody: `{"target":10,"weeks":2,"step":"percentage"}`,
+			query:   true,
+			saved:   &taper.Plan{Start: start, Baseline: 20, Target: 10, Weeks: 2, Step: taper.Percentage},
+			status:  http.StatusCreated,
+			respBody: `{"start":"2022-08-15T00:00:00Z","baseline":20,"target":10,"weeks":2,"step":"percentage",` +
+				`"targets":[{"t":1660521600000,"y":14.142135623730951},{"t":1661126400000,"y":10}]}`,
+		},
+		{
+			name:    "provided_baseline_and_start",
+			reqBody: `{"start":"2022-08-24T00:00:00Z","baseline":30,"target":0,"weeks":3}`,
+			saved:   &taper.Plan{Start: start.AddDate(0, 0, 7), Baseline: 30, Target: 0, Weeks: 3, Step: taper.Linear},
+			status:  http.StatusCreated,
+			respBody: `{"start":"2022-08-22T00:00:00Z","baseline":30,"target":0,"weeks":3,"step":"linear",` +
+				`"targets":[{"t":1661126400000,"y":20},{"t":1661731200000,"y":10},{"t":1662336000000,"y":0}]}`,
+		},
+		{
+			name:    "target_above_baseline",
+			reqBody: `{"target":25,"weeks":5}`,
+			query:   true,
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:    "percentage_to_zero",
+			reqBody: `{"target":0,"weeks":5,"step":"percentage"}`,
+			query:   true,
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:    "no_weeks",
+			reqBody: `{"baseline":20,"target":10}`,
+			status:  http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorer := mock_storage.NewMockStorer(ctrl)
+			if tt.query {
+				mockStorer.EXPECT().Query(gomock.Any(), gomock.Any()).Return(baseline, nil)
+			}
+
+			mockTaper := mock_taper.NewMockStorer(ctrl)
+			if tt.saved != nil {
+				mockTaper.EXPECT().Save(gomock.Any(), *tt.saved).Return(nil)
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.reqBody))
+
+			api := New(mockStorer, nil, WithTaperStore(mockTaper))
+			api.now = func() time.Time { return now }
+			api.CreateTaperPlan(w, r)
+
+			// validate status
+			status := w.Result().StatusCode
+			if status != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, status)
+			}
+			if tt.status != http.StatusCreated {
+				return
+			}
+
+			respBody := strings.TrimSpace(w.Body.String())
+			if respBody != tt.respBody {
+				t.Fatalf("expected '%s', got '%s'", tt.respBody, respBody)
+			}
+		})
+	}
+}
+
+func TestAPI_GetTaperPlan(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	start := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	plan := taper.Plan{Start: start, Baseline: 20, Target: 12, Weeks: 4, Step: taper.Linear}
+
+	mockTaper := mock_taper.NewMockStorer(ctrl)
+	mockTaper.EXPECT().Get(gomock.Any()).Return(plan, nil)
+
+	mockStorer := mock_storage.NewMockStorer(ctrl)
+	mockStorer.EXPECT().Query(gomock.Any(), gomock.Any()).Return([]storage.Plot{
+		{X: start.UnixMilli(), Y: 17},
+		{X: start.AddDate(0, 0, 7).UnixMilli(), Y: 20},
+		{X: start.AddDate(0, 0, 14).UnixMilli(), Y: 5},
+	}, nil)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	api := New(mockStorer, nil, WithTaperStore(mockTaper))
+	api.now = func() time.Time { return time.Date(2022, 8, 17, 12, 0, 0, 0, time.UTC) }
+	api.GetTaperPlan(w, r)
+
+	// validate status
+	status := w.Result().StatusCode
+	if status != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, status)
+	}
+
+	expected := `{"start":"2022-08-01T00:00:00Z","baseline":20,"target":12,"weeks":4,"step":"linear",` +
+		`"targets":[{"t":1659312000000,"y":18},{"t":1659916800000,"y":16},{"t":1660521600000,"y":14},` +
+		`{"t":1661126400000,"y":12}],` +
+		`"adherence":{"weeks":[` +
+		`{"start":"2022-08-01T00:00:00Z","target":18,"units":17,"complete":true,"adherent":true},` +
+		`{"start":"2022-08-08T00:00:00Z","target":16,"units":20,"complete":true,"adherent":false},` +
+		`{"start":"2022-08-15T00:00:00Z","target":14,"units":5,"complete":false}],` +
+		`"adherent_weeks":1,"complete_weeks":2,"adherence_percentage":50}}`
+	respBody := strings.TrimSpace(w.Body.String())
+	if respBody != expected {
+		t.Fatalf("expected '%s', got '%s'", expected, respBody)
+	}
+}
+
+func TestAPI_Query_TaperPlan(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	start := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+
+	mockTaper := mock_taper.NewMockStorer(ctrl)
+	mockTaper.EXPECT().Get(gomock.Any()).Return(taper.Plan{
+		Start:    start,
+		Baseline: 24,
+		Target:   16,
+		Weeks:    2,
+		Step:     taper.Linear,
+	}, nil)
+
+	mockStorer := mock_storage.NewMockStorer(ctrl)
+	mockStorer.EXPECT().Query(gomock.Any(), gomock.Any()).Return([]storage.Plot{{X: start.UnixMilli(), Y: 21}}, nil)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/?aggregation=week&unit=grams", nil)
+
+	api := New(mockStorer, nil, WithTaperStore(mockTaper))
+	api.Query(w, r)
+
+	// validate status
+	status := w.Result().StatusCode
+	if status != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, status)
+	}
+
+	expected := `{"plots":[{"t":1659312000000,"y":168,"tier":"increasing"}],"metadata":{"guideline":112,` +
+		`"guideline_plots":[{"t":1659312000000,"y":112}],"guideline_profile":"uk","weekly_limit":112,` +
+		`"tiers":{"lower":0,"increasing":1,"higher":0},` +
+		`"taper_plots":[{"t":1659312000000,"y":160},{"t":1659916800000,"y":128}],"unit":"grams"}}`
+	respBody := strings.TrimSpace(w.Body.String())
+	if respBody != expected {
+		t.Fatalf("expected '%s', got '%s'", expected, respBody)
+	}
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/auth"
+	"github.com/jemgunay/canlendar-graph/guideline"
+	"github.com/jemgunay/canlendar-graph/storage"
+	"github.com/jemgunay/canlendar-graph/tenant"
+)
+
+// UserParam is the query parameter used to select the user a request is for.
+const UserParam = "user"
+
+// UserMiddleware resolves the user selected by the user query parameter and stores it in the request context. If read
+// access is protected by a login, the login must be one of the user's logins, and the parameter may be omitted if the
+// login belongs to exactly one user. Otherwise, the parameter may be omitted if there is only one user.
+func (a *API) UserMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Query().Get(UserParam)
+		login, hasLogin := readLogin(r.Context())
+		if id == "" && hasLogin {
+			if users := a.users.ForLogin(login); len(users) == 1 {
+				id = users[0].ID
+			}
+		}
+
+		user, err := a.users.Get(id)
+		if err != nil {
+			log.Printf("failed to resolve user: %s", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if hasLogin && !user.Allows(login) {
+			log.Printf("login %s is not allowed to read user %s", login, user.ID)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(tenant.NewContext(r.Context(), user)))
+	})
+}
+
+// readLogin returns the login of the caller if read access is protected by a login. Share links have no login, as
+// they are bound to the user they were minted for.
+func readLogin(ctx context.Context) (string, bool) {
+	id, ok := auth.FromContext(ctx)
+	if !ok || id.Method == auth.ShareLink || id.Subject == "" {
+		return "", false
+	}
+	return id.Subject, true
+}
+
+// usersResponse lists the IDs of the users which the caller can select. It is empty in single-user mode, where no user
+// needs to be selected.
+type usersResponse struct {
+	Users []string `json:"users"`
+}
+
+// ListUsers lists the users which the caller can select with the user parameter, i.e. the users of the caller's login
+// if read access is protected by a login, or every user otherwise.
+func (a *API) ListUsers(w http.ResponseWriter, r *http.Request) {
+	users := a.users.List()
+	if login, ok := readLogin(r.Context()); ok {
+		users = a.users.ForLogin(login)
+	}
+
+	resp := usersResponse{Users: []string{}}
+	for _, u := range users {
+		if u.ID != "" {
+			resp.Users = append(resp.Users, u.ID)
+		}
+	}
+	writeJSON(w, resp)
+}
+
+// currentUser returns the user carried by the context, defaulting to the only user if there is one.
+func currentUser(ctx context.Context, users *tenant.Directory) (tenant.User, error) {
+	if user, ok := tenant.FromContext(ctx); ok {
+		return user, nil
+	}
+	return users.Get("")
+}
+
+// profile gets the guideline profile selected by the guideline query parameter, defaulting to the user's profile.
+func (a *API) profile(ctx context.Context, query url.Values) (guideline.Profile, bool) {
+	name := query.Get("guideline")
+	if name == "" {
+		if user, err := currentUser(ctx, a.users); err == nil {
+			name = user.GuidelineProfile
+		}
+	}
+	return a.guidelines.Get(name)
+}
+
+var _ storage.Storer = userStorer{}
+
+// userStorer scopes a storage.Storer to the user carried by the context, ensuring that every query is restricted to
+// the user's records and every stored record is tagged with the user.
+type userStorer struct {
+	storer storage.Storer
+	users  *tenant.Directory
+}
+
+// Store tags the records with the user before storing them.
+func (s userStorer) Store(ctx context.Context, records ...storage.Record) error {
+	user, err := currentUser(ctx, s.users)
+	if err != nil {
+		return err
+	}
+
+	// records written in single-user mode have no user tag
+	if user.ID != "" {
+		for i := range records {
+			tags := make(map[string]string, len(records[i].Tags)+1)
+			for k, v := range records[i].Tags {
+				tags[k] = v
+			}
+			tags[storage.UserTag] = user.ID
+			records[i].Tags = tags
+		}
+	}
+	return s.storer.Store(ctx, records...)
+}
+
+// Delete deletes the user's records.
+func (s userStorer) Delete(ctx context.Context, options ...storage.QueryOption) error {
+	options, err := s.scope(ctx, options)
+	if err != nil {
+		return err
+	}
+	return s.storer.Delete(ctx, options...)
+}
+
+// Query queries the user's plots.
+func (s userStorer) Query(ctx context.Context, options ...storage.QueryOption) ([]storage.Plot, error) {
+	options, err := s.scope(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	return s.storer.Query(ctx, options...)
+}
+
+// QueryRecords queries the user's records.
+func (s userStorer) QueryRecords(ctx context.Context, options ...storage.QueryOption) ([]storage.Record, error) {
+	options, err := s.scope(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	return s.storer.QueryRecords(ctx, options...)
+}
+
+// ReadLastTimestamp reads the timestamp of the user's newest record.
+func (s userStorer) ReadLastTimestamp(ctx context.Context, options ...storage.QueryOption) (time.Time, error) {
+	options, err := s.scope(ctx, options)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return s.storer.ReadLastTimestamp(ctx, options...)
+}
+
+// ReadFirstTimestamp reads the timestamp of the user's oldest record.
+func (s userStorer) ReadFirstTimestamp(ctx context.Context, options ...storage.QueryOption) (time.Time, error) {
+	options, err := s.scope(ctx, options)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return s.storer.ReadFirstTimestamp(ctx, options...)
+}
+
+// scope appends the user option last, such that it cannot be overridden.
+func (s userStorer) scope(ctx context.Context, options []storage.QueryOption) ([]storage.QueryOption, error) {
+	user, err := currentUser(ctx, s.users)
+	if err != nil {
+		return nil, err
+	}
+	scoped := make([]storage.QueryOption, 0, len(options)+1)
+	return append(append(scoped, options...), storage.WithUser(user.ID)), nil
+}
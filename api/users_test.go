@@ -0,0 +1,358 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jemgunay/canlendar-graph/auth"
+	"github.com/jemgunay/canlendar-graph/calendar"
+	"github.com/jemgunay/canlendar-graph/collect"
+	"github.com/jemgunay/canlendar-graph/guideline"
+	"github.com/jemgunay/canlendar-graph/storage"
+	"github.com/jemgunay/canlendar-graph/tenant"
+
+	mock_calendar "github.com/jemgunay/canlendar-graph/calendar/mocks"
+	mock_storage "github.com/jemgunay/canlendar-graph/storage/mocks"
+)
+
+func newTestUsers(t *testing.T) *tenant.Directory {
+	users, err := tenant.New([]tenant.User{
+		{
+			ID:         "alice",
+			CalendarID: "alice-calendar",
+			Timezone:   "Europe/London",
+			Logins:     []string{"alice@example.com", "family@example.com"},
+		},
+		{
+			ID:               "bob",
+			CalendarID:       "bob-calendar",
+			GuidelineProfile: guideline.UKMale,
+			Logins:           []string{"bob", "family@example.com"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create users: %s", err)
+	}
+	return users
+}
+
+func TestAPI_Collect_Users(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	day := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+
+	mockIter := mock_calendar.NewMockEventIterator(ctrl)
+	mockIter.EXPECT().Count().Return(1)
+	mockIter.EXPECT().Pages().Return(1)
+	var read bool
+	mockIter.EXPECT().Next().DoAndReturn(func() (calendar.Event, error) {
+		if read {
+			return calendar.Event{}, calendar.ErrNoMoreEvents
+		}
+		read = true
+		return calendar.Event{Date: day, Units: 4}, nil
+	}).AnyTimes()
+
+	// alice's calendar fails, but collection continues for bob
+	mockAlice := mock_calendar.NewMockFetcher(ctrl)
+	mockAlice.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("calendar unavailable"))
+	mockBob := mock_calendar.NewMockFetcher(ctrl)
+	mockBob.EXPECT().Fetch(gomock.Any(), day.Add(24*time.Hour), time.Time{}).Return(mockIter, nil)
+
+	mockCalendar := mock_calendar.NewMockProvider(ctrl)
+	mockCalendar.EXPECT().Fetcher("alice-calendar", "Europe/London").Return(mockAlice, nil)
+	mockCalendar.EXPECT().Fetcher("bob-calendar", "").Return(mockBob, nil)
+
+	mockStorer := mock_storage.NewMockStorer(ctrl)
+	var queriedUsers []string
+	mockStorer.EXPECT().ReadLastTimestamp(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, options ...storage.QueryOption) (time.Time, error) {
+			queriedUsers = append(queriedUsers, storage.NewTimestampQuery(options...).User)
+			return day, nil
+		},
+	).Times(2)
+	mockStorer.EXPECT().QueryRecords(gomock.Any(), gomock.Any()).Return(nil, storage.ErrNoResults)
+	var stored []storage.Record
+	mockStorer.EXPECT().Store(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, records ...storage.Record) error {
+			stored = records
+			return nil
+		},
+	)
+
+	api := New(mockStorer, mockCalendar, WithUsers(newTestUsers(t)))
+	job := collectAndWait(t, api, `{}`)
+
+	if job.Status != collect.Failed {
+		t.Fatalf("expected %s, got %s", collect.Failed, job.Status)
+	}
+	if len(job.Progress.Errors) != 1 || job.Progress.RecordsWritten != 1 {
+		t.Fatalf("expected 1 error and 1 written record, got %+v", job.Progress)
+	}
+
+	if len(queriedUsers) != 2 || queriedUsers[0] != "alice" || queriedUsers[1] != "bob" {
+		t.Fatalf("expected timestamps to be read for alice and bob, got %v", queriedUsers)
+	}
+	if len(stored) != 1 {
+		t.Fatalf("expected %d, got %d", 1, len(stored))
+	}
+	if user := stored[0].Tags[storage.UserTag]; user != "bob" {
+		t.Fatalf("expected %s, got %s", "bob", user)
+	}
+}
+
+func TestAPI_UserMiddleware(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cases := []struct {
+		name     string
+		params   string
+		identity *auth.Identity
+		status   int
+		user     string
+		profile  string
+	}{
+		{
+			name:    "user_profile",
+			params:  "&user=bob",
+			status:  http.StatusOK,
+			user:    "bob",
+			profile: guideline.UKMale,
+		},
+		{
+			name:    "override_profile",
+			params:  "&user=bob&guideline=uk",
+			status:  http.StatusOK,
+			user:    "bob",
+			profile: guideline.UK,
+		},
+		{
+			name:    "default_profile",
+			params:  "&user=alice",
+			status:  http.StatusOK,
+			user:    "alice",
+			profile: guideline.UK,
+		},
+		{
+			name:   "unknown_user",
+			params: "&user=carol",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "no_user",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:     "login_selects_user",
+			identity: &auth.Identity{Method: auth.OIDC, Subject: "Alice@Example.com"},
+			status:   http.StatusOK,
+			user:     "alice",
+			profile:  guideline.UK,
+		},
+		{
+			name:     "login_selected_user",
+			params:   "&user=bob",
+			identity: &auth.Identity{Method: auth.Basic, Subject: "bob"},
+			status:   http.StatusOK,
+			user:     "bob",
+			profile:  guideline.UKMale,
+		},
+		{
+			name:     "login_shared_user",
+			params:   "&user=bob",
+			identity: &auth.Identity{Method: auth.OIDC, Subject: "family@example.com"},
+			status:   http.StatusOK,
+			user:     "bob",
+			profile:  guideline.UKMale,
+		},
+		{
+			name:     "login_ambiguous_user",
+			identity: &auth.Identity{Method: auth.OIDC, Subject: "family@example.com"},
+			status:   http.StatusBadRequest,
+		},
+		{
+			name:     "login_other_user",
+			params:   "&user=bob",
+			identity: &auth.Identity{Method: auth.OIDC, Subject: "alice@example.com"},
+			status:   http.StatusForbidden,
+		},
+		{
+			name:     "login_unknown",
+			params:   "&user=alice",
+			identity: &auth.Identity{Method: auth.OIDC, Subject: "carol@example.com"},
+			status:   http.StatusForbidden,
+		},
+		{
+			name:     "share_link_user",
+			params:   "&user=bob",
+			identity: &auth.Identity{Method: auth.ShareLink},
+			status:   http.StatusOK,
+			user:     "bob",
+			profile:  guideline.UKMale,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorer := mock_storage.NewMockStorer(ctrl)
+			var queriedUser string
+			if tt.status == http.StatusOK {
+				mockStorer.EXPECT().Query(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ context.Context, options ...storage.QueryOption) ([]storage.Plot, error) {
+						queriedUser = storage.NewTimestampQuery(options...).User
+						return []storage.Plot{}, nil
+					},
+				)
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/?aggregation=week&end_time=2022-08-25T00:00:00Z"+tt.params, nil)
+			if tt.identity != nil {
+				r = r.WithContext(auth.NewContext(r.Context(), *tt.identity))
+			}
+
+			api := New(mockStorer, nil, WithUsers(newTestUsers(t)))
+			api.UserMiddleware(http.HandlerFunc(api.Query)).ServeHTTP(w, r)
+
+			// validate status
+			status := w.Result().StatusCode
+			if status != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			if queriedUser != tt.user {
+				t.Fatalf("expected %s, got %s", tt.user, queriedUser)
+			}
+			resp := queryResponse{}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %s", err)
+			}
+			if resp.Metadata.GuidelineProfile != tt.profile {
+				t.Fatalf("expected %s, got %s", tt.profile, resp.Metadata.GuidelineProfile)
+			}
+		})
+	}
+}
+
+func TestAPI_ListUsers(t *testing.T) {
+	cases := []struct {
+		name     string
+		users    *tenant.Directory
+		identity *auth.Identity
+		expected []string
+	}{
+		{
+			name:     "public",
+			users:    newTestUsers(t),
+			expected: []string{"alice", "bob"},
+		},
+		{
+			name:     "login",
+			users:    newTestUsers(t),
+			identity: &auth.Identity{Method: auth.Basic, Subject: "bob"},
+			expected: []string{"bob"},
+		},
+		{
+			name:     "shared_login",
+			users:    newTestUsers(t),
+			identity: &auth.Identity{Method: auth.OIDC, Subject: "family@example.com"},
+			expected: []string{"alice", "bob"},
+		},
+		{
+			name:     "unknown_login",
+			users:    newTestUsers(t),
+			identity: &auth.Identity{Method: auth.OIDC, Subject: "carol@example.com"},
+			expected: []string{},
+		},
+		{
+			name:     "single_user",
+			users:    tenant.Single(tenant.User{CalendarID: "units"}),
+			identity: &auth.Identity{Method: auth.Basic, Subject: "anyone"},
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+			if tt.identity != nil {
+				r = r.WithContext(auth.NewContext(r.Context(), *tt.identity))
+			}
+
+			api := New(nil, nil, WithUsers(tt.users))
+			api.ListUsers(w, r)
+
+			if status := w.Result().StatusCode; status != http.StatusOK {
+				t.Fatalf("expected %d, got %d", http.StatusOK, status)
+			}
+			resp := usersResponse{}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %s", err)
+			}
+			if !reflect.DeepEqual(resp.Users, tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, resp.Users)
+			}
+		})
+	}
+}
+
+// TestAPI_Query_Users follows the dashboard in multi-user mode, which lists the users of the login and then queries
+// the first of them.
+func TestAPI_Query_Users(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorer := mock_storage.NewMockStorer(ctrl)
+	var queriedUser string
+	mockStorer.EXPECT().Query(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, options ...storage.QueryOption) ([]storage.Plot, error) {
+			queriedUser = storage.NewTimestampQuery(options...).User
+			return []storage.Plot{{X: time.Date(2022, 8, 22, 0, 0, 0, 0, time.UTC).Unix(), Y: 4}}, nil
+		},
+	)
+
+	api := New(mockStorer, nil, WithUsers(newTestUsers(t)))
+	login := auth.NewContext(context.Background(), auth.Identity{Method: auth.OIDC, Subject: "bob"})
+
+	w := httptest.NewRecorder()
+	api.ListUsers(w, httptest.NewRequest(http.MethodGet, "/api/v1/users", nil).WithContext(login))
+	users := usersResponse{}
+	if err := json.NewDecoder(w.Body).Decode(&users); err != nil {
+		t.Fatalf("failed to decode users response: %s", err)
+	}
+	if len(users.Users) != 1 {
+		t.Fatalf("expected 1 user, got %v", users.Users)
+	}
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/query?aggregation=week&end_time=2022-08-25T00:00:00Z&user="+
+		users.Users[0], nil)
+	api.UserMiddleware(http.HandlerFunc(api.Query)).ServeHTTP(w, r.WithContext(login))
+
+	if status := w.Result().StatusCode; status != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, status)
+	}
+	if queriedUser != "bob" {
+		t.Fatalf("expected %s, got %s", "bob", queriedUser)
+	}
+	resp := queryResponse{}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %s", err)
+	}
+	if len(resp.Plots) != 1 || resp.Metadata.GuidelineProfile != guideline.UKMale {
+		t.Fatalf("expected bob's plot and profile, got %+v", resp)
+	}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/jemgunay/canlendar-graph/collect"
+	"github.com/jemgunay/canlendar-graph/storage"
+	"github.com/jemgunay/canlendar-graph/watch"
+)
+
+// Notify receives Google Calendar watch channel notifications, starting an incremental collect job for the user whose
+// calendar changed. Notifications are verified by their channel token, and notifications of channels which are no
+// longer registered are ignored. If a collect job is already running, the notification is rejected with a 503 such
+// that Google redelivers it with backoff.
+func (a *API) Notify(w http.ResponseWriter, r *http.Request) {
+	if a.watches == nil {
+		log.Printf("no watch manager configured")
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	channelID := r.Header.Get(watch.ChannelIDHeader)
+	if !a.watches.Verify(channelID, r.Header.Get(watch.ChannelTokenHeader)) {
+		log.Printf("invalid token for watch channel '%s'", channelID)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	// the sync notification confirms that a channel has been registered, rather than a change
+	state := r.Header.Get(watch.ResourceStateHeader)
+	if state == watch.StateSync {
+		log.Printf("watch channel %s registered", channelID)
+		return
+	}
+
+	user, err := a.watches.Owner(r.Context(), channelID)
+	if err != nil {
+		log.Printf("failed to find the owner of watch channel %s: %s", channelID, err)
+		if !errors.Is(err, watch.ErrUnknownChannel) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	job, err := a.StartCollect(r.Context(), CollectOptions{Users: []string{user.ID}})
+	if err != nil {
+		log.Printf("failed to start collect job for watch channel %s: %s", channelID, err)
+		switch {
+		case errors.Is(err, collect.ErrRunning), errors.Is(err, storage.ErrLocked):
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Printf("started collect job %s for user '%s' of watch channel %s (%s)", job.ID, user.ID, channelID, state)
+}
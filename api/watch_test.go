@@ -0,0 +1,190 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jemgunay/canlendar-graph/calendar"
+	"github.com/jemgunay/canlendar-graph/config"
+	"github.com/jemgunay/canlendar-graph/storage"
+	"github.com/jemgunay/canlendar-graph/tenant"
+	"github.com/jemgunay/canlendar-graph/watch"
+	watchfile "github.com/jemgunay/canlendar-graph/watch/file"
+
+	mock_calendar "github.com/jemgunay/canlendar-graph/calendar/mocks"
+	mock_storage "github.com/jemgunay/canlendar-graph/storage/mocks"
+)
+
+func TestAPI_Notify(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cases := []struct {
+		name     string
+		tamper   bool
+		busy     bool
+		unknown  bool
+		collects bool
+		status   int
+	}{
+		{
+			name:     "collects",
+			collects: true,
+			status:   http.StatusOK,
+		},
+		{
+			name:   "invalid_token",
+			tamper: true,
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "collect_running",
+			busy:   true,
+			status: http.StatusServiceUnavailable,
+		},
+		{
+			name:    "unknown_channel",
+			unknown: true,
+			status:  http.StatusOK,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var api *API
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				api.Notify(w, r)
+			}))
+			defer srv.Close()
+
+			// the fake stands in for the Google Calendar API, sending notifications to the webhook
+			fake := watch.NewFake(srv.Client())
+			mockFetcher := mock_calendar.NewMockFetcher(ctrl)
+			mockCalendar := mock_calendar.NewMockProvider(ctrl)
+			mockCalendar.EXPECT().Watcher("units").Return(fake, nil)
+			mockStorer := mock_storage.NewMockStorer(ctrl)
+			if tt.collects {
+				// collected once for the request below, and once for the notification sent by the fake
+				mockStorer.EXPECT().ReadLastTimestamp(gomock.Any(), gomock.Any()).Return(time.Time{}, storage.ErrNoResults).Times(2)
+				mockCalendar.EXPECT().Fetcher("units", "").Return(mockFetcher, nil).Times(2)
+				mockFetcher.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, calendar.ErrNoEventsFound).Times(2)
+			}
+
+			users := tenant.Single(tenant.User{CalendarID: "units"})
+			manager, err := watch.New(config.Watch{
+				Address:          srv.URL,
+				Secret:           "0123456789abcdef0123456789abcdef",
+				TTLHours:         168,
+				RenewBeforeHours: 24,
+			}, users, mockCalendar, watchfile.New(config.Watch{File: filepath.Join(t.TempDir(), "watch.json")}))
+			if err != nil {
+				t.Fatalf("failed to create watch manager: %s", err)
+			}
+			api = New(mockStorer, mockCalendar, WithUsers(users), WithWatchManager(manager))
+
+			// registering a channel sends a sync notification, which does not collect
+			if _, err := manager.Register(context.Background()); err != nil {
+				t.Fatalf("failed to register channel: %s", err)
+			}
+			if tt.busy {
+				if _, err := api.jobs.Start(); err != nil {
+					t.Fatalf("failed to start job: %s", err)
+				}
+			}
+
+			channel := fake.Channels()[0]
+			if tt.tamper {
+				channel.Token = manager.Token("another-channel")
+			}
+			// notifications of channels which are no longer registered are ignored
+			if tt.unknown {
+				channel.ID = "replaced-channel"
+				channel.Token = manager.Token(channel.ID)
+			}
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			r.Header.Set(watch.ChannelIDHeader, channel.ID)
+			r.Header.Set(watch.ChannelTokenHeader, channel.Token)
+			r.Header.Set(watch.ResourceStateHeader, watch.StateExists)
+			api.Notify(w, r)
+			api.collecting.Wait()
+
+			// validate status
+			status := w.Result().StatusCode
+			if status != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, status)
+			}
+
+			// notifications sent by the fake are accepted
+			if tt.collects {
+				if err := fake.Notify(context.Background()); err != nil {
+					t.Fatalf("failed to notify: %s", err)
+				}
+				api.collecting.Wait()
+			}
+		})
+	}
+}
+
+func TestAPI_Notify_Users(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var api *API
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.Notify(w, r)
+	}))
+	defer srv.Close()
+
+	// a channel is registered for each user's calendar
+	fakes := map[string]*watch.Fake{
+		"alice-calendar": watch.NewFake(srv.Client()),
+		"bob-calendar":   watch.NewFake(srv.Client()),
+	}
+	mockCalendar := mock_calendar.NewMockProvider(ctrl)
+	for calendarID, fake := range fakes {
+		mockCalendar.EXPECT().Watcher(calendarID).Return(fake, nil)
+	}
+
+	// only bob's calendar is collected for a notification of bob's channel
+	mockFetcher := mock_calendar.NewMockFetcher(ctrl)
+	mockFetcher.EXPECT().Fetch(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, calendar.ErrNoEventsFound)
+	mockCalendar.EXPECT().Fetcher("bob-calendar", "").Return(mockFetcher, nil)
+	mockStorer := mock_storage.NewMockStorer(ctrl)
+	var queriedUsers []string
+	mockStorer.EXPECT().ReadLastTimestamp(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, options ...storage.QueryOption) (time.Time, error) {
+			queriedUsers = append(queriedUsers, storage.NewTimestampQuery(options...).User)
+			return time.Time{}, storage.ErrNoResults
+		},
+	)
+
+	users := newTestUsers(t)
+	manager, err := watch.New(config.Watch{
+		Address:          srv.URL,
+		Secret:           "0123456789abcdef0123456789abcdef",
+		TTLHours:         168,
+		RenewBeforeHours: 24,
+	}, users, mockCalendar, watchfile.New(config.Watch{File: filepath.Join(t.TempDir(), "watch.json")}))
+	if err != nil {
+		t.Fatalf("failed to create watch manager: %s", err)
+	}
+	api = New(mockStorer, mockCalendar, WithUsers(users), WithWatchManager(manager))
+
+	if _, err := manager.Register(context.Background()); err != nil {
+		t.Fatalf("failed to register channels: %s", err)
+	}
+	if err := fakes["bob-calendar"].Notify(context.Background()); err != nil {
+		t.Fatalf("failed to notify: %s", err)
+	}
+	api.collecting.Wait()
+
+	if len(queriedUsers) != 1 || queriedUsers[0] != "bob" {
+		t.Fatalf("expected only bob to be collected, got %v", queriedUsers)
+	}
+}
@@ -0,0 +1,250 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/jemgunay/canlendar-graph/config"
+)
+
+const (
+	// ShareParam is the query parameter used to provide a share token.
+	ShareParam = "share"
+	// shareCookie is the cookie which remembers the share token of the last share link opened, such that the
+	// dashboard's static files can be loaded without the share token in their URLs.
+	shareCookie = "share"
+)
+
+// AccessMode is the login protection applied to read access.
+type AccessMode string
+
+const (
+	// AccessNone applies no login protection, i.e. read access is public.
+	AccessNone AccessMode = "none"
+	// AccessBasic requires HTTP basic auth credentials.
+	AccessBasic AccessMode = "basic"
+	// AccessOIDC requires an OIDC ID token belonging to one of the allowed emails.
+	AccessOIDC AccessMode = "oidc"
+)
+
+// String returns the string representation of an AccessMode.
+func (m AccessMode) String() string {
+	return string(m)
+}
+
+// IsValid determines if an AccessMode is supported.
+func (m AccessMode) IsValid() bool {
+	switch m {
+	case AccessNone, AccessBasic, AccessOIDC:
+		return true
+	}
+	return false
+}
+
+// ReadGuard protects read access to the dashboard and API with an optional login, and grants scoped read-only access
+// to holders of a valid share token.
+type ReadGuard struct {
+	mode     AccessMode
+	username []byte
+	password []byte
+	verifier *Verifier
+	flow     *loginFlow
+	shares   *ShareSigner
+}
+
+// NewReadGuard initialises a ReadGuard. OIDC ID tokens are verified against the issuers and JWKS URL of the auth
+// config, and browsers are logged in with the OIDC authorization code flow if it is configured. Share tokens are
+// rejected if no signer is provided.
+func NewReadGuard(conf config.Access, authConf config.Auth, shares *ShareSigner) (*ReadGuard, error) {
+	g := &ReadGuard{
+		mode:   AccessMode(conf.Mode),
+		shares: shares,
+	}
+
+	switch g.mode {
+	case AccessNone:
+	case AccessBasic:
+		if conf.BasicUsername == "" || conf.BasicPassword == "" {
+			return nil, errors.New("both a username and password must be configured for basic access")
+		}
+		g.username, g.password = []byte(conf.BasicUsername), []byte(conf.BasicPassword)
+	case AccessOIDC:
+		if conf.OIDCAudience == "" || len(conf.OIDCEmails) == 0 {
+			return nil, errors.New("both an audience and emails must be configured for OIDC access")
+		}
+		g.verifier = NewVerifier(NewKeySet(authConf.JWKSURL), authConf.Issuers, conf.OIDCAudience, conf.OIDCEmails)
+		flow, err := newLoginFlow(conf)
+		if err != nil {
+			return nil, err
+		}
+		g.flow = flow
+	default:
+		return nil, fmt.Errorf("unsupported access mode: %s", conf.Mode)
+	}
+	return g, nil
+}
+
+// Middleware rejects requests which do not satisfy the login protection. Share tokens are not accepted.
+func (g *ReadGuard) Middleware(next http.Handler) http.Handler {
+	if g.mode == AccessNone {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := g.login(r)
+		if err != nil {
+			log.Printf("failed to authenticate read request: %s", err)
+			g.challenge(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), id)))
+	})
+}
+
+// WriteMiddleware behaves as Middleware for requests which write. If read access is public, writes would be too, so
+// they are instead protected by the fallback middleware, such as the API authenticator.
+func (g *ReadGuard) WriteMiddleware(fallback func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if g.mode == AccessNone {
+			return fallback(next)
+		}
+		return g.Middleware(next)
+	}
+}
+
+// ShareMiddleware behaves as Middleware, but also accepts a share token via the share query parameter. The request's
+// query parameters are restricted to the scope of the share token. A verified share token is remembered in a cookie
+// until it expires, and is used if the request has neither a share token nor login credentials.
+func (g *ReadGuard) ShareMiddleware(next http.Handler) http.Handler {
+	login := g.Middleware(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		token := query.Get(ShareParam)
+		remembered := false
+		if token == "" {
+			cookie, err := r.Cookie(shareCookie)
+			if err != nil || g.hasCredentials(r) {
+				login.ServeHTTP(w, r)
+				return
+			}
+			token, remembered = cookie.Value, true
+		}
+
+		if g.shares == nil {
+			log.Printf("share token provided but share links are disabled")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		share, err := g.shares.Verify(token)
+		if err != nil {
+			log.Printf("failed to verify share token: %s", err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err := share.Restrict(query); err != nil {
+			log.Printf("failed to restrict request to share: %s", err)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		r.URL.RawQuery = query.Encode()
+
+		if !remembered {
+			http.SetCookie(w, &http.Cookie{
+				Name:     shareCookie,
+				Value:    token,
+				Path:     "/",
+				Expires:  share.Expiry,
+				Secure:   g.secure(r),
+				HttpOnly: true,
+				SameSite: http.SameSiteStrictMode,
+			})
+		}
+
+		id := Identity{Method: ShareLink}
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), id)))
+	})
+}
+
+// secure determines if cookies should only be sent over HTTPS. If the browser login is configured, its redirect URL
+// decides, otherwise the request does, including requests forwarded by a proxy which terminates HTTPS.
+func (g *ReadGuard) secure(r *http.Request) bool {
+	if g.flow != nil {
+		return g.flow.secure()
+	}
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+// login determines the identity of the caller according to the access mode.
+func (g *ReadGuard) login(r *http.Request) (Identity, error) {
+	switch g.mode {
+	case AccessBasic:
+		username, password, ok := r.BasicAuth()
+		if !ok {
+			return Identity{}, errors.New("no credentials provided")
+		}
+		// evaluate both comparisons to avoid leaking which of the two is incorrect
+		validUsername := subtle.ConstantTimeCompare([]byte(username), g.username)
+		validPassword := subtle.ConstantTimeCompare([]byte(password), g.password)
+		if validUsername&validPassword != 1 {
+			return Identity{}, errors.New("invalid credentials")
+		}
+		return Identity{Method: Basic, Subject: username}, nil
+
+	case AccessOIDC:
+		token := bearerToken(r)
+		if token == "" {
+			return Identity{}, errors.New("no credentials provided")
+		}
+		claims, err := g.verifier.Verify(r.Context(), token)
+		if err != nil {
+			return Identity{}, err
+		}
+		return Identity{Method: OIDC, Subject: claims.Email}, nil
+	}
+
+	return Identity{}, fmt.Errorf("unsupported access mode: %s", g.mode)
+}
+
+// bearerToken returns the token of the Authorization header, falling back to the session cookie of a browser login.
+func bearerToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		if token := strings.TrimPrefix(header, "Bearer "); token != header {
+			return token
+		}
+		return ""
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// hasCredentials determines if the request provides login credentials, or if no login is required.
+func (g *ReadGuard) hasCredentials(r *http.Request) bool {
+	if g.mode == AccessNone || r.Header.Get("Authorization") != "" {
+		return true
+	}
+	_, err := r.Cookie(sessionCookie)
+	return g.mode == AccessOIDC && err == nil
+}
+
+// challenge writes an unauthorised response, prompting for credentials appropriate to the access mode. Browser
+// navigations are redirected to the login page if the OIDC browser login is configured.
+func (g *ReadGuard) challenge(w http.ResponseWriter, r *http.Request) {
+	if g.flow != nil && isNavigation(r) {
+		http.Redirect(w, r, LoginPath, http.StatusFound)
+		return
+	}
+	if g.mode == AccessBasic {
+		w.Header().Set("WWW-Authenticate", `Basic realm="canlendar-graph", charset="UTF-8"`)
+	} else {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+	w.WriteHeader(http.StatusUnauthorized)
+}
@@ -0,0 +1,316 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/config"
+)
+
+func TestReadGuard_ShareMiddleware(t *testing.T) {
+	shares, err := NewShareSigner(config.Share{
+		Secret:      "0123456789abcdef0123456789abcdef",
+		MaxTTLHours: 24,
+	})
+	if err != nil {
+		t.Fatalf("failed to create share signer: %s", err)
+	}
+	guard, err := NewReadGuard(config.Access{
+		Mode:          AccessBasic.String(),
+		BasicUsername: "user",
+		BasicPassword: "password",
+	}, config.Auth{}, shares)
+	if err != nil {
+		t.Fatalf("failed to create read guard: %s", err)
+	}
+
+	now := time.Now()
+	sign := func(expiry time.Time) string {
+		token, err := shares.Sign(Share{
+			Aggregation: "week",
+			Start:       time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC),
+			End:         time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC),
+			Expiry:      expiry,
+		})
+		if err != nil {
+			t.Fatalf("failed to sign share: %s", err)
+		}
+		return token
+	}
+	validToken := sign(now.Add(time.Hour))
+
+	cases := []struct {
+		name     string
+		query    string
+		username string
+		password string
+		// cookie is the remembered share token, if any
+		cookie   string
+		status   int
+		identity Identity
+		// expected query parameters received by the handler
+		aggregation string
+		start       string
+		end         string
+	}{
+		{
+			name:        "share_clamps_range",
+			query:       "?aggregation=week&end_time=2022-10-01T00:00:00Z&share=" + validToken,
+			status:      http.StatusOK,
+			identity:    Identity{Method: ShareLink},
+			aggregation: "week",
+			start:       "2022-06-01T00:00:00Z",
+			end:         "2022-08-01T00:00:00Z",
+		},
+		{
+			name:        "share_narrower_range",
+			query:       "?start_time=2022-07-01T00:00:00Z&end_time=2022-07-15T00:00:00Z&share=" + validToken,
+			status:      http.StatusOK,
+			identity:    Identity{Method: ShareLink},
+			aggregation: "week",
+			start:       "2022-07-01T00:00:00Z",
+			end:         "2022-07-15T00:00:00Z",
+		},
+		{
+			name:   "share_wrong_aggregation",
+			query:  "?aggregation=day&share=" + validToken,
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "share_outside_range",
+			query:  "?start_time=2022-09-01T00:00:00Z&share=" + validToken,
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "share_expired",
+			query:  "?share=" + sign(now.Add(-time.Hour)),
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "share_tampered",
+			query:  "?share=" + validToken + "x",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:        "basic_auth",
+			query:       "?aggregation=day",
+			username:    "user",
+			password:    "password",
+			status:      http.StatusOK,
+			identity:    Identity{Method: Basic, Subject: "user"},
+			aggregation: "day",
+		},
+		{
+			name:        "share_cookie",
+			query:       "?aggregation=week",
+			cookie:      validToken,
+			status:      http.StatusOK,
+			identity:    Identity{Method: ShareLink},
+			aggregation: "week",
+			start:       "2022-06-01T00:00:00Z",
+			end:         "2022-08-01T00:00:00Z",
+		},
+		{
+			name:   "share_cookie_tampered",
+			cookie: validToken + "x",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:        "basic_auth_overrides_share_cookie",
+			query:       "?aggregation=day",
+			cookie:      validToken,
+			username:    "user",
+			password:    "password",
+			status:      http.StatusOK,
+			identity:    Identity{Method: Basic, Subject: "user"},
+			aggregation: "day",
+		},
+		{
+			name:     "basic_auth_wrong_password",
+			username: "user",
+			password: "wrong",
+			status:   http.StatusUnauthorized,
+		},
+		{
+			name:   "no_credentials",
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/query"+tt.query, nil)
+			if tt.username != "" {
+				r.SetBasicAuth(tt.username, tt.password)
+			}
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: shareCookie, Value: tt.cookie})
+			}
+
+			var (
+				identity Identity
+				received *http.Request
+			)
+			handler := guard.ShareMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				identity, _ = FromContext(r.Context())
+				received = r
+			}))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			// validate status
+			status := w.Result().StatusCode
+			if status != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			if identity != tt.identity {
+				t.Fatalf("expected '%+v', got '%+v'", tt.identity, identity)
+			}
+			// share tokens provided by query parameter are remembered
+			remembered := len(w.Result().Cookies()) == 1 && w.Result().Cookies()[0].Value == validToken
+			if remembered != (identity.Method == ShareLink && tt.cookie == "") {
+				t.Fatalf("unexpected cookies: %v", w.Result().Cookies())
+			}
+			query := received.URL.Query()
+			if query.Get("aggregation") != tt.aggregation || query.Get("start_time") != tt.start ||
+				query.Get("end_time") != tt.end {
+				t.Fatalf("expected '%s %s %s', got '%s %s %s'", tt.aggregation, tt.start, tt.end,
+					query.Get("aggregation"), query.Get("start_time"), query.Get("end_time"))
+			}
+		})
+	}
+}
+
+func TestReadGuard_ShareMiddleware_SecureCookie(t *testing.T) {
+	shares, err := NewShareSigner(config.Share{
+		Secret:      "0123456789abcdef0123456789abcdef",
+		MaxTTLHours: 24,
+	})
+	if err != nil {
+		t.Fatalf("failed to create share signer: %s", err)
+	}
+	guard, err := NewReadGuard(config.Access{Mode: AccessNone.String()}, config.Auth{}, shares)
+	if err != nil {
+		t.Fatalf("failed to create read guard: %s", err)
+	}
+	token, err := shares.Sign(Share{Aggregation: "week", Expiry: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("failed to sign share: %s", err)
+	}
+
+	cases := []struct {
+		name      string
+		url       string
+		forwarded string
+		secure    bool
+	}{
+		{
+			name: "http",
+			url:  "http://example.com/?share=" + token,
+		},
+		{
+			name:   "https",
+			url:    "https://example.com/?share=" + token,
+			secure: true,
+		},
+		{
+			name:      "forwarded_https",
+			url:       "http://example.com/?share=" + token,
+			forwarded: "https",
+			secure:    true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-Proto", tt.forwarded)
+			}
+			w := httptest.NewRecorder()
+			guard.ShareMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, r)
+
+			cookie := findCookie(w.Result().Cookies(), shareCookie)
+			if cookie == nil || cookie.Secure != tt.secure {
+				t.Fatalf("expected remembered share cookie with secure %t, got %v", tt.secure, cookie)
+			}
+		})
+	}
+}
+
+func TestReadGuard_Middleware_NoLogin(t *testing.T) {
+	guard, err := NewReadGuard(config.Access{Mode: AccessNone.String()}, config.Auth{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create read guard: %s", err)
+	}
+
+	w := httptest.NewRecorder()
+	guard.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+		ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stats", nil))
+
+	if status := w.Result().StatusCode; status != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, status)
+	}
+}
+
+func TestReadGuard_WriteMiddleware(t *testing.T) {
+	// the fallback rejects every request, standing in for the API authenticator
+	fallback := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		})
+	}
+
+	cases := []struct {
+		name     string
+		access   config.Access
+		username string
+		password string
+		status   int
+	}{
+		{
+			name:   "public_read_access",
+			access: config.Access{Mode: AccessNone.String()},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:     "basic_auth",
+			access:   config.Access{Mode: AccessBasic.String(), BasicUsername: "user", BasicPassword: "password"},
+			username: "user",
+			password: "password",
+			status:   http.StatusOK,
+		},
+		{
+			name:   "basic_auth_no_credentials",
+			access: config.Access{Mode: AccessBasic.String(), BasicUsername: "user", BasicPassword: "password"},
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			guard, err := NewReadGuard(tt.access, config.Auth{}, nil)
+			if err != nil {
+				t.Fatalf("failed to create read guard: %s", err)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/goals", nil)
+			if tt.username != "" {
+				r.SetBasicAuth(tt.username, tt.password)
+			}
+			w := httptest.NewRecorder()
+			guard.WriteMiddleware(fallback)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+				ServeHTTP(w, r)
+
+			if status := w.Result().StatusCode; status != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, status)
+			}
+		})
+	}
+}
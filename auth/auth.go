@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/jemgunay/canlendar-graph/config"
+)
+
+// APIKeyHeader is the request header used to provide a static API key.
+const APIKeyHeader = "X-API-Key"
+
+// Method is the means by which a caller was authenticated.
+type Method string
+
+const (
+	// OIDC indicates that the caller provided a verified OIDC ID token.
+	OIDC Method = "oidc"
+	// APIKey indicates that the caller provided a static API key.
+	APIKey Method = "api_key"
+	// Basic indicates that the caller provided valid basic auth credentials.
+	Basic Method = "basic"
+	// ShareLink indicates that the caller provided a valid share token.
+	ShareLink Method = "share_link"
+)
+
+// Identity is an authenticated caller. Subject is the token's service account for OIDC callers.
+type Identity struct {
+	Method  Method
+	Subject string
+}
+
+type identityKey struct{}
+
+// NewContext returns a copy of the context which carries the authenticated identity.
+func NewContext(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the authenticated identity stored in the context, if any.
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// Authenticator authenticates requests using either a static API key or an OIDC ID token.
+type Authenticator struct {
+	verifier *Verifier
+	apiKeys  [][]byte
+}
+
+// New initialises an Authenticator from config. OIDC ID tokens are only accepted if both an audience and a service
+// account are configured.
+func New(conf config.Auth) (*Authenticator, error) {
+	if (conf.Audience == "") != (conf.ServiceAccount == "") {
+		return nil, errors.New("both an OIDC audience and service account must be configured to accept ID tokens")
+	}
+
+	a := &Authenticator{}
+	if conf.Audience != "" {
+		a.verifier = NewVerifier(NewKeySet(conf.JWKSURL), conf.Issuers, conf.Audience, []string{conf.ServiceAccount})
+	}
+	for _, key := range conf.APIKeys {
+		if key != "" {
+			a.apiKeys = append(a.apiKeys, []byte(key))
+		}
+	}
+
+	if a.verifier == nil && len(a.apiKeys) == 0 {
+		log.Printf("no OIDC audience or API keys configured - all authenticated requests will be rejected")
+	}
+	return a, nil
+}
+
+// Middleware rejects requests which do not provide a valid API key via the X-API-Key header or a valid OIDC ID token
+// via the Authorization header. The caller's identity is stored in the request context.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := a.authenticate(r)
+		if err != nil {
+			log.Printf("failed to authenticate request: %s", err)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), id)))
+	})
+}
+
+// authenticate determines the identity of the caller.
+func (a *Authenticator) authenticate(r *http.Request) (Identity, error) {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		for _, k := range a.apiKeys {
+			if subtle.ConstantTimeCompare([]byte(key), k) == 1 {
+				return Identity{Method: APIKey}, nil
+			}
+		}
+		return Identity{}, errors.New("invalid API key")
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" || token == r.Header.Get("Authorization") {
+		return Identity{}, errors.New("no credentials provided")
+	}
+	if a.verifier == nil {
+		return Identity{}, errors.New("ID tokens are not accepted")
+	}
+
+	claims, err := a.verifier.Verify(r.Context(), token)
+	if err != nil {
+		return Identity{}, err
+	}
+	return Identity{Method: OIDC, Subject: claims.Email}, nil
+}
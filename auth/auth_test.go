@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/config"
+)
+
+func TestAuthenticator_Middleware(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+
+	// serve a self-signed JWKS
+	jwks, err := MarshalJWKS("test", &key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to encode JWKS: %s", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		_, _ = w.Write(jwks)
+	}))
+	defer server.Close()
+
+	authenticator, err := New(config.Auth{
+		Audience:       "https://example.com",
+		ServiceAccount: "scheduler@example.iam.gserviceaccount.com",
+		Issuers:        []string{"https://accounts.google.com"},
+		JWKSURL:        server.URL,
+		APIKeys:        []string{"secret"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create authenticator: %s", err)
+	}
+
+	now := time.Now()
+	validClaims := func() Claims {
+		return Claims{
+			Issuer:        "https://accounts.google.com",
+			Subject:       "123",
+			Audience:      []string{"https://example.com"},
+			Expiry:        now.Add(time.Hour).Unix(),
+			IssuedAt:      now.Unix(),
+			Email:         "scheduler@example.iam.gserviceaccount.com",
+			EmailVerified: true,
+		}
+	}
+
+	cases := []struct {
+		name     string
+		key      *rsa.PrivateKey
+		kid      string
+		claims   func(c *Claims)
+		apiKey   string
+		noCreds  bool
+		status   int
+		identity Identity
+	}{
+		{
+			name:     "valid_token",
+			status:   http.StatusOK,
+			identity: Identity{Method: OIDC, Subject: "scheduler@example.iam.gserviceaccount.com"},
+		},
+		{
+			name:   "wrong_audience",
+			claims: func(c *Claims) { c.Audience = []string{"https://other.com"} },
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "wrong_service_account",
+			claims: func(c *Claims) { c.Email = "other@example.iam.gserviceaccount.com" },
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "unverified_email",
+			claims: func(c *Claims) { c.EmailVerified = false },
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "untrusted_issuer",
+			claims: func(c *Claims) { c.Issuer = "https://evil.com" },
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "expired_token",
+			claims: func(c *Claims) { c.Expiry = now.Add(-time.Hour).Unix() },
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "unknown_key",
+			kid:    "unknown",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "invalid_signature",
+			key:    otherKey,
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:     "valid_api_key",
+			apiKey:   "secret",
+			status:   http.StatusOK,
+			identity: Identity{Method: APIKey},
+		},
+		{
+			name:   "invalid_api_key",
+			apiKey: "wrong",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:    "no_credentials",
+			noCreds: true,
+			status:  http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/collect", nil)
+			switch {
+			case tt.apiKey != "":
+				r.Header.Set(APIKeyHeader, tt.apiKey)
+			case !tt.noCreds:
+				signingKey, kid, claims := key, "test", validClaims()
+				if tt.key != nil {
+					signingKey = tt.key
+				}
+				if tt.kid != "" {
+					kid = tt.kid
+				}
+				if tt.claims != nil {
+					tt.claims(&claims)
+				}
+				token, err := Sign(signingKey, kid, claims)
+				if err != nil {
+					t.Fatalf("failed to sign token: %s", err)
+				}
+				r.Header.Set("Authorization", "Bearer "+token)
+			}
+
+			var identity Identity
+			handler := authenticator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				identity, _ = FromContext(r.Context())
+			}))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			// validate status
+			status := w.Result().StatusCode
+			if status != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, status)
+			}
+			if identity != tt.identity {
+				t.Fatalf("expected '%+v', got '%+v'", tt.identity, identity)
+			}
+		})
+	}
+}
+
+func TestNew_PartialOIDCConfig(t *testing.T) {
+	if _, err := New(config.Auth{Audience: "https://example.com"}); err == nil {
+		t.Fatal("expected error for audience without service account")
+	}
+}
@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultKeyTTL is how long keys are cached for if the JWKS response does not specify a max age.
+	defaultKeyTTL = time.Hour
+	// minRefreshInterval limits how often keys are refreshed when a token is signed with an unknown key.
+	minRefreshInterval = time.Minute
+)
+
+var maxAgeRegex = regexp.MustCompile(`max-age=(\d+)`)
+
+// ErrUnknownKey indicates that a token was signed with a key which is not in the key set.
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// jwks is a JSON Web Key Set.
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// jwk is a JSON Web Key. Only RSA keys are supported.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// publicKey decodes the RSA public key.
+func (k jwk) publicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode modulus: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode exponent: %w", err)
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+// KeySet fetches and caches the RSA public keys from a JWKS URL. Both http(s):// and file:// URLs are supported, the
+// latter allowing a self-signed key set to be used locally.
+type KeySet struct {
+	url    string
+	client *http.Client
+	now    func() time.Time
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	expires   time.Time
+	refreshed time.Time
+}
+
+// NewKeySet initialises a KeySet for a JWKS URL. Keys are fetched lazily.
+func NewKeySet(url string) *KeySet {
+	return &KeySet{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+		now:    time.Now,
+	}
+}
+
+// Key returns the key with the provided ID. The keys are refreshed if they have expired, or if the ID is unknown and
+// the keys have not been refreshed recently.
+func (s *KeySet) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	key, ok := s.keys[kid]
+	if ok && now.Before(s.expires) {
+		return key, nil
+	}
+	if !ok && s.keys != nil && now.Before(s.expires) && now.Sub(s.refreshed) < minRefreshInterval {
+		return nil, ErrUnknownKey
+	}
+
+	if err := s.refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	if key, ok = s.keys[kid]; !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// refresh fetches the keys from the JWKS URL.
+func (s *KeySet) refresh(ctx context.Context) error {
+	b, ttl, err := s.fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	set := jwks{}
+	if err := json.Unmarshal(b, &set); err != nil {
+		return fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return fmt.Errorf("failed to decode key %s: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+
+	s.keys = keys
+	s.refreshed = s.now()
+	s.expires = s.refreshed.Add(ttl)
+	return nil
+}
+
+// fetch reads the raw JWKS and determines how long it can be cached for.
+func (s *KeySet) fetch(ctx context.Context) ([]byte, time.Duration, error) {
+	if strings.HasPrefix(s.url, "file://") {
+		b, err := ioutil.ReadFile(strings.TrimPrefix(s.url, "file://"))
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read JWKS file: %w", err)
+		}
+		return b, defaultKeyTTL, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("unexpected JWKS response status: %d", resp.StatusCode)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read JWKS response: %w", err)
+	}
+
+	ttl := defaultKeyTTL
+	if m := maxAgeRegex.FindStringSubmatch(resp.Header.Get("Cache-Control")); m != nil {
+		if seconds, err := strconv.Atoi(m[1]); err == nil {
+			ttl = time.Duration(seconds) * time.Second
+		}
+	}
+	return b, ttl, nil
+}
@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/config"
+)
+
+const (
+	// LoginPath is the path which starts the OIDC browser login.
+	LoginPath = "/login"
+	// LoginCallbackPath is the path which the OIDC provider redirects back to after login.
+	LoginCallbackPath = "/login/callback"
+
+	// sessionCookie is the cookie which holds the verified ID token of a browser login.
+	sessionCookie = "session"
+	// stateCookie is the cookie which holds the state of a login in progress, guarding the callback against CSRF.
+	stateCookie = "login_state"
+	// stateTTL is how long a login in progress can take.
+	stateTTL = 10 * time.Minute
+)
+
+// loginFlow is the OIDC authorization code flow used to log browsers in. The verified ID token is stored in a session
+// cookie, which is accepted in place of an Authorization header until the token expires.
+type loginFlow struct {
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	authURL      string
+	tokenURL     string
+	client       *http.Client
+}
+
+// newLoginFlow initialises a loginFlow from the access config. nil is returned if no client secret and redirect URL
+// are configured, i.e. the browser login is disabled.
+func newLoginFlow(conf config.Access) (*loginFlow, error) {
+	if conf.OIDCClientSecret == "" && conf.OIDCRedirectURL == "" {
+		return nil, nil
+	}
+	if conf.OIDCClientSecret == "" || conf.OIDCRedirectURL == "" {
+		return nil, errors.New("both a client secret and redirect URL must be configured for OIDC browser login")
+	}
+
+	return &loginFlow{
+		clientID:     conf.OIDCAudience,
+		clientSecret: conf.OIDCClientSecret,
+		redirectURL:  conf.OIDCRedirectURL,
+		authURL:      conf.OIDCAuthURL,
+		tokenURL:     conf.OIDCTokenURL,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Login starts the OIDC browser login by redirecting to the provider.
+func (g *ReadGuard) Login(w http.ResponseWriter, r *http.Request) {
+	if g.flow == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("failed to generate login state: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	state := hex.EncodeToString(b)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookie,
+		Value:    state,
+		Path:     LoginCallbackPath,
+		MaxAge:   int(stateTTL.Seconds()),
+		Secure:   g.flow.secure(),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	query := url.Values{
+		"client_id":     {g.flow.clientID},
+		"redirect_uri":  {g.flow.redirectURL},
+		"response_type": {"code"},
+		"scope":         {"openid email"},
+		"state":         {state},
+	}
+	http.Redirect(w, r, g.flow.authURL+"?"+query.Encode(), http.StatusFound)
+}
+
+// LoginCallback completes the OIDC browser login by exchanging the authorization code for an ID token, which is
+// verified and stored in the session cookie.
+func (g *ReadGuard) LoginCallback(w http.ResponseWriter, r *http.Request) {
+	if g.flow == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	query := r.URL.Query()
+	cookie, err := r.Cookie(stateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(query.Get("state"))) != 1 {
+		log.Printf("login callback state does not match")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	code := query.Get("code")
+	if code == "" {
+		log.Printf("login callback has no code: %s", query.Get("error"))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	token, err := g.flow.exchange(r.Context(), code)
+	if err != nil {
+		log.Printf("failed to exchange login code: %s", err)
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	claims, err := g.verifier.Verify(r.Context(), token)
+	if err != nil {
+		log.Printf("failed to verify login token: %s", err)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookie,
+		Path:     LoginCallbackPath,
+		MaxAge:   -1,
+		Secure:   g.flow.secure(),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  time.Unix(claims.Expiry, 0),
+		Secure:   g.flow.secure(),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// exchange exchanges an authorization code for an ID token at the provider's token endpoint.
+func (f *loginFlow) exchange(ctx context.Context, code string) (string, error) {
+	form := url.Values{
+		"code":          {code},
+		"client_id":     {f.clientID},
+		"client_secret": {f.clientSecret},
+		"redirect_uri":  {f.redirectURL},
+		"grant_type":    {"authorization_code"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected token response status: %d", resp.StatusCode)
+	}
+	body := struct {
+		IDToken string `json:"id_token"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("token response has no ID token")
+	}
+	return body.IDToken, nil
+}
+
+// secure determines if cookies should only be sent over HTTPS, i.e. if the service is not being run locally over HTTP.
+func (f *loginFlow) secure() bool {
+	return strings.HasPrefix(f.redirectURL, "https://")
+}
+
+// isNavigation determines if a request is a browser navigation, rather than an API call.
+func isNavigation(r *http.Request) bool {
+	return r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html")
+}
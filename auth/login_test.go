@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/config"
+)
+
+func TestReadGuard_Login(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+
+	// serve a self-signed JWKS
+	jwks, err := MarshalJWKS("test", &key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to encode JWKS: %s", err)
+	}
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(jwks)
+	}))
+	defer jwksServer.Close()
+
+	// serve a token endpoint which exchanges the code "valid" for an ID token
+	now := time.Now()
+	token, err := Sign(key, "test", Claims{
+		Issuer:        "https://accounts.google.com",
+		Subject:       "123",
+		Audience:      []string{"client-id"},
+		Expiry:        now.Add(time.Hour).Unix(),
+		IssuedAt:      now.Unix(),
+		Email:         "user@example.com",
+		EmailVerified: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to sign token: %s", err)
+	}
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "valid" || r.FormValue("client_secret") != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": token})
+	}))
+	defer tokenServer.Close()
+
+	guard, err := NewReadGuard(config.Access{
+		Mode:             AccessOIDC.String(),
+		OIDCAudience:     "client-id",
+		OIDCEmails:       []string{"user@example.com"},
+		OIDCClientSecret: "secret",
+		OIDCRedirectURL:  "https://example.com/login/callback",
+		OIDCAuthURL:      "https://accounts.example.com/auth",
+		OIDCTokenURL:     tokenServer.URL,
+	}, config.Auth{
+		Issuers: []string{"https://accounts.google.com"},
+		JWKSURL: jwksServer.URL,
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to create read guard: %s", err)
+	}
+
+	var identity Identity
+	protected := guard.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, _ = FromContext(r.Context())
+	}))
+
+	// browser navigations are redirected to the login, API calls are not
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "text/html,application/xhtml+xml")
+	w := httptest.NewRecorder()
+	protected.ServeHTTP(w, r)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != LoginPath {
+		t.Fatalf("expected redirect to login, got %d to %q", w.Code, w.Header().Get("Location"))
+	}
+	w = httptest.NewRecorder()
+	protected.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stats", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d for API call, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	// the login redirects to the provider with a state which is remembered in a cookie
+	w = httptest.NewRecorder()
+	guard.Login(w, httptest.NewRequest(http.MethodGet, LoginPath, nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected status %d for login, got %d", http.StatusFound, w.Code)
+	}
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil || !strings.HasPrefix(location.String(), "https://accounts.example.com/auth?") {
+		t.Fatalf("unexpected login redirect: %q", w.Header().Get("Location"))
+	}
+	state := location.Query().Get("state")
+	if location.Query().Get("client_id") != "client-id" || state == "" {
+		t.Fatalf("unexpected login redirect query: %s", location.RawQuery)
+	}
+	pending := findCookie(w.Result().Cookies(), stateCookie)
+	if pending == nil || pending.Value != state {
+		t.Fatalf("expected state cookie %q, got %v", state, pending)
+	}
+
+	cases := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{
+			name:   "state_mismatch",
+			query:  "?code=valid&state=other",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "no_code",
+			query:  "?error=access_denied&state=" + state,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "invalid_code",
+			query:  "?code=invalid&state=" + state,
+			status: http.StatusBadGateway,
+		},
+		{
+			name:   "success",
+			query:  "?code=valid&state=" + state,
+			status: http.StatusFound,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, LoginCallbackPath+tt.query, nil)
+			r.AddCookie(pending)
+			w := httptest.NewRecorder()
+			guard.LoginCallback(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, w.Code)
+			}
+			if tt.status != http.StatusFound {
+				return
+			}
+
+			// the session cookie logs subsequent requests in
+			session := findCookie(w.Result().Cookies(), sessionCookie)
+			if session == nil || session.Value != token || !session.HttpOnly || !session.Secure {
+				t.Fatalf("unexpected session cookie: %v", session)
+			}
+			r = httptest.NewRequest(http.MethodGet, "/api/v1/stats", nil)
+			r.AddCookie(session)
+			w = httptest.NewRecorder()
+			protected.ServeHTTP(w, r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status %d with session, got %d", http.StatusOK, w.Code)
+			}
+			expected := Identity{Method: OIDC, Subject: "user@example.com"}
+			if identity != expected {
+				t.Fatalf("expected identity %+v, got %+v", expected, identity)
+			}
+		})
+	}
+}
+
+func TestNewReadGuard_PartialLoginConfig(t *testing.T) {
+	_, err := NewReadGuard(config.Access{
+		Mode:             AccessOIDC.String(),
+		OIDCAudience:     "client-id",
+		OIDCEmails:       []string{"user@example.com"},
+		OIDCClientSecret: "secret",
+	}, config.Auth{}, nil)
+	if err == nil {
+		t.Fatal("expected error for login without a redirect URL")
+	}
+}
+
+// findCookie returns the cookie with the provided name, or nil if there is none.
+func findCookie(cookies []*http.Cookie, name string) *http.Cookie {
+	for _, c := range cookies {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// clockSkew is the leeway allowed when validating token timestamps.
+const clockSkew = time.Minute
+
+// Claims are the ID token claims used to authenticate a caller.
+type Claims struct {
+	Issuer        string   `json:"iss"`
+	Subject       string   `json:"sub"`
+	Audience      audience `json:"aud"`
+	Expiry        int64    `json:"exp"`
+	IssuedAt      int64    `json:"iat"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+}
+
+// audience is an ID token audience, which may be encoded as either a single string or a list of strings.
+type audience []string
+
+// UnmarshalJSON decodes either a string or a list of strings.
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(b, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+// keyFetcher provides the public key for a key ID.
+type keyFetcher interface {
+	Key(ctx context.Context, kid string) (*rsa.PublicKey, error)
+}
+
+// Verifier verifies RS256 signed OIDC ID tokens, such as the Google-signed tokens sent by Cloud Scheduler. Tokens must
+// be issued by one of the trusted issuers for the expected audience, and belong to one of the expected emails, i.e. a
+// service account.
+type Verifier struct {
+	keys     keyFetcher
+	issuers  []string
+	audience string
+	emails   []string
+	now      func() time.Time
+}
+
+// NewVerifier initialises a Verifier.
+func NewVerifier(keys *KeySet, issuers []string, audience string, emails []string) *Verifier {
+	return &Verifier{
+		keys:     keys,
+		issuers:  issuers,
+		audience: audience,
+		emails:   emails,
+		now:      time.Now,
+	}
+}
+
+// Verify verifies the token's signature and claims.
+func (v *Verifier) Verify(ctx context.Context, token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, errors.New("malformed token")
+	}
+
+	header := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Claims{}, fmt.Errorf("failed to decode token header: %w", err)
+	}
+	if header.Alg != "RS256" {
+		return Claims{}, fmt.Errorf("unsupported signing algorithm: %s", header.Alg)
+	}
+
+	key, err := v.keys.Key(ctx, header.Kid)
+	if err != nil {
+		return Claims{}, fmt.Errorf("failed to get signing key: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, fmt.Errorf("failed to decode token signature: %w", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return Claims{}, fmt.Errorf("invalid token signature: %w", err)
+	}
+
+	claims := Claims{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Claims{}, fmt.Errorf("failed to decode token claims: %w", err)
+	}
+	if err := v.validate(claims); err != nil {
+		return Claims{}, err
+	}
+	return claims, nil
+}
+
+// validate validates the token claims.
+func (v *Verifier) validate(claims Claims) error {
+	now := v.now()
+	if now.After(time.Unix(claims.Expiry, 0).Add(clockSkew)) {
+		return errors.New("token has expired")
+	}
+	if now.Add(clockSkew).Before(time.Unix(claims.IssuedAt, 0)) {
+		return errors.New("token was issued in the future")
+	}
+
+	if !contains(v.issuers, claims.Issuer) {
+		return fmt.Errorf("untrusted token issuer: %s", claims.Issuer)
+	}
+	if !contains(claims.Audience, v.audience) {
+		return fmt.Errorf("unexpected token audience: %s", strings.Join(claims.Audience, ","))
+	}
+	if !claims.EmailVerified || !contains(v.emails, claims.Email) {
+		return fmt.Errorf("unexpected token email: %s", claims.Email)
+	}
+	return nil
+}
+
+// contains determines if list contains v.
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeSegment decodes a base64url encoded JSON token segment.
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
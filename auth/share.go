@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/config"
+)
+
+// minShareSecretLength is the minimum length of the secret used to sign share tokens.
+const minShareSecretLength = 32
+
+var (
+	// ErrInvalidShare indicates that a share token is malformed or has an invalid signature.
+	ErrInvalidShare = errors.New("invalid share token")
+	// ErrExpiredShare indicates that a share token has expired.
+	ErrExpiredShare = errors.New("share token has expired")
+	// ErrOutOfScope indicates that a request is outside of the scope granted by a share token.
+	ErrOutOfScope = errors.New("request is outside of share scope")
+)
+
+// Share is the read-only scope granted by a share token, i.e. a single user's data for a single aggregation over a date
+// range. The user is empty in single-user mode.
+type Share struct {
+	User        string    `json:"user,omitempty"`
+	Aggregation string    `json:"agg"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Expiry      time.Time `json:"exp"`
+}
+
+// Restrict restricts query API parameters to the share's scope. The user and aggregation are set to the share's user
+// and aggregation, and the start and end times are clamped to the share's date range.
+func (s Share) Restrict(query url.Values) error {
+	if user := query.Get("user"); user != "" && user != s.User {
+		return fmt.Errorf("%w: user %s", ErrOutOfScope, user)
+	}
+	query.Set("user", s.User)
+
+	if agg := query.Get("aggregation"); agg != "" && agg != s.Aggregation {
+		return fmt.Errorf("%w: aggregation %s", ErrOutOfScope, agg)
+	}
+	query.Set("aggregation", s.Aggregation)
+
+	start, err := time.Parse(time.RFC3339, query.Get("start_time"))
+	if err != nil || start.Before(s.Start) {
+		start = s.Start
+	}
+	end, err := time.Parse(time.RFC3339, query.Get("end_time"))
+	if err != nil || end.After(s.End) {
+		end = s.End
+	}
+	if start.After(end) {
+		return fmt.Errorf("%w: %s to %s", ErrOutOfScope, start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+
+	query.Set("start_time", start.Format(time.RFC3339))
+	query.Set("end_time", end.Format(time.RFC3339))
+	return nil
+}
+
+// ShareSigner signs and verifies HMAC-SHA256 share tokens.
+type ShareSigner struct {
+	secret []byte
+	maxTTL time.Duration
+	now    func() time.Time
+}
+
+// NewShareSigner initialises a ShareSigner. The secret must be at least 32 characters.
+func NewShareSigner(conf config.Share) (*ShareSigner, error) {
+	if len(conf.Secret) < minShareSecretLength {
+		return nil, fmt.Errorf("share secret must be at least %d characters", minShareSecretLength)
+	}
+	if conf.MaxTTLHours <= 0 {
+		return nil, errors.New("share max TTL must be positive")
+	}
+
+	return &ShareSigner{
+		secret: []byte(conf.Secret),
+		maxTTL: time.Duration(conf.MaxTTLHours) * time.Hour,
+		now:    time.Now,
+	}, nil
+}
+
+// MaxTTL is the maximum duration a share token can be valid for.
+func (s *ShareSigner) MaxTTL() time.Duration {
+	return s.maxTTL
+}
+
+// Sign creates a share token.
+func (s *ShareSigner) Sign(share Share) (string, error) {
+	payload, err := json.Marshal(share)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode share: %w", err)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.mac(encoded)), nil
+}
+
+// Verify verifies the token's signature and expiry.
+func (s *ShareSigner) Verify(token string) (Share, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return Share{}, ErrInvalidShare
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(signature, s.mac(parts[0])) {
+		return Share{}, ErrInvalidShare
+	}
+
+	share := Share{}
+	if err := decodeSegment(parts[0], &share); err != nil {
+		return Share{}, ErrInvalidShare
+	}
+	if !s.now().Before(share.Expiry) {
+		return Share{}, ErrExpiredShare
+	}
+	return share, nil
+}
+
+// mac calculates the HMAC-SHA256 of the encoded payload.
+func (s *ShareSigner) mac(payload string) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
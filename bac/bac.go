@@ -0,0 +1,108 @@
+package bac
+
+import (
+	"sort"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/config"
+	"github.com/jemgunay/canlendar-graph/storage"
+	"github.com/jemgunay/canlendar-graph/units"
+)
+
+const (
+	// eliminationRate is the average rate at which BAC falls, in percent per hour.
+	eliminationRate = 0.015
+	// distribution ratios (Widmark r) for men and women.
+	maleDistributionRatio   = 0.68
+	femaleDistributionRatio = 0.55
+)
+
+// Point is an estimated BAC (percent) at a point in time.
+type Point struct {
+	Time time.Time `json:"time"`
+	BAC  float64   `json:"bac"`
+}
+
+// Timeline is an estimated BAC curve. ZeroAt is the estimated time at which BAC returns to zero.
+type Timeline struct {
+	Points []Point   `json:"points"`
+	Peak   Point     `json:"peak"`
+	ZeroAt time.Time `json:"zero_at"`
+}
+
+// Estimator estimates BAC using the Widmark formula for a given body profile.
+type Estimator struct {
+	weightKg float64
+	ratio    float64
+}
+
+// New initialises an Estimator from the user's body profile. The male distribution ratio is used unless the sex is
+// female.
+func New(conf config.Body) Estimator {
+	ratio := maleDistributionRatio
+	if conf.Sex == "female" {
+		ratio = femaleDistributionRatio
+	}
+
+	return Estimator{
+		weightKg: conf.WeightKg,
+		ratio:    ratio,
+	}
+}
+
+// Estimate estimates a BAC curve from a set of records, sampled every step from the first record until BAC returns to
+// zero. Each record's alcohol is assumed to be absorbed immediately, and BAC is eliminated at a constant rate from the
+// first record onwards. Returns an empty timeline if there are no records or the body weight is unknown.
+func (e Estimator) Estimate(records []storage.Record, step time.Duration) Timeline {
+	if len(records) == 0 || e.weightKg <= 0 || step <= 0 {
+		return Timeline{}
+	}
+
+	sorted := make([]storage.Record, len(records))
+	copy(sorted, records)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Time.Before(sorted[j].Time)
+	})
+
+	var timeline Timeline
+	var bac float64
+	next := 0
+	for t := sorted[0].Time; ; t = t.Add(step) {
+		// eliminate alcohol since the previous sample, determining exactly when BAC reached zero if it did so
+		if len(timeline.Points) > 0 && bac > 0 {
+			prev := bac
+			bac -= eliminationRate * step.Hours()
+			if bac <= 0 {
+				bac = 0
+				hoursToZero := prev / eliminationRate
+				timeline.ZeroAt = t.Add(-step).Add(time.Duration(hoursToZero * float64(time.Hour)))
+			}
+		}
+
+		// absorb all drinks consumed by this sample
+		for ; next < len(sorted) && !sorted[next].Time.After(t); next++ {
+			bac += e.contribution(sorted[next].Units())
+			timeline.ZeroAt = time.Time{}
+		}
+
+		point := Point{Time: t, BAC: bac}
+		timeline.Points = append(timeline.Points, point)
+		if bac > timeline.Peak.BAC {
+			timeline.Peak = point
+		}
+
+		if bac == 0 && next == len(sorted) {
+			if timeline.ZeroAt.IsZero() {
+				timeline.ZeroAt = t
+			}
+			return timeline
+		}
+	}
+}
+
+// contribution returns the BAC (percent) contributed by the given UK units: the grams of alcohol divided by the body
+// weight in grams multiplied by the distribution ratio.
+func (e Estimator) contribution(ukUnits float64) float64 {
+	grams := ukUnits * units.UK.Grams()
+	return grams / (e.weightKg * 1000 * e.ratio) * 100
+}
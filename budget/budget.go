@@ -0,0 +1,122 @@
+package budget
+
+import (
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/config"
+	"github.com/jemgunay/canlendar-graph/guideline"
+	"github.com/jemgunay/canlendar-graph/storage"
+)
+
+// Projection describes how the period-end total is projected from the units consumed so far.
+type Projection string
+
+const (
+	// Linear projects the units consumed so far at the same rate over the remainder of the period.
+	Linear Projection = "linear"
+	// WeekdayWeighted projects the remainder of the period using the historical average units of each weekday.
+	WeekdayWeighted Projection = "weekday"
+)
+
+// String gets the projection name.
+func (p Projection) String() string {
+	return string(p)
+}
+
+// IsValid determines if the projection is supported.
+func (p Projection) IsValid() bool {
+	switch p {
+	case Linear, WeekdayWeighted:
+		return true
+	default:
+		return false
+	}
+}
+
+// Planner determines the unit budget for a period.
+type Planner struct {
+	weeklyUnits  float64
+	monthlyUnits float64
+	projection   Projection
+	carryOver    CarryOver
+}
+
+// New initialises a Planner from config. Unsupported projections fall back to the Linear projection, and invalid
+// carry-overs fall back to no carry-over.
+func New(conf config.Budget) Planner {
+	projection := Projection(conf.Projection)
+	if !projection.IsValid() {
+		projection = Linear
+	}
+
+	carryOver := CarryOver{
+		Fraction: conf.CarryOverFraction,
+		Cap:      conf.CarryOverCap,
+	}
+	if !carryOver.IsValid() {
+		carryOver = CarryOver{}
+	}
+
+	return Planner{
+		weeklyUnits:  conf.WeeklyUnits,
+		monthlyUnits: conf.MonthlyUnits,
+		projection:   projection,
+		carryOver:    carryOver,
+	}
+}
+
+// Projection returns the default projection.
+func (p Planner) Projection() Projection {
+	return p.projection
+}
+
+// CarryOver returns the default carry-over of unused weekly units.
+func (p Planner) CarryOver() CarryOver {
+	return p.carryOver
+}
+
+// Budget returns the configured budget in UK units for the aggregation period starting at start. If no budget is
+// configured for the aggregation, the guideline profile limit for the period is used.
+func (p Planner) Budget(aggregation storage.Aggregation, profile guideline.Profile, start time.Time) float64 {
+	switch {
+	case aggregation == storage.Week && p.weeklyUnits > 0:
+		return p.weeklyUnits
+	case aggregation == storage.Month && p.monthlyUnits > 0:
+		return p.monthlyUnits
+	default:
+		return profile.Limit(aggregation, start)
+	}
+}
+
+// ProjectLinear projects the period-end total by extrapolating the units consumed between start and now to the end of
+// the period.
+func ProjectLinear(consumed float64, start, end, now time.Time) float64 {
+	elapsed := now.Sub(start)
+	if elapsed <= 0 {
+		return consumed
+	}
+	if now.After(end) {
+		return consumed
+	}
+	return consumed * float64(end.Sub(start)) / float64(elapsed)
+}
+
+// ProjectWeekdayWeighted projects the period-end total by adding the average units for the weekday of each remaining
+// day of the period to the units consumed so far. The averages are calculated from a set of historical day aggregated
+// plots. The current day is considered to be consumed.
+func ProjectWeekdayWeighted(consumed float64, history []storage.Plot, end, now time.Time) float64 {
+	var totals, counts [7]float64
+	for _, p := range history {
+		weekday := time.UnixMilli(p.X).UTC().Weekday()
+		totals[weekday] += p.Y
+		counts[weekday]++
+	}
+
+	projected := consumed
+	for d := storage.Day.PeriodEnd(now); d.Before(end); d = d.AddDate(0, 0, 1) {
+		if weekday := d.Weekday(); counts[weekday] > 0 {
+			projected += totals[weekday] / counts[weekday]
+		}
+	}
+	return projected
+}
@@ -0,0 +1,78 @@
+package budget
+
+import (
+	"math"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/storage"
+)
+
+// CarryOver describes how much of a week's unused budget rolls over to the following week. Fraction is the fraction of
+// the unused units carried over, and Cap is the maximum number of units (in UK units) that can be carried over.
+type CarryOver struct {
+	Fraction float64 `json:"fraction"`
+	Cap      float64 `json:"cap"`
+}
+
+// IsValid determines if the carry-over is valid.
+func (c CarryOver) IsValid() bool {
+	return c.Fraction >= 0 && c.Fraction <= 1 && c.Cap >= 0
+}
+
+// Apply returns the units carried over from a week which ended with the provided balance. Overspending is not carried
+// over.
+func (c CarryOver) Apply(balance float64) float64 {
+	if balance <= 0 {
+		return 0
+	}
+	return math.Min(balance*c.Fraction, c.Cap)
+}
+
+// LedgerWeek is a single week of a unit ledger. The allowance is the week's budget plus the units carried over from
+// the previous week, and the balance is the allowance less the units consumed.
+type LedgerWeek struct {
+	Start       time.Time `json:"start"`
+	Budget      float64   `json:"budget"`
+	CarriedOver float64   `json:"carried_over"`
+	Allowance   float64   `json:"allowance"`
+	Units       float64   `json:"units"`
+	Balance     float64   `json:"balance"`
+}
+
+// Ledger calculates a running unit ledger for each week between start and end from a set of week aggregated plots,
+// where each week has the same budget and unused units are carried over to the following week.
+func Ledger(weeks []storage.Plot, weeklyBudget float64, carry CarryOver, start, end time.Time) []LedgerWeek {
+	units := make(map[time.Time]float64, len(weeks))
+	for _, w := range weeks {
+		units[storage.Week.PeriodStart(time.UnixMilli(w.X))] += w.Y
+	}
+
+	var ledger []LedgerWeek
+	var carried float64
+	for s := storage.Week.PeriodStart(start); s.Before(end); s = storage.Week.PeriodEnd(s) {
+		w := LedgerWeek{
+			Start:       s,
+			Budget:      weeklyBudget,
+			CarriedOver: carried,
+			Allowance:   weeklyBudget + carried,
+			Units:       units[s],
+		}
+		w.Balance = w.Allowance - w.Units
+		ledger = append(ledger, w)
+
+		carried = carry.Apply(w.Balance)
+	}
+	return ledger
+}
+
+// Balances returns the balance of each week of a ledger as week aggregated plots.
+func Balances(ledger []LedgerWeek) []storage.Plot {
+	balances := make([]storage.Plot, 0, len(ledger))
+	for _, w := range ledger {
+		balances = append(balances, storage.Plot{
+			X: w.Start.UnixMilli(),
+			Y: w.Balance,
+		})
+	}
+	return balances
+}
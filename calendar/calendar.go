@@ -6,6 +6,7 @@ import (
 	"log"
 	"regexp"
 	"strconv"
+	"sync"
 	"time"
 
 	"golang.org/x/net/context"
@@ -18,22 +19,87 @@ import (
 // MaxRecommendedWeeklyUnits is the recommended maximum weekly unit intake.
 const MaxRecommendedWeeklyUnits = 14
 
-// Fetcher fetches all calendar events since startTime in an iterable format.
+// Fetcher fetches all calendar events between startTime and endTime in an iterable format. Events are fetched up to
+// the present and beyond if endTime is zero.
 type Fetcher interface {
-	Fetch(ctx context.Context, startTime time.Time) (EventIterator, error)
+	Fetch(ctx context.Context, startTime, endTime time.Time) (EventIterator, error)
 }
 
-var _ Fetcher = (*Requester)(nil)
+// Watcher registers and stops watch channels, which push a notification to the channel's address whenever a calendar's
+// events change.
+type Watcher interface {
+	Watch(ctx context.Context, channel Channel) (Channel, error)
+	StopWatch(ctx context.Context, channel Channel) error
+}
+
+// Provider provides the Fetcher for a calendar, where events are read in the provided time zone, and the Watcher for a
+// calendar.
+type Provider interface {
+	Fetcher(calendarID, timezone string) (Fetcher, error)
+	Watcher(calendarID string) (Watcher, error)
+}
+
+var (
+	_ Fetcher  = (*Requester)(nil)
+	_ Watcher  = (*Requester)(nil)
+	_ Provider = (*Pool)(nil)
+)
+
+// Pool is a Provider which lazily creates a Requester for each calendar on first use, and reuses it thereafter.
+type Pool struct {
+	isLocal bool
+
+	mu         sync.Mutex
+	requesters map[string]*Requester
+}
+
+// NewPool initialises a Pool. See New for how credentials are read.
+func NewPool(isLocal bool) *Pool {
+	return &Pool{
+		isLocal:    isLocal,
+		requesters: make(map[string]*Requester),
+	}
+}
+
+// Fetcher returns the Requester for a calendar ID or name, creating it if it does not yet exist.
+func (p *Pool) Fetcher(calendarID, timezone string) (Fetcher, error) {
+	return p.requester(calendarID, timezone)
+}
+
+// Watcher returns the Requester for a calendar ID or name, creating it if it does not yet exist.
+func (p *Pool) Watcher(calendarID string) (Watcher, error) {
+	return p.requester(calendarID, "")
+}
+
+// requester returns the Requester for a calendar ID or name and time zone, creating it if it does not yet exist.
+func (p *Pool) requester(calendarID, timezone string) (*Requester, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := calendarID + "|" + timezone
+	if r, ok := p.requesters[key]; ok {
+		return r, nil
+	}
+
+	r, err := New(calendarID, timezone, p.isLocal)
+	if err != nil {
+		return nil, err
+	}
+	p.requesters[key] = r
+	return r, nil
+}
 
 // Requester is a HTTP Fetcher for collecting calendar events via the Google Calendar API.
 type Requester struct {
 	calendarID string
+	timezone   string
 	service    *gcal.Service
 }
 
-// New initialises a new Requester for a given Google calendar name. It supports reading credentials from a file (for
-// local dev) or from env defaults (hosted via a CSP).
-func New(calendarName string, isLocal bool) (*Requester, error) {
+// New initialises a new Requester for a given Google calendar ID or name. Events are read in the provided time zone,
+// or in each event's own time zone if none is provided. It supports reading credentials from a file (for local dev) or
+// from env defaults (hosted via a CSP).
+func New(calendar, timezone string, isLocal bool) (*Requester, error) {
 	options := []option.ClientOption{
 		option.WithScopes(gcal.CalendarReadonlyScope),
 	}
@@ -55,10 +121,10 @@ func New(calendarName string, isLocal bool) (*Requester, error) {
 		return nil, fmt.Errorf("unable to retrieve calendar list: %w", err)
 	}
 
-	// iterate over all calendars and locate the corresponding ID for the target calendar name
+	// iterate over all calendars and locate the corresponding ID for the target calendar ID or name
 	var calendarID string
 	for _, item := range list.Items {
-		if item.Summary == calendarName {
+		if item.Id == calendar || item.Summary == calendar {
 			calendarID = item.Id
 			break
 		}
@@ -66,27 +132,34 @@ func New(calendarName string, isLocal bool) (*Requester, error) {
 
 	// validate that calendar ID was found for target calendar
 	if calendarID == "" {
-		return nil, fmt.Errorf("failed to find ID for the '%s' calendar", calendarName)
+		return nil, fmt.Errorf("failed to find ID for the '%s' calendar", calendar)
 	}
 
 	return &Requester{
 		calendarID: calendarID,
+		timezone:   timezone,
 		service:    service,
 	}, nil
 }
 
-// Event represents a processed alcohol unit calendar event.
+// Event represents a processed alcohol unit calendar event. Unknown is set if the unit amount was specified as unknown
+// in the event summary, in which case Units is zero and should be imputed by the consumer. Timed is set if the event
+// has a time of day, in which case Date is in the event's local time zone. Otherwise, Date is midnight UTC. ID is the
+// calendar's ID for the event, which distinguishes events at the same time.
 type Event struct {
-	Date  time.Time
-	Units float64
+	ID      string
+	Date    time.Time
+	Units   float64
+	Unknown bool
+	Timed   bool
 }
 
-// ErrNoEventsFound indicates that no events for the given calendar could be found within the specified start time and
-// the current time.
+// ErrNoEventsFound indicates that no events for the given calendar could be found within the specified time range.
 var ErrNoEventsFound = errors.New("no events found")
 
-// Fetch fetches a set of events for a given calendar name timestamped after the provided startTime.
-func (r *Requester) Fetch(ctx context.Context, startTime time.Time) (EventIterator, error) {
+// Fetch fetches a set of events for a given calendar name which end after the provided startTime and, if an endTime is
+// provided, start before the endTime.
+func (r *Requester) Fetch(ctx context.Context, startTime, endTime time.Time) (EventIterator, error) {
 	// request all Events for target calendar
 	req := r.service.Events.List(r.calendarID).
 		TimeMin(startTime.Format(time.RFC3339)).
@@ -95,31 +168,86 @@ func (r *Requester) Fetch(ctx context.Context, startTime time.Time) (EventIterat
 		OrderBy("startTime").
 		MaxResults(2500).
 		Context(ctx)
+	if !endTime.IsZero() {
+		req = req.TimeMax(endTime.Format(time.RFC3339))
+	}
+	if r.timezone != "" {
+		req = req.TimeZone(r.timezone)
+	}
 
-	events, err := req.Do()
+	// read every page of events
+	iter := &Iterator{}
+	err := req.Pages(ctx, func(page *gcal.Events) error {
+		iter.items = append(iter.items, page.Items...)
+		iter.pages++
+		return nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("unable to retrieve events: %w", err)
 	}
 
-	if len(events.Items) == 0 {
+	if len(iter.items) == 0 {
 		return nil, ErrNoEventsFound
 	}
 
-	return &Iterator{
-		events: events,
-	}, nil
+	return iter, nil
+}
+
+// Channel is a watch channel, which notifies Address of changes to a calendar's events until it expires. The token is
+// sent with every notification, allowing the receiver to verify that the notification is genuine.
+type Channel struct {
+	ID         string    `json:"id"`
+	ResourceID string    `json:"resource_id,omitempty"`
+	Address    string    `json:"address"`
+	Token      string    `json:"-"`
+	Expiry     time.Time `json:"expiry"`
+}
+
+// Watch registers a watch channel for the calendar's events, returning the channel with the resource ID and expiry
+// assigned by the Google Calendar API. The expiry may be earlier than requested.
+func (r *Requester) Watch(ctx context.Context, channel Channel) (Channel, error) {
+	resp, err := r.service.Events.Watch(r.calendarID, &gcal.Channel{
+		Id:         channel.ID,
+		Type:       "web_hook",
+		Address:    channel.Address,
+		Token:      channel.Token,
+		Expiration: channel.Expiry.UnixMilli(),
+	}).Context(ctx).Do()
+	if err != nil {
+		return Channel{}, fmt.Errorf("unable to watch events: %w", err)
+	}
+
+	channel.ResourceID = resp.ResourceId
+	if resp.Expiration > 0 {
+		channel.Expiry = time.UnixMilli(resp.Expiration).UTC()
+	}
+	return channel, nil
 }
 
-// EventIterator is a set of Event results, where Next returns the next event and Count returns the total number of
-// events.
+// StopWatch stops a watch channel, such that no further notifications are sent.
+func (r *Requester) StopWatch(ctx context.Context, channel Channel) error {
+	err := r.service.Channels.Stop(&gcal.Channel{
+		Id:         channel.ID,
+		ResourceId: channel.ResourceID,
+	}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("unable to stop watch channel: %w", err)
+	}
+	return nil
+}
+
+// EventIterator is a set of Event results, where Next returns the next event, Count returns the total number of events
+// and Pages returns the number of pages the events were fetched in.
 type EventIterator interface {
 	Next() (Event, error)
 	Count() int
+	Pages() int
 }
 
 // Iterator is an iterable layer of abstraction above Google Calendar API events.
 type Iterator struct {
-	events  *gcal.Events
+	items   []*gcal.Event
+	pages   int
 	current int
 }
 
@@ -128,36 +256,38 @@ var ErrNoMoreEvents = errors.New("no more events to iterate over")
 
 // Next returns the next calendar event, processed into alcohol units.
 func (i *Iterator) Next() (Event, error) {
-	if i.current == len(i.events.Items) {
+	if i.current == len(i.items) {
 		return Event{}, ErrNoMoreEvents
 	}
-	ev, err := processEvent(i.events.Items[i.current])
-	if err != nil {
-		return ev, err
-	}
-
+	// advance past events which fail to process such that iteration continues
+	item := i.items[i.current]
 	i.current++
-	return ev, nil
+	return processEvent(item)
 }
 
 // Count returns the total count of iterable items.
 func (i *Iterator) Count() int {
-	return len(i.events.Items)
+	return len(i.items)
+}
+
+// Pages returns the number of pages the items were fetched in.
+func (i *Iterator) Pages() int {
+	return i.pages
 }
 
 var summaryUnitsRegex = regexp.MustCompile(`(?m)[\d?]*\.?\d*`)
 
-// processEvent processes the date and number of units from the calendar event summary. Sets the unit count to the
-// maximum recommended weekly volume the unit amount was specified as unknown in the event summary, i.e. "?" instead of
-// a number.
+// processEvent processes the date and number of units from the calendar event summary. Flags the event as unknown if
+// the unit amount was specified as unknown in the event summary, i.e. "?" instead of a number.
 func processEvent(event *gcal.Event) (Event, error) {
-	ev := Event{}
+	ev := Event{ID: event.Id}
 
 	var err error
 	// parse date from string
 	switch {
 	case event.Start.DateTime != "":
 		ev.Date, err = time.Parse(time.RFC3339, event.Start.DateTime)
+		ev.Timed = true
 	case event.Start.Date != "":
 		ev.Date, err = time.Parse("2006-01-02", event.Start.Date)
 	default:
@@ -173,8 +303,8 @@ func processEvent(event *gcal.Event) (Event, error) {
 		// invalid summary provided
 		return ev, fmt.Errorf("failed to find a units number for event: %s", event.Summary)
 	case "?":
-		// default unknown units to the weekly maximum
-		ev.Units = MaxRecommendedWeeklyUnits
+		// leave unknown units to be imputed
+		ev.Unknown = true
 	default:
 		// parse number of units into float
 		if ev.Units, err = strconv.ParseFloat(match, 64); err != nil {
@@ -37,18 +37,123 @@ func (m *MockFetcher) EXPECT() *MockFetcherMockRecorder {
 }
 
 // Fetch mocks base method.
-func (m *MockFetcher) Fetch(ctx context.Context, startTime time.Time) (calendar.EventIterator, error) {
+func (m *MockFetcher) Fetch(ctx context.Context, startTime, endTime time.Time) (calendar.EventIterator, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Fetch", ctx, startTime)
+	ret := m.ctrl.Call(m, "Fetch", ctx, startTime, endTime)
 	ret0, _ := ret[0].(calendar.EventIterator)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Fetch indicates an expected call of Fetch.
-func (mr *MockFetcherMockRecorder) Fetch(ctx, startTime interface{}) *gomock.Call {
+func (mr *MockFetcherMockRecorder) Fetch(ctx, startTime, endTime interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Fetch", reflect.TypeOf((*MockFetcher)(nil).Fetch), ctx, startTime)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Fetch", reflect.TypeOf((*MockFetcher)(nil).Fetch), ctx, startTime, endTime)
+}
+
+// MockWatcher is a mock of Watcher interface.
+type MockWatcher struct {
+	ctrl     *gomock.Controller
+	recorder *MockWatcherMockRecorder
+}
+
+// MockWatcherMockRecorder is the mock recorder for MockWatcher.
+type MockWatcherMockRecorder struct {
+	mock *MockWatcher
+}
+
+// NewMockWatcher creates a new mock instance.
+func NewMockWatcher(ctrl *gomock.Controller) *MockWatcher {
+	mock := &MockWatcher{ctrl: ctrl}
+	mock.recorder = &MockWatcherMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWatcher) EXPECT() *MockWatcherMockRecorder {
+	return m.recorder
+}
+
+// StopWatch mocks base method.
+func (m *MockWatcher) StopWatch(ctx context.Context, channel calendar.Channel) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StopWatch", ctx, channel)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StopWatch indicates an expected call of StopWatch.
+func (mr *MockWatcherMockRecorder) StopWatch(ctx, channel interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StopWatch", reflect.TypeOf((*MockWatcher)(nil).StopWatch), ctx, channel)
+}
+
+// Watch mocks base method.
+func (m *MockWatcher) Watch(ctx context.Context, channel calendar.Channel) (calendar.Channel, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Watch", ctx, channel)
+	ret0, _ := ret[0].(calendar.Channel)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Watch indicates an expected call of Watch.
+func (mr *MockWatcherMockRecorder) Watch(ctx, channel interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Watch", reflect.TypeOf((*MockWatcher)(nil).Watch), ctx, channel)
+}
+
+// MockProvider is a mock of Provider interface.
+type MockProvider struct {
+	ctrl     *gomock.Controller
+	recorder *MockProviderMockRecorder
+}
+
+// MockProviderMockRecorder is the mock recorder for MockProvider.
+type MockProviderMockRecorder struct {
+	mock *MockProvider
+}
+
+// NewMockProvider creates a new mock instance.
+func NewMockProvider(ctrl *gomock.Controller) *MockProvider {
+	mock := &MockProvider{ctrl: ctrl}
+	mock.recorder = &MockProviderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockProvider) EXPECT() *MockProviderMockRecorder {
+	return m.recorder
+}
+
+// Fetcher mocks base method.
+func (m *MockProvider) Fetcher(calendarID, timezone string) (calendar.Fetcher, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Fetcher", calendarID, timezone)
+	ret0, _ := ret[0].(calendar.Fetcher)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Fetcher indicates an expected call of Fetcher.
+func (mr *MockProviderMockRecorder) Fetcher(calendarID, timezone interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Fetcher", reflect.TypeOf((*MockProvider)(nil).Fetcher), calendarID, timezone)
+}
+
+// Watcher mocks base method.
+func (m *MockProvider) Watcher(calendarID string) (calendar.Watcher, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Watcher", calendarID)
+	ret0, _ := ret[0].(calendar.Watcher)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Watcher indicates an expected call of Watcher.
+func (mr *MockProviderMockRecorder) Watcher(calendarID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Watcher", reflect.TypeOf((*MockProvider)(nil).Watcher), calendarID)
 }
 
 // MockEventIterator is a mock of EventIterator interface.
@@ -102,3 +207,17 @@ func (mr *MockEventIteratorMockRecorder) Next() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Next", reflect.TypeOf((*MockEventIterator)(nil).Next))
 }
+
+// Pages mocks base method.
+func (m *MockEventIterator) Pages() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Pages")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Pages indicates an expected call of Pages.
+func (mr *MockEventIteratorMockRecorder) Pages() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pages", reflect.TypeOf((*MockEventIterator)(nil).Pages))
+}
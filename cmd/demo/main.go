@@ -6,26 +6,75 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/jemgunay/canlendar-graph/api"
+	"github.com/jemgunay/canlendar-graph/auth"
 	"github.com/jemgunay/canlendar-graph/config"
+	goalfile "github.com/jemgunay/canlendar-graph/goal/file"
 	"github.com/jemgunay/canlendar-graph/storage"
+	taperfile "github.com/jemgunay/canlendar-graph/taper/file"
 )
 
 func main() {
 	conf := config.New()
-	apiHandlers := api.New(&demoStore{}, nil)
+
+	var shareSigner *auth.ShareSigner
+	if conf.Share.Secret != "" {
+		var err error
+		if shareSigner, err = auth.NewShareSigner(conf.Share); err != nil {
+			log.Printf("failed to create share signer: %s", err)
+			os.Exit(1)
+		}
+	}
+	readGuard, err := auth.NewReadGuard(conf.Access, conf.Auth, shareSigner)
+	if err != nil {
+		log.Printf("failed to create read guard: %s", err)
+		os.Exit(1)
+	}
+
+	apiHandlers := api.New(&demoStore{}, nil,
+		api.WithGoalStore(goalfile.New(conf.Goals)),
+		api.WithTaperStore(taperfile.New(conf.Taper)),
+		api.WithShareSigner(shareSigner),
+	)
 
 	router := mux.NewRouter()
 	router.Use(allowCORSMiddleware)
-	router.HandleFunc("/api/v1/query", apiHandlers.Query).Methods(http.MethodGet)
+	// API handlers and dashboard accessible via share links
+	shareRouter := router.NewRoute().Subrouter()
+	shareRouter.Use(readGuard.ShareMiddleware, apiHandlers.UserMiddleware)
+	shareRouter.HandleFunc("/api/v1/query", apiHandlers.Query).Methods(http.MethodGet)
+
+	// API handlers protected by login
+	readRouter := router.NewRoute().Subrouter()
+	readRouter.Use(readGuard.Middleware, apiHandlers.UserMiddleware)
+	readRouter.HandleFunc("/api/v1/stats", apiHandlers.Stats).Methods(http.MethodGet)
+	readRouter.HandleFunc("/api/v1/streaks", apiHandlers.Streaks).Methods(http.MethodGet)
+	readRouter.HandleFunc("/api/v1/budget", apiHandlers.Budget).Methods(http.MethodGet)
+	readRouter.HandleFunc("/api/v1/bank", apiHandlers.Bank).Methods(http.MethodGet)
+	readRouter.HandleFunc("/api/v1/bank/balance", apiHandlers.BankBalance).Methods(http.MethodGet)
+	readRouter.HandleFunc("/api/v1/compare", apiHandlers.Compare).Methods(http.MethodGet)
+	readRouter.HandleFunc("/api/v1/heatmap", apiHandlers.Heatmap).Methods(http.MethodGet)
+	readRouter.HandleFunc("/api/v1/distribution", apiHandlers.Distribution).Methods(http.MethodGet)
+	readRouter.HandleFunc("/api/v1/sessions", apiHandlers.Sessions).Methods(http.MethodGet)
+	readRouter.HandleFunc("/api/v1/bac", apiHandlers.BAC).Methods(http.MethodGet)
+	readRouter.HandleFunc("/api/v1/goals", apiHandlers.ListGoals).Methods(http.MethodGet)
+	readRouter.HandleFunc("/api/v1/goals", apiHandlers.CreateGoal).Methods(http.MethodPost)
+	readRouter.HandleFunc("/api/v1/goals/{id}", apiHandlers.GetGoal).Methods(http.MethodGet)
+	readRouter.HandleFunc("/api/v1/goals/{id}", apiHandlers.UpdateGoal).Methods(http.MethodPut)
+	readRouter.HandleFunc("/api/v1/goals/{id}", apiHandlers.DeleteGoal).Methods(http.MethodDelete)
+	readRouter.HandleFunc("/api/v1/taper", apiHandlers.GetTaperPlan).Methods(http.MethodGet)
+	readRouter.HandleFunc("/api/v1/taper", apiHandlers.CreateTaperPlan).Methods(http.MethodPost)
+	readRouter.HandleFunc("/api/v1/taper", apiHandlers.DeleteTaperPlan).Methods(http.MethodDelete)
+	readRouter.HandleFunc("/api/v1/share", apiHandlers.CreateShare).Methods(http.MethodPost)
 
 	// HTTP file server
 	staticFileHandler := http.StripPrefix("/static/", http.FileServer(http.Dir("../../static/")))
-	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	shareRouter.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		r.URL.Path = "/static/"
 		staticFileHandler.ServeHTTP(w, r)
 	})
@@ -33,7 +82,7 @@ func main() {
 
 	// start HTTP server
 	log.Printf("starting demo HTTP server on port %d", conf.Port)
-	err := http.ListenAndServe(":"+strconv.Itoa(conf.Port), router)
+	err = http.ListenAndServe(":"+strconv.Itoa(conf.Port), router)
 	log.Printf("demo HTTP server shut down: %s", err)
 }
 
@@ -68,14 +117,22 @@ func (d demoStore) Query(_ context.Context, options ...storage.QueryOption) ([]s
 	}
 }
 
-func (d demoStore) ReadLastTimestamp(_ context.Context) (time.Time, error) {
+func (d demoStore) QueryRecords(_ context.Context, _ ...storage.QueryOption) ([]storage.Record, error) {
+	return nil, storage.ErrNoResults
+}
+
+func (d demoStore) ReadLastTimestamp(_ context.Context, _ ...storage.QueryOption) (time.Time, error) {
 	return time.Now().UTC(), nil
 }
 
-func (d demoStore) ReadFirstTimestamp(_ context.Context) (time.Time, error) {
+func (d demoStore) ReadFirstTimestamp(_ context.Context, _ ...storage.QueryOption) (time.Time, error) {
 	return time.Time{}, nil
 }
 
+func (d demoStore) Delete(_ context.Context, _ ...storage.QueryOption) error {
+	return nil
+}
+
 func (d demoStore) Store(_ context.Context, _ ...storage.Record) error {
 	return nil
 }
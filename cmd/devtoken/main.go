@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/auth"
+)
+
+const keyID = "local"
+
+func main() {
+	audience := flag.String("audience", "http://localhost:8080", "the audience of the ID token")
+	serviceAccount := flag.String("service-account", "scheduler@localhost", "the service account email of the ID token")
+	issuer := flag.String("issuer", "https://accounts.google.com", "the issuer of the ID token")
+	keyFile := flag.String("key-file", "./config/local-key.pem", "the private key file, generated if it does not exist")
+	jwksFile := flag.String("jwks-file", "./config/local-jwks.json", "the JWKS file to write the public key to")
+	ttl := flag.Duration("ttl", time.Hour, "how long the ID token is valid for")
+	flag.Parse()
+
+	key, err := loadOrGenerateKey(*keyFile)
+	if err != nil {
+		log.Printf("failed to load private key: %s", err)
+		os.Exit(1)
+	}
+
+	jwks, err := auth.MarshalJWKS(keyID, &key.PublicKey)
+	if err != nil {
+		log.Printf("failed to encode JWKS: %s", err)
+		os.Exit(1)
+	}
+	if err := ioutil.WriteFile(*jwksFile, jwks, 0644); err != nil {
+		log.Printf("failed to write JWKS file: %s", err)
+		os.Exit(1)
+	}
+
+	now := time.Now()
+	token, err := auth.Sign(key, keyID, auth.Claims{
+		Issuer:        *issuer,
+		Subject:       *serviceAccount,
+		Audience:      []string{*audience},
+		Expiry:        now.Add(*ttl).Unix(),
+		IssuedAt:      now.Unix(),
+		Email:         *serviceAccount,
+		EmailVerified: true,
+	})
+	if err != nil {
+		log.Printf("failed to sign ID token: %s", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(token)
+}
+
+// loadOrGenerateKey loads a PEM encoded RSA private key, generating and writing one if the file does not exist.
+func loadOrGenerateKey(path string) (*rsa.PrivateKey, error) {
+	b, err := ioutil.ReadFile(path)
+	if err == nil {
+		block, _ := pem.Decode(b)
+		if block == nil {
+			return nil, errors.New("no PEM data found")
+		}
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	b = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := ioutil.WriteFile(path, b, 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
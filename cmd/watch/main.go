@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/calendar"
+	"github.com/jemgunay/canlendar-graph/config"
+	"github.com/jemgunay/canlendar-graph/storage/influx"
+	"github.com/jemgunay/canlendar-graph/tenant"
+	"github.com/jemgunay/canlendar-graph/watch"
+	watchfile "github.com/jemgunay/canlendar-graph/watch/file"
+)
+
+func main() {
+	calendarName := flag.String("calendar-name", "Units Consumed", "the calendar documenting units in single-user mode")
+	local := flag.Bool("local", false, "use local credentials.json file rather than default env creds")
+	stop := flag.Bool("stop", false, "stop every registered watch channel rather than registering channels")
+	notify := flag.Bool("notify", false, "send a fake change notification to WATCH_ADDRESS rather than registering channels")
+	userID := flag.String("user", "", "the user whose registered channel --notify sends for, required in multi-user mode")
+	flag.Parse()
+
+	conf := config.New()
+
+	users, err := tenant.Load(conf.Tenants, *calendarName)
+	if err != nil {
+		log.Printf("failed to load users: %s", err)
+		os.Exit(1)
+	}
+
+	// registered channels are shared with the server, so are read from and written to the same store
+	influxRequester := influx.New(conf.Influx)
+	var channels watch.Store = influxRequester
+	if conf.Watch.File != "" {
+		channels = watchfile.New(conf.Watch)
+	}
+	lockTTL := time.Duration(conf.Collect.LockTTLMinutes) * time.Minute
+	manager, err := watch.New(conf.Watch, users, calendar.NewPool(*local), channels,
+		watch.WithLocker(influxRequester, lockTTL))
+	if err != nil {
+		log.Printf("failed to create watch manager: %s", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	switch {
+	case *notify:
+		// notifications are scoped to the user whose calendar the channel is registered for, so a registered channel
+		// is sent to WATCH_ADDRESS, e.g. a local server
+		user, err := users.Get(*userID)
+		if err != nil {
+			log.Printf("failed to resolve user: %s", err)
+			os.Exit(1)
+		}
+		channel, err := manager.Channel(ctx, user)
+		if err != nil {
+			log.Printf("failed to find registered channel: %s", err)
+			os.Exit(1)
+		}
+		channel.Address = conf.Watch.Address
+		if err := watch.Send(ctx, http.DefaultClient, channel, watch.StateExists, 1); err != nil {
+			log.Printf("failed to send notification: %s", err)
+			os.Exit(1)
+		}
+		log.Println("successfully sent notification")
+
+	case *stop:
+		if err := manager.Stop(ctx); err != nil {
+			log.Printf("failed to stop watch channels: %s", err)
+			os.Exit(1)
+		}
+		log.Println("successfully stopped watch channels")
+
+	default:
+		renewAt, err := manager.Register(ctx)
+		if err != nil {
+			log.Printf("failed to register watch channels: %s", err)
+			os.Exit(1)
+		}
+		log.Printf("successfully registered watch channels; renew before %s", renewAt.Format(time.RFC3339))
+	}
+}
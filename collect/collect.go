@@ -0,0 +1,269 @@
+package collect
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+//go:generate mockgen -source=collect.go -destination=mocks/collect.go
+
+var (
+	// ErrNotFound indicates that no job exists with the requested ID.
+	ErrNotFound = errors.New("collect job not found")
+	// ErrRunning indicates that a job is already running.
+	ErrRunning = errors.New("collect job already running")
+)
+
+// LockName is the name of the storage lock held while a collect job runs, or while watch channels are registered.
+const LockName = "collect"
+
+const (
+	// maxJobs is the number of jobs retained, after which the oldest finished jobs are discarded.
+	maxJobs = 50
+	// maxErrors is the number of errors retained per job.
+	maxErrors = 100
+)
+
+// Status is the state of a collect job.
+type Status string
+
+const (
+	// Running indicates that the job is in progress.
+	Running Status = "running"
+	// Succeeded indicates that the job completed without errors.
+	Succeeded Status = "succeeded"
+	// Failed indicates that the job completed, but failed for at least one user.
+	Failed Status = "failed"
+)
+
+// String gets the status name.
+func (s Status) String() string {
+	return string(s)
+}
+
+// IsValid determines if the status is supported.
+func (s Status) IsValid() bool {
+	switch s {
+	case Running, Succeeded, Failed:
+		return true
+	default:
+		return false
+	}
+}
+
+// Progress counts the work done by a job.
+type Progress struct {
+	PagesFetched   int      `json:"pages_fetched"`
+	RecordsParsed  int      `json:"records_parsed"`
+	RecordsWritten int      `json:"records_written"`
+	Errors         []string `json:"errors,omitempty"`
+}
+
+// Job is a single collection of every user's calendar events.
+type Job struct {
+	ID         string     `json:"id"`
+	Status     Status     `json:"status"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Progress   Progress   `json:"progress"`
+}
+
+// Store persists jobs, such that a job can be polled from any instance. ErrNotFound is returned if no job exists with
+// the requested ID.
+type Store interface {
+	StoreJob(ctx context.Context, job Job) error
+	ReadJob(ctx context.Context, id string) (Job, error)
+}
+
+// Tracker tracks the status of collect jobs in memory, such that a job can be polled after the request which started
+// it has returned. Only one job runs at a time. Jobs are also saved to the store if one is provided, such that jobs
+// started by other instances can be polled.
+type Tracker struct {
+	now   func() time.Time
+	store Store
+
+	mu   sync.Mutex
+	jobs []*Job
+}
+
+// NewTracker initialises a Tracker. Jobs are only tracked in memory if the store is nil.
+func NewTracker(store Store) *Tracker {
+	return &Tracker{
+		now:   time.Now,
+		store: store,
+	}
+}
+
+// Start starts a new job, returning a Run used to report its progress. ErrRunning is returned if a job is already
+// running.
+func (t *Tracker) Start() (*Run, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, job := range t.jobs {
+		if job.Status == Running {
+			return nil, fmt.Errorf("%w: %s", ErrRunning, job.ID)
+		}
+	}
+
+	t.jobs = append(t.jobs, &Job{
+		ID:        id,
+		Status:    Running,
+		StartedAt: t.now().UTC(),
+	})
+	// discard the oldest jobs, which are all finished as only one job runs at a time
+	if len(t.jobs) > maxJobs {
+		t.jobs = t.jobs[len(t.jobs)-maxJobs:]
+	}
+
+	return &Run{
+		tracker: t,
+		id:      id,
+	}, nil
+}
+
+// Get returns a copy of the job with the provided ID. Jobs not started by this tracker are read from the store.
+func (t *Tracker) Get(ctx context.Context, id string) (Job, error) {
+	if job, ok := t.get(id); ok {
+		return job, nil
+	}
+	if t.store == nil {
+		return Job{}, ErrNotFound
+	}
+	return t.store.ReadJob(ctx, id)
+}
+
+// get returns a copy of the job with the provided ID if it was started by this tracker.
+func (t *Tracker) get(id string) (Job, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	job := t.find(id)
+	if job == nil {
+		return Job{}, false
+	}
+
+	copied := *job
+	copied.Progress.Errors = append([]string(nil), job.Progress.Errors...)
+	return copied, true
+}
+
+// Discard removes a job which never started work, i.e. because it could not acquire a lock.
+func (t *Tracker) Discard(run *Run) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, job := range t.jobs {
+		if job.ID == run.id {
+			t.jobs = append(t.jobs[:i], t.jobs[i+1:]...)
+			return
+		}
+	}
+}
+
+// update applies fn to the job with the provided ID, if it exists.
+func (t *Tracker) update(id string, fn func(job *Job)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if job := t.find(id); job != nil {
+		fn(job)
+	}
+}
+
+// find returns the job with the provided ID, or nil if it does not exist. The caller must hold the mutex.
+func (t *Tracker) find(id string) *Job {
+	for _, job := range t.jobs {
+		if job.ID == id {
+			return job
+		}
+	}
+	return nil
+}
+
+// Run reports the progress of a running job.
+type Run struct {
+	tracker *Tracker
+	id      string
+}
+
+// ID returns the job's ID.
+func (r *Run) ID() string {
+	return r.id
+}
+
+// AddPages adds to the number of calendar pages fetched.
+func (r *Run) AddPages(n int) {
+	r.tracker.update(r.id, func(job *Job) {
+		job.Progress.PagesFetched += n
+	})
+}
+
+// AddParsed adds to the number of records parsed from calendar events.
+func (r *Run) AddParsed(n int) {
+	r.tracker.update(r.id, func(job *Job) {
+		job.Progress.RecordsParsed += n
+	})
+}
+
+// AddWritten adds to the number of records written to storage.
+func (r *Run) AddWritten(n int) {
+	r.tracker.update(r.id, func(job *Job) {
+		job.Progress.RecordsWritten += n
+	})
+}
+
+// AddError records an error. Errors beyond the first 100 are dropped.
+func (r *Run) AddError(err error) {
+	r.tracker.update(r.id, func(job *Job) {
+		if len(job.Progress.Errors) < maxErrors {
+			job.Progress.Errors = append(job.Progress.Errors, err.Error())
+		}
+	})
+}
+
+// Finish marks the job as finished. The job has failed if it failed to collect for any user.
+func (r *Run) Finish(failed bool) {
+	r.tracker.update(r.id, func(job *Job) {
+		job.Status = Succeeded
+		if failed {
+			job.Status = Failed
+		}
+		finished := r.tracker.now().UTC()
+		job.FinishedAt = &finished
+	})
+}
+
+// Save saves the job's current status and progress to the store, if one is provided.
+func (r *Run) Save(ctx context.Context) error {
+	if r.tracker.store == nil {
+		return nil
+	}
+	job, ok := r.tracker.get(r.id)
+	if !ok {
+		return ErrNotFound
+	}
+	if err := r.tracker.store.StoreJob(ctx, job); err != nil {
+		return fmt.Errorf("failed to save collect job: %w", err)
+	}
+	return nil
+}
+
+// newID generates a random job ID.
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate job ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 )
 
 // Config is the service config.
@@ -12,6 +13,22 @@ type Config struct {
 	WebAppHost  string
 	ServiceHost string
 	Influx      Influx
+	Impute      Impute
+	Guideline   Guideline
+	Budget      Budget
+	Session     Session
+	Body        Body
+	Goals       Goals
+	Taper       Taper
+	Auth        Auth
+	Access      Access
+	Share       Share
+	Tenants     Tenants
+	Collect     Collect
+	Schedule    Schedule
+	Watch       Watch
+	// UnloggedDays is the logging completeness policy for days with no calendar entries, i.e. "dry" or "unknown".
+	UnloggedDays string
 }
 
 // Influx contains the InfluxDB config.
@@ -21,6 +38,125 @@ type Influx struct {
 	Org   string
 }
 
+// Impute contains the config for imputing units for events logged as unknown.
+type Impute struct {
+	Strategy      string
+	FixedUnits    float64
+	LookbackDays  int
+	WeekdayWindow int
+}
+
+// Guideline contains the config for the default guideline profile and the custom guideline profile limits (in UK
+// units).
+type Guideline struct {
+	Profile           string
+	CustomWeeklyUnits float64
+	CustomDailyUnits  float64
+	CustomBingeUnits  float64
+	// CustomHigherWeeklyUnits is the weekly higher risk threshold, where zero defaults to twice the weekly limit.
+	CustomHigherWeeklyUnits float64
+}
+
+// Budget contains the config for unit budgets (in UK units). A zero budget defaults to the guideline profile limit.
+// CarryOverFraction of a week's unused units roll over to the following week, up to CarryOverCap units.
+type Budget struct {
+	WeeklyUnits       float64
+	MonthlyUnits      float64
+	Projection        string
+	CarryOverFraction float64
+	CarryOverCap      float64
+}
+
+// Session contains the config for detecting drinking sessions. Events separated by no more than GapMinutes belong to
+// the same session, and events before DrinkingDayCutoffHour (local time) belong to the previous drinking day.
+type Session struct {
+	GapMinutes            int
+	DrinkingDayCutoffHour int
+}
+
+// Body contains the user's body profile, used to estimate blood alcohol concentration. Sex is "male" or "female".
+type Body struct {
+	WeightKg float64
+	Sex      string
+}
+
+// Goals contains the config for persisting goals. Goals are stored in InfluxDB, such that they are shared by every
+// instance, unless a file is set, e.g. for a self-hosted single instance.
+type Goals struct {
+	File string
+}
+
+// Taper contains the config for persisting the tapering plan. The plan is stored in InfluxDB, such that it is shared by
+// every instance, unless a file is set.
+type Taper struct {
+	File string
+}
+
+// Auth contains the config for authenticating collect requests. OIDC ID tokens are accepted if an audience and service
+// account are configured, and static API keys are accepted via the X-API-Key header.
+type Auth struct {
+	Audience       string
+	ServiceAccount string
+	Issuers        []string
+	// JWKSURL is the URL of the token signing keys. A file:// URL can be used to verify self-signed tokens locally.
+	JWKSURL string
+	APIKeys []string
+}
+
+// Access contains the config for protecting read access to the dashboard and API. Mode is one of "none", "basic" or
+// "oidc"; OIDC ID tokens are verified against the Auth issuers and JWKS URL.
+type Access struct {
+	Mode          string
+	BasicUsername string
+	BasicPassword string
+	OIDCAudience  string
+	OIDCEmails    []string
+	// OIDCClientSecret and OIDCRedirectURL enable the browser login, in which case OIDCAudience is the OAuth client ID.
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+	OIDCAuthURL      string
+	OIDCTokenURL     string
+}
+
+// Tenants contains the config for serving multiple users. Single-user mode is used if no users file is set.
+type Tenants struct {
+	File string
+}
+
+// Collect contains the config for collect jobs. The collect lock expires after LockTTLMinutes unless refreshed, such
+// that a crashed instance cannot block collection indefinitely.
+type Collect struct {
+	LockTTLMinutes int
+}
+
+// Schedule contains the config for the in-process collect scheduler, which is disabled unless either a cron expression
+// or an interval is set. Each run is delayed by a random jitter of up to JitterSeconds, and the last run time is
+// persisted to StateFile.
+type Schedule struct {
+	Cron            string
+	IntervalMinutes int
+	JitterSeconds   int
+	StateFile       string
+}
+
+// Watch contains the config for push-triggered collection via Google Calendar watch channels, which is disabled unless
+// an address is set. Address is the public HTTPS URL of the watch webhook and Secret signs each channel's token.
+// Channels are renewed RenewBeforeHours before they expire. Registered channels are persisted to InfluxDB unless a File
+// is set.
+type Watch struct {
+	Address          string
+	Secret           string
+	TTLHours         int
+	RenewBeforeHours int
+	File             string
+}
+
+// Share contains the config for signing shareable read-only links. Share links are disabled if no secret is set.
+type Share struct {
+	Secret      string
+	MaxTTLHours int
+}
+
 // New initialises a Config from environment variables.
 func New() Config {
 	// attempt to get config environment vars, or default them
@@ -31,6 +167,82 @@ func New() Config {
 			Token: getEnvVar("INFLUX_TOKEN", ""),
 			Org:   getEnvVar("INFLUX_ORG", ""),
 		},
+		Impute: Impute{
+			Strategy:      getEnvVar("IMPUTE_STRATEGY", "median"),
+			FixedUnits:    getEnvVarFloat("IMPUTE_FIXED_UNITS", 14),
+			LookbackDays:  getEnvVarInt("IMPUTE_LOOKBACK_DAYS", 90),
+			WeekdayWindow: getEnvVarInt("IMPUTE_WEEKDAY_WINDOW", 4),
+		},
+		Guideline: Guideline{
+			Profile:                 getEnvVar("GUIDELINE_PROFILE", "uk"),
+			CustomWeeklyUnits:       getEnvVarFloat("GUIDELINE_CUSTOM_WEEKLY_UNITS", 14),
+			CustomDailyUnits:        getEnvVarFloat("GUIDELINE_CUSTOM_DAILY_UNITS", 0),
+			CustomBingeUnits:        getEnvVarFloat("GUIDELINE_CUSTOM_BINGE_UNITS", 8),
+			CustomHigherWeeklyUnits: getEnvVarFloat("GUIDELINE_CUSTOM_HIGHER_WEEKLY_UNITS", 0),
+		},
+		Budget: Budget{
+			WeeklyUnits:       getEnvVarFloat("BUDGET_WEEKLY_UNITS", 0),
+			MonthlyUnits:      getEnvVarFloat("BUDGET_MONTHLY_UNITS", 0),
+			Projection:        getEnvVar("BUDGET_PROJECTION", "linear"),
+			CarryOverFraction: getEnvVarFloat("BUDGET_CARRY_OVER_FRACTION", 0.5),
+			CarryOverCap:      getEnvVarFloat("BUDGET_CARRY_OVER_CAP", 7),
+		},
+		Session: Session{
+			GapMinutes:            getEnvVarInt("SESSION_GAP_MINUTES", 180),
+			DrinkingDayCutoffHour: getEnvVarInt("DRINKING_DAY_CUTOFF_HOUR", 6),
+		},
+		Body: Body{
+			WeightKg: getEnvVarFloat("BODY_WEIGHT_KG", 70),
+			Sex:      getEnvVar("BODY_SEX", "male"),
+		},
+		Goals: Goals{
+			File: getEnvVar("GOALS_FILE", ""),
+		},
+		Taper: Taper{
+			File: getEnvVar("TAPER_FILE", ""),
+		},
+		Auth: Auth{
+			Audience:       getEnvVar("AUTH_AUDIENCE", ""),
+			ServiceAccount: getEnvVar("AUTH_SERVICE_ACCOUNT", ""),
+			Issuers:        getEnvVarList("AUTH_ISSUERS", "https://accounts.google.com,accounts.google.com"),
+			JWKSURL:        getEnvVar("AUTH_JWKS_URL", "https://www.googleapis.com/oauth2/v3/certs"),
+			APIKeys:        getEnvVarList("AUTH_API_KEYS", ""),
+		},
+		Access: Access{
+			Mode:             getEnvVar("ACCESS_MODE", "none"),
+			BasicUsername:    getEnvVar("ACCESS_BASIC_USERNAME", ""),
+			BasicPassword:    getEnvVar("ACCESS_BASIC_PASSWORD", ""),
+			OIDCAudience:     getEnvVar("ACCESS_OIDC_AUDIENCE", ""),
+			OIDCEmails:       getEnvVarList("ACCESS_OIDC_EMAILS", ""),
+			OIDCClientSecret: getEnvVar("ACCESS_OIDC_CLIENT_SECRET", ""),
+			OIDCRedirectURL:  getEnvVar("ACCESS_OIDC_REDIRECT_URL", ""),
+			OIDCAuthURL:      getEnvVar("ACCESS_OIDC_AUTH_URL", "https://accounts.google.com/o/oauth2/v2/auth"),
+			OIDCTokenURL:     getEnvVar("ACCESS_OIDC_TOKEN_URL", "https://oauth2.googleapis.com/token"),
+		},
+		Share: Share{
+			Secret:      getEnvVar("SHARE_SECRET", ""),
+			MaxTTLHours: getEnvVarInt("SHARE_MAX_TTL_HOURS", 720),
+		},
+		Tenants: Tenants{
+			File: getEnvVar("USERS_FILE", ""),
+		},
+		Collect: Collect{
+			LockTTLMinutes: getEnvVarInt("COLLECT_LOCK_TTL_MINUTES", 30),
+		},
+		Schedule: Schedule{
+			Cron:            getEnvVar("SCHEDULE_CRON", ""),
+			IntervalMinutes: getEnvVarInt("SCHEDULE_INTERVAL_MINUTES", 0),
+			JitterSeconds:   getEnvVarInt("SCHEDULE_JITTER_SECONDS", 30),
+			StateFile:       getEnvVar("SCHEDULE_STATE_FILE", "schedule.json"),
+		},
+		Watch: Watch{
+			Address:          getEnvVar("WATCH_ADDRESS", ""),
+			Secret:           getEnvVar("WATCH_SECRET", ""),
+			TTLHours:         getEnvVarInt("WATCH_TTL_HOURS", 168),
+			RenewBeforeHours: getEnvVarInt("WATCH_RENEW_BEFORE_HOURS", 24),
+			File:             getEnvVar("WATCH_FILE", ""),
+		},
+		UnloggedDays: getEnvVar("UNLOGGED_DAYS_POLICY", "dry"),
 	}
 }
 
@@ -55,3 +267,24 @@ func getEnvVarInt(key string, defaultValue int) int {
 	}
 	return varInt
 }
+
+// getEnvVarFloat gets a float environment variable or defaults it to 0 if unset.
+func getEnvVarFloat(key string, defaultValue float64) float64 {
+	varStr := getEnvVar(key, strconv.FormatFloat(defaultValue, 'f', -1, 64))
+	varFloat, err := strconv.ParseFloat(varStr, 64)
+	if err != nil {
+		return 0
+	}
+	return varFloat
+}
+
+// getEnvVarList gets a comma separated list environment variable or defaults it if unset.
+func getEnvVarList(key, defaultValue string) []string {
+	var list []string
+	for _, v := range strings.Split(getEnvVar(key, defaultValue), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
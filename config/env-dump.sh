@@ -1,4 +1,52 @@
 echo "PORT: ${PORT}"
 echo "INFLUX_HOST: ${INFLUX_HOST}"
 echo "INFLUX_TOKEN: ${INFLUX_TOKEN}"
-echo "INFLUX_ORG: ${INFLUX_ORG}"
\ No newline at end of file
+echo "INFLUX_ORG: ${INFLUX_ORG}"
+echo "IMPUTE_STRATEGY: ${IMPUTE_STRATEGY}"
+echo "IMPUTE_FIXED_UNITS: ${IMPUTE_FIXED_UNITS}"
+echo "IMPUTE_LOOKBACK_DAYS: ${IMPUTE_LOOKBACK_DAYS}"
+echo "IMPUTE_WEEKDAY_WINDOW: ${IMPUTE_WEEKDAY_WINDOW}"
+echo "GUIDELINE_PROFILE: ${GUIDELINE_PROFILE}"
+echo "GUIDELINE_CUSTOM_WEEKLY_UNITS: ${GUIDELINE_CUSTOM_WEEKLY_UNITS}"
+echo "GUIDELINE_CUSTOM_DAILY_UNITS: ${GUIDELINE_CUSTOM_DAILY_UNITS}"
+echo "GUIDELINE_CUSTOM_BINGE_UNITS: ${GUIDELINE_CUSTOM_BINGE_UNITS}"
+echo "GUIDELINE_CUSTOM_HIGHER_WEEKLY_UNITS: ${GUIDELINE_CUSTOM_HIGHER_WEEKLY_UNITS}"
+echo "UNLOGGED_DAYS_POLICY: ${UNLOGGED_DAYS_POLICY}"
+echo "BUDGET_WEEKLY_UNITS: ${BUDGET_WEEKLY_UNITS}"
+echo "BUDGET_MONTHLY_UNITS: ${BUDGET_MONTHLY_UNITS}"
+echo "BUDGET_PROJECTION: ${BUDGET_PROJECTION}"
+echo "BUDGET_CARRY_OVER_FRACTION: ${BUDGET_CARRY_OVER_FRACTION}"
+echo "BUDGET_CARRY_OVER_CAP: ${BUDGET_CARRY_OVER_CAP}"
+echo "SESSION_GAP_MINUTES: ${SESSION_GAP_MINUTES}"
+echo "DRINKING_DAY_CUTOFF_HOUR: ${DRINKING_DAY_CUTOFF_HOUR}"
+echo "BODY_WEIGHT_KG: ${BODY_WEIGHT_KG}"
+echo "BODY_SEX: ${BODY_SEX}"
+echo "GOALS_FILE: ${GOALS_FILE}"
+echo "TAPER_FILE: ${TAPER_FILE}"
+echo "AUTH_AUDIENCE: ${AUTH_AUDIENCE}"
+echo "AUTH_SERVICE_ACCOUNT: ${AUTH_SERVICE_ACCOUNT}"
+echo "AUTH_ISSUERS: ${AUTH_ISSUERS}"
+echo "AUTH_JWKS_URL: ${AUTH_JWKS_URL}"
+echo "AUTH_API_KEYS: ${AUTH_API_KEYS:+<set>}"
+echo "ACCESS_MODE: ${ACCESS_MODE}"
+echo "ACCESS_BASIC_USERNAME: ${ACCESS_BASIC_USERNAME}"
+echo "ACCESS_BASIC_PASSWORD: ${ACCESS_BASIC_PASSWORD:+<set>}"
+echo "ACCESS_OIDC_AUDIENCE: ${ACCESS_OIDC_AUDIENCE}"
+echo "ACCESS_OIDC_EMAILS: ${ACCESS_OIDC_EMAILS}"
+echo "ACCESS_OIDC_CLIENT_SECRET: ${ACCESS_OIDC_CLIENT_SECRET:+<set>}"
+echo "ACCESS_OIDC_REDIRECT_URL: ${ACCESS_OIDC_REDIRECT_URL}"
+echo "ACCESS_OIDC_AUTH_URL: ${ACCESS_OIDC_AUTH_URL}"
+echo "ACCESS_OIDC_TOKEN_URL: ${ACCESS_OIDC_TOKEN_URL}"
+echo "SHARE_SECRET: ${SHARE_SECRET:+<set>}"
+echo "SHARE_MAX_TTL_HOURS: ${SHARE_MAX_TTL_HOURS}"
+echo "USERS_FILE: ${USERS_FILE}"
+echo "COLLECT_LOCK_TTL_MINUTES: ${COLLECT_LOCK_TTL_MINUTES}"
+echo "SCHEDULE_CRON: ${SCHEDULE_CRON}"
+echo "SCHEDULE_INTERVAL_MINUTES: ${SCHEDULE_INTERVAL_MINUTES}"
+echo "SCHEDULE_JITTER_SECONDS: ${SCHEDULE_JITTER_SECONDS}"
+echo "SCHEDULE_STATE_FILE: ${SCHEDULE_STATE_FILE}"
+echo "WATCH_ADDRESS: ${WATCH_ADDRESS}"
+echo "WATCH_SECRET: ${WATCH_SECRET:+<set>}"
+echo "WATCH_TTL_HOURS: ${WATCH_TTL_HOURS}"
+echo "WATCH_RENEW_BEFORE_HOURS: ${WATCH_RENEW_BEFORE_HOURS}"
+echo "WATCH_FILE: ${WATCH_FILE}"
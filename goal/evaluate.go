@@ -0,0 +1,169 @@
+package goal
+
+import (
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/storage"
+)
+
+// Status is the outcome of a goal or of a single goal period.
+type Status string
+
+const (
+	// InProgress indicates that the outcome is not yet known.
+	InProgress Status = "in_progress"
+	// Met indicates that the target was met.
+	Met Status = "met"
+	// Failed indicates that the target was missed.
+	Failed Status = "failed"
+)
+
+// Period is the outcome of a goal for a single evaluation period. Value is the units consumed for dry range and max
+// weekly units goals, or the dry days for min weekly dry days goals.
+type Period struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Value  float64   `json:"value"`
+	Status Status    `json:"status"`
+}
+
+// Evaluation is the progress and history of a goal. Progress is the percentage of the dry days achieved for dry range
+// goals, or the percentage of the target reached in the current period for weekly goals. Streaks count consecutive met
+// periods, where periods still in progress neither extend nor break a streak.
+type Evaluation struct {
+	Status             Status   `json:"status"`
+	ProgressPercentage float64  `json:"progress_percentage"`
+	Periods            []Period `json:"periods"`
+	Met                int      `json:"met"`
+	Failed             int      `json:"failed"`
+	CurrentStreak      int      `json:"current_streak"`
+	LongestStreak      int      `json:"longest_streak"`
+}
+
+// QueryEnd returns the end of the time range to query to evaluate the goal at now.
+func (g Goal) QueryEnd(now time.Time) time.Time {
+	end := storage.Day.PeriodEnd(now)
+	if g.End != nil && g.End.Before(end) {
+		return *g.End
+	}
+	return end
+}
+
+// Evaluate evaluates a goal at now from a set of day aggregated plots (in UK units). Dry range goals are evaluated per
+// day. Weekly goals are evaluated over consecutive 7 day periods from the goal's start date. Only periods which have
+// started by now are included.
+func Evaluate(g Goal, days []storage.Plot, now time.Time) Evaluation {
+	units := make(map[time.Time]float64, len(days))
+	for _, d := range days {
+		units[storage.Day.PeriodStart(time.UnixMilli(d.X))] += d.Y
+	}
+
+	periodDays := 7
+	if g.Type == DryRange {
+		periodDays = 1
+	}
+
+	e := Evaluation{Periods: []Period{}}
+	for start := g.Start; !start.After(now); start = start.AddDate(0, 0, periodDays) {
+		if g.End != nil && !start.Before(*g.End) {
+			break
+		}
+
+		end := start.AddDate(0, 0, periodDays)
+		p := evaluatePeriod(g, units, start, end, now)
+		e.Periods = append(e.Periods, p)
+
+		switch p.Status {
+		case Met:
+			e.Met++
+			e.CurrentStreak++
+			if e.CurrentStreak > e.LongestStreak {
+				e.LongestStreak = e.CurrentStreak
+			}
+		case Failed:
+			e.Failed++
+			e.CurrentStreak = 0
+		}
+	}
+
+	ended := g.End != nil && !now.Before(*g.End)
+	switch {
+	case e.Failed > 0 && (g.Type == DryRange || g.End != nil):
+		e.Status = Failed
+	case ended:
+		e.Status = Met
+	default:
+		e.Status = InProgress
+	}
+
+	e.ProgressPercentage = progress(g, e.Periods)
+	return e
+}
+
+// evaluatePeriod evaluates a single goal period between start and end.
+func evaluatePeriod(g Goal, units map[time.Time]float64, start, end, now time.Time) Period {
+	p := Period{
+		Start:  start,
+		End:    end,
+		Status: InProgress,
+	}
+	complete := !now.Before(end)
+
+	switch g.Type {
+	case DryRange, MaxWeeklyUnits:
+		// dry range goals have an implicit target of zero units
+		var limit float64
+		if g.Type == MaxWeeklyUnits {
+			limit = g.Target
+		}
+		for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+			p.Value += units[d]
+		}
+		switch {
+		case p.Value > limit:
+			p.Status = Failed
+		case complete:
+			p.Status = Met
+		}
+
+	case MinWeeklyDryDays:
+		// a day is only counted as dry once it has ended
+		var remaining float64
+		for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+			switch {
+			case !now.Before(d.AddDate(0, 0, 1)):
+				if units[d] <= 0 {
+					p.Value++
+				}
+			case units[d] <= 0:
+				remaining++
+			}
+		}
+		switch {
+		case p.Value >= g.Target:
+			p.Status = Met
+		case complete || p.Value+remaining < g.Target:
+			p.Status = Failed
+		}
+	}
+	return p
+}
+
+// progress calculates the progress percentage of a goal from its evaluated periods.
+func progress(g Goal, periods []Period) float64 {
+	if g.Type == DryRange {
+		total := g.End.Sub(g.Start).Hours() / 24
+		var dry float64
+		for _, p := range periods {
+			if p.Status == Met {
+				dry++
+			}
+		}
+		return dry / total * 100
+	}
+
+	if len(periods) == 0 || g.Target <= 0 {
+		return 0
+	}
+	return periods[len(periods)-1].Value / g.Target * 100
+}
@@ -0,0 +1,145 @@
+package file
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/jemgunay/canlendar-graph/config"
+	"github.com/jemgunay/canlendar-graph/goal"
+	"github.com/jemgunay/canlendar-graph/internal/jsonfile"
+	"github.com/jemgunay/canlendar-graph/tenant"
+)
+
+var _ goal.Storer = (*Store)(nil)
+
+// Store persists goals to a JSON file per user. The file is read and rewritten on every operation, which is sufficient
+// for the small number of goals expected.
+type Store struct {
+	file string
+	mu   sync.Mutex
+}
+
+// New initialises a Store from config.
+func New(conf config.Goals) *Store {
+	return &Store{
+		file: conf.File,
+	}
+}
+
+// List returns every goal, in order of creation.
+func (s *Store) List(ctx context.Context) ([]goal.Goal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.read(ctx)
+}
+
+// Get returns the goal with the provided ID.
+func (s *Store) Get(ctx context.Context, id string) (goal.Goal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	goals, err := s.read(ctx)
+	if err != nil {
+		return goal.Goal{}, err
+	}
+
+	for _, g := range goals {
+		if g.ID == id {
+			return g, nil
+		}
+	}
+	return goal.Goal{}, goal.ErrNotFound
+}
+
+// Create assigns a new ID to the goal and persists it.
+func (s *Store) Create(ctx context.Context, g goal.Goal) (goal.Goal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	goals, err := s.read(ctx)
+	if err != nil {
+		return goal.Goal{}, err
+	}
+
+	if g.ID, err = newID(); err != nil {
+		return goal.Goal{}, err
+	}
+
+	if err := s.write(ctx, append(goals, g)); err != nil {
+		return goal.Goal{}, err
+	}
+	return g, nil
+}
+
+// Update replaces the goal with the same ID.
+func (s *Store) Update(ctx context.Context, g goal.Goal) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	goals, err := s.read(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range goals {
+		if goals[i].ID == g.ID {
+			goals[i] = g
+			return s.write(ctx, goals)
+		}
+	}
+	return goal.ErrNotFound
+}
+
+// Delete removes the goal with the provided ID.
+func (s *Store) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	goals, err := s.read(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range goals {
+		if goals[i].ID == id {
+			return s.write(ctx, append(goals[:i], goals[i+1:]...))
+		}
+	}
+	return goal.ErrNotFound
+}
+
+// read reads the goals from the user's file. A missing file is treated as no goals.
+func (s *Store) read(ctx context.Context) ([]goal.Goal, error) {
+	goals := []goal.Goal{}
+	if _, err := jsonfile.Read(s.path(ctx), &goals); err != nil {
+		return nil, fmt.Errorf("failed to read goals: %w", err)
+	}
+	return goals, nil
+}
+
+// write replaces the user's file with the provided goals.
+func (s *Store) write(ctx context.Context, goals []goal.Goal) error {
+	if err := jsonfile.Write(s.path(ctx), goals); err != nil {
+		return fmt.Errorf("failed to write goals: %w", err)
+	}
+	return nil
+}
+
+// path returns the file for the user carried by the context.
+func (s *Store) path(ctx context.Context) string {
+	user, _ := tenant.FromContext(ctx)
+	return tenant.Path(s.file, user)
+}
+
+// newID generates a random goal ID.
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate goal ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,99 @@
+package goal
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/storage"
+)
+
+//go:generate mockgen -source=goal.go -destination=mocks/goal.go
+
+// ErrNotFound indicates that no goal exists with the requested ID.
+var ErrNotFound = errors.New("goal not found")
+
+// Type is the kind of target a goal sets.
+type Type string
+
+const (
+	// DryRange is a goal of consuming zero units every day between the goal's start and end dates.
+	DryRange Type = "dry_range"
+	// MaxWeeklyUnits is a goal of consuming at most Target units (in UK units) per week.
+	MaxWeeklyUnits Type = "max_weekly_units"
+	// MinWeeklyDryDays is a goal of having at least Target dry days per week.
+	MinWeeklyDryDays Type = "min_weekly_dry_days"
+)
+
+// String gets the goal type name.
+func (t Type) String() string {
+	return string(t)
+}
+
+// IsValid determines if the goal type is supported.
+func (t Type) IsValid() bool {
+	switch t {
+	case DryRange, MaxWeeklyUnits, MinWeeklyDryDays:
+		return true
+	default:
+		return false
+	}
+}
+
+// Goal is a personal drinking goal or challenge. Start and End are dates (midnight UTC), where End is exclusive. A nil
+// End indicates an ongoing goal, which is only supported by weekly goals.
+type Goal struct {
+	ID     string     `json:"id"`
+	Name   string     `json:"name"`
+	Type   Type       `json:"type"`
+	Start  time.Time  `json:"start"`
+	End    *time.Time `json:"end,omitempty"`
+	Target float64    `json:"target"`
+}
+
+// Normalise truncates the goal's start and end times to dates.
+func (g *Goal) Normalise() {
+	g.Start = storage.Day.PeriodStart(g.Start)
+	if g.End != nil {
+		end := storage.Day.PeriodStart(*g.End)
+		g.End = &end
+	}
+}
+
+// Validate determines if the goal definition is valid.
+func (g Goal) Validate() error {
+	if !g.Type.IsValid() {
+		return errors.New("unsupported goal type: " + g.Type.String())
+	}
+	if g.Start.IsZero() {
+		return errors.New("start must not be zero")
+	}
+	if g.End != nil && !g.End.After(g.Start) {
+		return errors.New("end must be after start")
+	}
+
+	switch g.Type {
+	case DryRange:
+		if g.End == nil {
+			return errors.New("dry range goals must have an end")
+		}
+	case MaxWeeklyUnits:
+		if g.Target < 0 {
+			return errors.New("target units must not be negative")
+		}
+	case MinWeeklyDryDays:
+		if g.Target < 1 || g.Target > 7 {
+			return errors.New("target dry days must be between 1 and 7")
+		}
+	}
+	return nil
+}
+
+// Storer persists goal definitions.
+type Storer interface {
+	List(ctx context.Context) ([]Goal, error)
+	Get(ctx context.Context, id string) (Goal, error)
+	Create(ctx context.Context, g Goal) (Goal, error)
+	Update(ctx context.Context, g Goal) error
+	Delete(ctx context.Context, id string) error
+}
@@ -0,0 +1,181 @@
+package guideline
+
+import (
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/config"
+	"github.com/jemgunay/canlendar-graph/storage"
+	"github.com/jemgunay/canlendar-graph/units"
+)
+
+const (
+	daysPerWeek  = 7
+	daysPerYear  = 365.25
+	daysPerMonth = daysPerYear / 12
+)
+
+// Profile is a set of drinking guideline limits. Limits are defined in the profile's own standard drinks, where a
+// standard drink contains GramsPerDrink grams of ethanol. A zero DailyDrinks indicates that there is no daily limit.
+// Drinking more than BingeDrinks in a single day or session is considered binge drinking. Drinking more than
+// HigherWeeklyDrinks in a week is considered higher risk, where a zero HigherWeeklyDrinks defaults to twice the weekly
+// limit.
+type Profile struct {
+	Name               string
+	GramsPerDrink      float64
+	WeeklyDrinks       float64
+	DailyDrinks        float64
+	BingeDrinks        float64
+	HigherWeeklyDrinks float64
+}
+
+// WeeklyUnits returns the weekly limit in UK units.
+func (p Profile) WeeklyUnits() float64 {
+	return p.WeeklyDrinks * p.GramsPerDrink / units.UK.Grams()
+}
+
+// DailyUnits returns the daily limit in UK units. Zero is returned if the profile has no daily limit.
+func (p Profile) DailyUnits() float64 {
+	return p.DailyDrinks * p.GramsPerDrink / units.UK.Grams()
+}
+
+// BingeUnits returns the binge drinking threshold in UK units.
+func (p Profile) BingeUnits() float64 {
+	return p.BingeDrinks * p.GramsPerDrink / units.UK.Grams()
+}
+
+// HigherWeeklyUnits returns the weekly higher risk threshold in UK units.
+func (p Profile) HigherWeeklyUnits() float64 {
+	if p.HigherWeeklyDrinks <= 0 {
+		return 2 * p.WeeklyUnits()
+	}
+	return p.HigherWeeklyDrinks * p.GramsPerDrink / units.UK.Grams()
+}
+
+// DailyReferenceUnits returns the daily limit in UK units, or an even share of the weekly limit if the profile has no
+// daily limit.
+func (p Profile) DailyReferenceUnits() float64 {
+	if daily := p.DailyUnits(); daily > 0 {
+		return daily
+	}
+	return p.WeeklyUnits() / daysPerWeek
+}
+
+// Limit returns the limit in UK units for the aggregation period starting at start. Month and year limits are scaled
+// from the weekly limit by the actual number of days in the period. The day limit is the daily limit, if any.
+func (p Profile) Limit(aggregation storage.Aggregation, start time.Time) float64 {
+	start = start.UTC()
+	switch aggregation {
+	case storage.Day:
+		return p.DailyUnits()
+	case storage.Week:
+		return p.WeeklyUnits()
+	case storage.Month:
+		return p.WeeklyUnits() * days(start, start.AddDate(0, 1, 0)) / daysPerWeek
+	case storage.Year:
+		return p.WeeklyUnits() * days(start, start.AddDate(1, 0, 0)) / daysPerWeek
+	default:
+		return 0
+	}
+}
+
+// AverageLimit returns the limit in UK units for an average length aggregation period.
+func (p Profile) AverageLimit(aggregation storage.Aggregation) float64 {
+	switch aggregation {
+	case storage.Day:
+		return p.DailyUnits()
+	case storage.Week:
+		return p.WeeklyUnits()
+	case storage.Month:
+		return p.WeeklyUnits() * daysPerMonth / daysPerWeek
+	case storage.Year:
+		return p.WeeklyUnits() * daysPerYear / daysPerWeek
+	default:
+		return 0
+	}
+}
+
+// Plots returns the calendar accurate limit for the period of each of the provided aggregated plots.
+func (p Profile) Plots(aggregation storage.Aggregation, plots []storage.Plot) []storage.Plot {
+	limits := make([]storage.Plot, 0, len(plots))
+	for _, plot := range plots {
+		limits = append(limits, storage.Plot{
+			X: plot.X,
+			Y: p.Limit(aggregation, time.UnixMilli(plot.X)),
+		})
+	}
+	return limits
+}
+
+// days returns the number of days between two times.
+func days(start, end time.Time) float64 {
+	return end.Sub(start).Hours() / 24
+}
+
+// Supported guideline profile names.
+const (
+	UK       = "uk"
+	UKMale   = "uk_male"
+	UKFemale = "uk_female"
+	USMale   = "us_male"
+	USFemale = "us_female"
+	AU       = "au"
+	Custom   = "custom"
+)
+
+// Set is a collection of guideline profiles keyed by profile name, with a default profile.
+type Set struct {
+	profiles       map[string]Profile
+	defaultProfile Profile
+}
+
+// New initialises the set of supported guideline profiles. The custom profile is defined in UK units via config. The
+// UK profile is used as the default if the configured default profile is unsupported.
+func New(conf config.Guideline) Set {
+	profiles := map[string]Profile{
+		// UK Chief Medical Officers' guidance: 14 units a week, with no daily limit. The NHS binge and higher risk
+		// thresholds for women are used as the lower of the two
+		UK: {Name: UK, GramsPerDrink: units.UK.Grams(), WeeklyDrinks: 14, BingeDrinks: 6, HigherWeeklyDrinks: 35},
+		// NHS binge drinking thresholds: more than 8 units in a single session for men, or 6 units for women. Higher
+		// risk drinking is more than 50 units a week for men, or 35 units for women
+		UKMale:   {Name: UKMale, GramsPerDrink: units.UK.Grams(), WeeklyDrinks: 14, BingeDrinks: 8, HigherWeeklyDrinks: 50},
+		UKFemale: {Name: UKFemale, GramsPerDrink: units.UK.Grams(), WeeklyDrinks: 14, BingeDrinks: 6, HigherWeeklyDrinks: 35},
+		// NIAAA low-risk drinking levels and binge thresholds
+		USMale:   {Name: USMale, GramsPerDrink: units.USDrinks.Grams(), WeeklyDrinks: 14, DailyDrinks: 4, BingeDrinks: 5},
+		USFemale: {Name: USFemale, GramsPerDrink: units.USDrinks.Grams(), WeeklyDrinks: 7, DailyDrinks: 3, BingeDrinks: 4},
+		// NHMRC Australian guidelines, where exceeding the daily limit is considered risky single occasion drinking
+		AU: {Name: AU, GramsPerDrink: units.AUDrinks.Grams(), WeeklyDrinks: 10, DailyDrinks: 4, BingeDrinks: 4},
+		Custom: {
+			Name:          Custom,
+			GramsPerDrink: units.UK.Grams(),
+			WeeklyDrinks:  conf.CustomWeeklyUnits,
+			DailyDrinks:   conf.CustomDailyUnits,
+			BingeDrinks:   conf.CustomBingeUnits,
+			// zero defaults to twice the weekly limit
+			HigherWeeklyDrinks: conf.CustomHigherWeeklyUnits,
+		},
+	}
+
+	defaultProfile, ok := profiles[conf.Profile]
+	if !ok {
+		defaultProfile = profiles[UK]
+	}
+
+	return Set{
+		profiles:       profiles,
+		defaultProfile: defaultProfile,
+	}
+}
+
+// Get returns the named profile. The default profile is returned if the name is empty.
+func (s Set) Get(name string) (Profile, bool) {
+	if name == "" {
+		return s.defaultProfile, true
+	}
+	p, ok := s.profiles[name]
+	return p, ok
+}
+
+// Default returns the default profile.
+func (s Set) Default() Profile {
+	return s.defaultProfile
+}
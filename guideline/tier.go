@@ -0,0 +1,93 @@
+package guideline
+
+import (
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/storage"
+)
+
+// Tier is a risk band for the units consumed in an aggregation period.
+type Tier string
+
+const (
+	// LowerRisk is consumption within the guideline limit.
+	LowerRisk Tier = "lower"
+	// IncreasingRisk is consumption above the guideline limit, up to the higher risk threshold.
+	IncreasingRisk Tier = "increasing"
+	// HigherRisk is consumption above the higher risk threshold.
+	HigherRisk Tier = "higher"
+)
+
+// String gets the tier name.
+func (t Tier) String() string {
+	return string(t)
+}
+
+// Tier classifies the units consumed in the aggregation period starting at start. Week, month and year periods are
+// classified, where month and year thresholds are scaled from the weekly thresholds by the actual number of days in the
+// period. An empty tier is returned for day periods, or if the profile has no weekly limit.
+func (p Profile) Tier(aggregation storage.Aggregation, start time.Time, units float64) Tier {
+	if aggregation == storage.Day {
+		return ""
+	}
+
+	limit := p.Limit(aggregation, start)
+	if limit <= 0 {
+		return ""
+	}
+	higher := limit * p.HigherWeeklyUnits() / p.WeeklyUnits()
+
+	switch {
+	case units <= limit:
+		return LowerRisk
+	case units <= higher:
+		return IncreasingRisk
+	default:
+		return HigherRisk
+	}
+}
+
+// Classify sets the tier of each of the provided aggregated plots.
+func (p Profile) Classify(aggregation storage.Aggregation, plots []storage.Plot) []storage.Plot {
+	if plots == nil {
+		return nil
+	}
+
+	classified := make([]storage.Plot, 0, len(plots))
+	for _, plot := range plots {
+		plot.Tier = p.Tier(aggregation, time.UnixMilli(plot.X), plot.Y).String()
+		classified = append(classified, plot)
+	}
+	return classified
+}
+
+// TierSummary is the number of aggregation periods spent in each tier.
+type TierSummary struct {
+	Lower      int `json:"lower"`
+	Increasing int `json:"increasing"`
+	Higher     int `json:"higher"`
+}
+
+// SummariseTiers counts the aggregation periods spent in each tier from a set of classified plots. Returns nil if none
+// of the plots are classified.
+func SummariseTiers(plots []storage.Plot) *TierSummary {
+	var s *TierSummary
+	for _, p := range plots {
+		if p.Tier == "" {
+			continue
+		}
+		if s == nil {
+			s = &TierSummary{}
+		}
+
+		switch Tier(p.Tier) {
+		case LowerRisk:
+			s.Lower++
+		case IncreasingRisk:
+			s.Increasing++
+		case HigherRisk:
+			s.Higher++
+		}
+	}
+	return s
+}
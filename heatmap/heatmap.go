@@ -0,0 +1,121 @@
+package heatmap
+
+import (
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/guideline"
+	"github.com/jemgunay/canlendar-graph/storage"
+)
+
+// Bucket is an intensity bucket for the units consumed in a day.
+type Bucket string
+
+const (
+	None     Bucket = "none"
+	Low      Bucket = "low"
+	Moderate Bucket = "moderate"
+	Heavy    Bucket = "heavy"
+	Binge    Bucket = "binge"
+)
+
+// Level returns the bucket's intensity level, from 0 (none) to 4 (binge).
+func (b Bucket) Level() int {
+	switch b {
+	case Low:
+		return 1
+	case Moderate:
+		return 2
+	case Heavy:
+		return 3
+	case Binge:
+		return 4
+	default:
+		return 0
+	}
+}
+
+// Thresholds are the upper bounds (inclusive) of the low, moderate and heavy buckets. Any units exceeding Heavy fall
+// into the binge bucket.
+type Thresholds struct {
+	Low      float64 `json:"low"`
+	Moderate float64 `json:"moderate"`
+	Heavy    float64 `json:"heavy"`
+}
+
+// NewThresholds derives bucket thresholds in UK units from a guideline profile. Low extends to the profile's daily
+// reference, heavy extends to the binge threshold and moderate sits halfway between the two.
+func NewThresholds(profile guideline.Profile) Thresholds {
+	low := profile.DailyReferenceUnits()
+	heavy := profile.BingeUnits()
+	if heavy < low {
+		heavy = low
+	}
+
+	return Thresholds{
+		Low:      low,
+		Moderate: (low + heavy) / 2,
+		Heavy:    heavy,
+	}
+}
+
+// Bucket determines the bucket for the units consumed in a day.
+func (t Thresholds) Bucket(units float64) Bucket {
+	switch {
+	case units <= 0:
+		return None
+	case units <= t.Low:
+		return Low
+	case units <= t.Moderate:
+		return Moderate
+	case units <= t.Heavy:
+		return Heavy
+	default:
+		return Binge
+	}
+}
+
+// Day is a single cell of the heatmap.
+type Day struct {
+	Date   string  `json:"date"`
+	Units  float64 `json:"units"`
+	Bucket Bucket  `json:"bucket"`
+	Level  int     `json:"level"`
+}
+
+// Week is a column of the heatmap, starting on Monday. Days outside of the heatmap's range are nil.
+type Week struct {
+	Start time.Time `json:"start"`
+	Days  [7]*Day   `json:"days"`
+}
+
+// Build lays out day aggregated plots as a grid of weeks by weekdays covering [start, end). Days within the range but
+// missing from plots are treated as having no units.
+func Build(days []storage.Plot, start, end time.Time, thresholds Thresholds) []Week {
+	totals := make(map[time.Time]float64, len(days))
+	for _, d := range days {
+		totals[storage.Day.PeriodStart(time.UnixMilli(d.X))] += d.Y
+	}
+
+	start = storage.Day.PeriodStart(start)
+	var weeks []Week
+	for weekStart := storage.Week.PeriodStart(start); weekStart.Before(end); weekStart = weekStart.AddDate(0, 0, 7) {
+		week := Week{Start: weekStart}
+		for i := range week.Days {
+			d := weekStart.AddDate(0, 0, i)
+			if d.Before(start) || !d.Before(end) {
+				continue
+			}
+
+			units := totals[d]
+			bucket := thresholds.Bucket(units)
+			week.Days[i] = &Day{
+				Date:   d.Format("2006-01-02"),
+				Units:  units,
+				Bucket: bucket,
+				Level:  bucket.Level(),
+			}
+		}
+		weeks = append(weeks, week)
+	}
+	return weeks
+}
@@ -0,0 +1,171 @@
+package impute
+
+import (
+	"sort"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/config"
+	"github.com/jemgunay/canlendar-graph/storage"
+)
+
+// Strategy describes how units should be imputed for an event logged as unknown.
+type Strategy string
+
+const (
+	// Fixed imputes a fixed number of units.
+	Fixed Strategy = "fixed"
+	// Median imputes the median units of observed drinking days.
+	Median Strategy = "median"
+	// WeekdayAverage imputes the rolling average units of the same weekday over the preceding weeks.
+	WeekdayAverage Strategy = "weekday_average"
+	// Exclude imputes zero units, but the event is still counted as an imputed entry.
+	Exclude Strategy = "exclude"
+)
+
+// String gets the strategy name.
+func (s Strategy) String() string {
+	return string(s)
+}
+
+// IsValid determines if the imputation strategy is supported.
+func (s Strategy) IsValid() bool {
+	switch s {
+	case Fixed, Median, WeekdayAverage, Exclude:
+		return true
+	default:
+		return false
+	}
+}
+
+// History is a set of observed daily unit totals, keyed by the UTC start of the day.
+type History map[time.Time]float64
+
+// NewHistory initialises a History from a set of day aggregated plots.
+func NewHistory(plots []storage.Plot) History {
+	h := make(History, len(plots))
+	for _, p := range plots {
+		h.Add(time.UnixMilli(p.X), p.Y)
+	}
+	return h
+}
+
+// Add adds units to the day total containing t.
+func (h History) Add(t time.Time, units float64) {
+	h[day(t)] += units
+}
+
+// Imputer resolves units for events logged as unknown.
+type Imputer struct {
+	strategy      Strategy
+	fixedUnits    float64
+	lookbackDays  int
+	weekdayWindow int
+}
+
+// New initialises an Imputer from config. Unsupported strategies fall back to the Fixed strategy.
+func New(conf config.Impute) Imputer {
+	strategy := Strategy(conf.Strategy)
+	if !strategy.IsValid() {
+		strategy = Fixed
+	}
+
+	return Imputer{
+		strategy:      strategy,
+		fixedUnits:    conf.FixedUnits,
+		lookbackDays:  conf.LookbackDays,
+		weekdayWindow: conf.WeekdayWindow,
+	}
+}
+
+// Strategy returns the imputation strategy in use.
+func (i Imputer) Strategy() Strategy {
+	return i.strategy
+}
+
+// RequiresHistory determines if the strategy depends on previously observed data.
+func (i Imputer) RequiresHistory() bool {
+	return i.strategy == Median || i.strategy == WeekdayAverage
+}
+
+// LookbackDays is the number of days of observed history required prior to the first imputed event, which covers the
+// preceding weeks averaged by the WeekdayAverage strategy.
+func (i Imputer) LookbackDays() int {
+	if i.strategy == WeekdayAverage && i.weekdayWindow*7 > i.lookbackDays {
+		return i.weekdayWindow * 7
+	}
+	return i.lookbackDays
+}
+
+// Impute returns the units to use for an unknown event on the given date. Strategies which depend on history fall back
+// to the fixed units if there is no observed history to draw from.
+func (i Imputer) Impute(date time.Time, history History) float64 {
+	switch i.strategy {
+	case Exclude:
+		return 0
+	case Median:
+		if units, ok := i.median(date, history); ok {
+			return units
+		}
+	case WeekdayAverage:
+		if units, ok := i.weekdayAverage(date, history); ok {
+			return units
+		}
+	}
+	return i.fixedUnits
+}
+
+// median calculates the median units of the drinking days within the lookback window preceding date.
+func (i Imputer) median(date time.Time, history History) (float64, bool) {
+	end := day(date)
+	start := end.AddDate(0, 0, -i.lookbackDays)
+
+	var values []float64
+	for d, units := range history {
+		if units <= 0 || d.Before(start) || !d.Before(end) {
+			continue
+		}
+		values = append(values, units)
+	}
+
+	if len(values) == 0 {
+		return 0, false
+	}
+
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2, true
+	}
+	return values[mid], true
+}
+
+// weekdayAverage calculates the average units of the same weekday across the preceding weeks. Days with no history
+// are treated as dry.
+func (i Imputer) weekdayAverage(date time.Time, history History) (float64, bool) {
+	if i.weekdayWindow <= 0 {
+		return 0, false
+	}
+
+	var total float64
+	var found bool
+	d := day(date)
+	for w := 0; w < i.weekdayWindow; w++ {
+		d = d.AddDate(0, 0, -7)
+		units, ok := history[d]
+		if ok {
+			found = true
+		}
+		total += units
+	}
+
+	if !found {
+		return 0, false
+	}
+	return total / float64(i.weekdayWindow), true
+}
+
+// day truncates t to the UTC start of its day.
+func day(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
@@ -1,48 +1,212 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/jemgunay/canlendar-graph/api"
+	"github.com/jemgunay/canlendar-graph/auth"
+	"github.com/jemgunay/canlendar-graph/bac"
+	"github.com/jemgunay/canlendar-graph/budget"
 	"github.com/jemgunay/canlendar-graph/calendar"
 	"github.com/jemgunay/canlendar-graph/config"
+	"github.com/jemgunay/canlendar-graph/goal"
+	goalfile "github.com/jemgunay/canlendar-graph/goal/file"
+	"github.com/jemgunay/canlendar-graph/guideline"
+	"github.com/jemgunay/canlendar-graph/impute"
+	"github.com/jemgunay/canlendar-graph/schedule"
+	"github.com/jemgunay/canlendar-graph/session"
+	"github.com/jemgunay/canlendar-graph/stats"
 	"github.com/jemgunay/canlendar-graph/storage/influx"
+	"github.com/jemgunay/canlendar-graph/taper"
+	taperfile "github.com/jemgunay/canlendar-graph/taper/file"
+	"github.com/jemgunay/canlendar-graph/tenant"
+	"github.com/jemgunay/canlendar-graph/watch"
+	watchfile "github.com/jemgunay/canlendar-graph/watch/file"
 )
 
 func main() {
 	// process flags
-	calendarName := flag.String("calendar-name", "Units Consumed", "the name of the calendar documenting units")
+	calendarName := flag.String("calendar-name", "Units Consumed", "the calendar documenting units in single-user mode")
 	local := flag.Bool("local", false, "use local credentials.json file rather than default env creds")
 	flag.Parse()
 
 	conf := config.New()
 
-	calendarRequester, err := calendar.New(*calendarName, *local)
+	users, err := tenant.Load(conf.Tenants, *calendarName)
 	if err != nil {
-		log.Printf("failed to create calendar requester: %s", err)
+		log.Printf("failed to load users: %s", err)
 		os.Exit(1)
 	}
 
+	guidelines := guideline.New(conf.Guideline)
+	for _, user := range users.List() {
+		if _, ok := guidelines.Get(user.GuidelineProfile); !ok {
+			log.Printf("unsupported guideline profile for user '%s': %s", user.ID, user.GuidelineProfile)
+			os.Exit(1)
+		}
+	}
+
+	authenticator, err := auth.New(conf.Auth)
+	if err != nil {
+		log.Printf("failed to create authenticator: %s", err)
+		os.Exit(1)
+	}
+
+	var shareSigner *auth.ShareSigner
+	if conf.Share.Secret != "" {
+		if shareSigner, err = auth.NewShareSigner(conf.Share); err != nil {
+			log.Printf("failed to create share signer: %s", err)
+			os.Exit(1)
+		}
+	}
+	readGuard, err := auth.NewReadGuard(conf.Access, conf.Auth, shareSigner)
+	if err != nil {
+		log.Printf("failed to create read guard: %s", err)
+		os.Exit(1)
+	}
+
+	// calendar requesters are created for each user on first use
+	calendars := calendar.NewPool(*local)
+
 	influxRequester := influx.New(conf.Influx)
-	apiHandlers := api.New(influxRequester, calendarRequester)
+	lockTTL := time.Duration(conf.Collect.LockTTLMinutes) * time.Minute
+	if lockTTL <= 0 {
+		log.Printf("collect lock TTL must be positive: %d minutes", conf.Collect.LockTTLMinutes)
+		os.Exit(1)
+	}
+
+	// optionally register watch channels such that changes to calendars trigger collection
+	var watches *watch.Manager
+	if conf.Watch.Address != "" {
+		var channels watch.Store = influxRequester
+		if conf.Watch.File != "" {
+			channels = watchfile.New(conf.Watch)
+		}
+		watches, err = watch.New(conf.Watch, users, calendars, channels, watch.WithLocker(influxRequester, lockTTL))
+		if err != nil {
+			log.Printf("failed to create watch manager: %s", err)
+			os.Exit(1)
+		}
+	}
+
+	// goals and the tapering plan are shared by every instance unless persisted to local files
+	var goals goal.Storer = influx.NewGoalStore(influxRequester)
+	if conf.Goals.File != "" {
+		goals = goalfile.New(conf.Goals)
+	}
+	var plans taper.Storer = influx.NewTaperStore(influxRequester)
+	if conf.Taper.File != "" {
+		plans = taperfile.New(conf.Taper)
+	}
+
+	apiHandlers := api.New(influxRequester, calendars,
+		api.WithUsers(users),
+		api.WithImputer(impute.New(conf.Impute)),
+		api.WithGuidelines(guidelines),
+		api.WithUnloggedPolicy(stats.Policy(conf.UnloggedDays)),
+		api.WithBudget(budget.New(conf.Budget)),
+		api.WithSessionDetector(session.New(conf.Session)),
+		api.WithBACEstimator(bac.New(conf.Body)),
+		api.WithGoalStore(goals),
+		api.WithTaperStore(plans),
+		api.WithShareSigner(shareSigner),
+		api.WithLocker(influxRequester, lockTTL),
+		api.WithRunStore(influxRequester),
+		api.WithJobStore(influxRequester),
+		api.WithWatchManager(watches),
+	)
+
+	// optionally keep storage up to date without an external scheduler, e.g. Cloud Scheduler
+	scheduler, err := schedule.New(conf.Schedule, func(ctx context.Context) error {
+		job, err := apiHandlers.StartCollect(ctx, api.CollectOptions{})
+		if err != nil {
+			return err
+		}
+		log.Printf("started scheduled collect job %s", job.ID)
+		return nil
+	})
+	switch {
+	case err == nil:
+		go scheduler.Run(context.Background())
+	case !errors.Is(err, schedule.ErrDisabled):
+		log.Printf("failed to create scheduler: %s", err)
+		os.Exit(1)
+	}
+
+	if watches != nil {
+		go watches.Run(context.Background())
+	}
 
 	router := mux.NewRouter()
-	// API handlers
-	router.HandleFunc("/api/v1/query", apiHandlers.Query).Methods(http.MethodGet)
-	router.HandleFunc("/api/v1/collect", apiHandlers.Collect).Methods(http.MethodPost)
+	// API handlers and dashboard accessible via share links
+	shareRouter := router.NewRoute().Subrouter()
+	shareRouter.Use(readGuard.ShareMiddleware, apiHandlers.UserMiddleware)
+	shareRouter.HandleFunc("/api/v1/query", apiHandlers.Query).Methods(http.MethodGet)
+
+	// users selectable by the logged in caller, which is protected by login but not scoped to a user
+	router.Handle("/api/v1/users", readGuard.Middleware(http.HandlerFunc(apiHandlers.ListUsers))).Methods(http.MethodGet)
+
+	// API handlers protected by login
+	readRouter := router.NewRoute().Subrouter()
+	readRouter.Use(readGuard.Middleware, apiHandlers.UserMiddleware)
+	readRouter.HandleFunc("/api/v1/stats", apiHandlers.Stats).Methods(http.MethodGet)
+	readRouter.HandleFunc("/api/v1/streaks", apiHandlers.Streaks).Methods(http.MethodGet)
+	readRouter.HandleFunc("/api/v1/budget", apiHandlers.Budget).Methods(http.MethodGet)
+	readRouter.HandleFunc("/api/v1/bank", apiHandlers.Bank).Methods(http.MethodGet)
+	readRouter.HandleFunc("/api/v1/bank/balance", apiHandlers.BankBalance).Methods(http.MethodGet)
+	readRouter.HandleFunc("/api/v1/compare", apiHandlers.Compare).Methods(http.MethodGet)
+	readRouter.HandleFunc("/api/v1/heatmap", apiHandlers.Heatmap).Methods(http.MethodGet)
+	readRouter.HandleFunc("/api/v1/distribution", apiHandlers.Distribution).Methods(http.MethodGet)
+	readRouter.HandleFunc("/api/v1/sessions", apiHandlers.Sessions).Methods(http.MethodGet)
+	readRouter.HandleFunc("/api/v1/bac", apiHandlers.BAC).Methods(http.MethodGet)
+	readRouter.HandleFunc("/api/v1/goals", apiHandlers.ListGoals).Methods(http.MethodGet)
+	readRouter.HandleFunc("/api/v1/goals/{id}", apiHandlers.GetGoal).Methods(http.MethodGet)
+	readRouter.HandleFunc("/api/v1/taper", apiHandlers.GetTaperPlan).Methods(http.MethodGet)
+
+	// API handlers which write, protected by login, or by the authenticator if read access is public
+	writeRouter := router.NewRoute().Subrouter()
+	writeRouter.Use(readGuard.WriteMiddleware(authenticator.Middleware), apiHandlers.UserMiddleware)
+	writeRouter.HandleFunc("/api/v1/goals", apiHandlers.CreateGoal).Methods(http.MethodPost)
+	writeRouter.HandleFunc("/api/v1/goals/{id}", apiHandlers.UpdateGoal).Methods(http.MethodPut)
+	writeRouter.HandleFunc("/api/v1/goals/{id}", apiHandlers.DeleteGoal).Methods(http.MethodDelete)
+	writeRouter.HandleFunc("/api/v1/taper", apiHandlers.CreateTaperPlan).Methods(http.MethodPost)
+	writeRouter.HandleFunc("/api/v1/taper", apiHandlers.DeleteTaperPlan).Methods(http.MethodDelete)
+	writeRouter.HandleFunc("/api/v1/share", apiHandlers.CreateShare).Methods(http.MethodPost)
+
+	// authenticated API handlers, i.e. triggered by Cloud Scheduler
+	authRouter := router.PathPrefix("/api/v1/collect").Subrouter()
+	authRouter.Use(authenticator.Middleware)
+	authRouter.HandleFunc("", apiHandlers.Collect).Methods(http.MethodPost)
+	authRouter.HandleFunc("/history", apiHandlers.GetCollectHistory).Methods(http.MethodGet)
+	authRouter.HandleFunc("/runs", apiHandlers.GetCollectRuns).Methods(http.MethodGet)
+	authRouter.HandleFunc("/reconcile", apiHandlers.Reconcile).Methods(http.MethodPost)
+	authRouter.HandleFunc("/runs/{id}", apiHandlers.UndoCollectRun).Methods(http.MethodDelete)
+	authRouter.HandleFunc("/{id}", apiHandlers.GetCollectJob).Methods(http.MethodGet)
+
+	// watch channel notifications, verified by channel token
+	router.HandleFunc("/api/v1/watch", apiHandlers.Notify).Methods(http.MethodPost)
+
+	// OIDC browser login, which sets a session cookie accepted by the read guard
+	router.HandleFunc(auth.LoginPath, readGuard.Login).Methods(http.MethodGet)
+	router.HandleFunc(auth.LoginCallbackPath, readGuard.LoginCallback).Methods(http.MethodGet)
 
-	// HTTP file server
+	// HTTP file server for the dashboard, protected by login but accessible via share links
+	staticRouter := router.NewRoute().Subrouter()
+	staticRouter.Use(readGuard.ShareMiddleware)
 	staticFileHandler := http.StripPrefix("/static/", http.FileServer(http.Dir("static/")))
-	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	staticRouter.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		r.URL.Path = "/static/"
 		staticFileHandler.ServeHTTP(w, r)
 	})
-	router.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir("./static"))))
+	staticRouter.PathPrefix("/static/").Handler(staticFileHandler)
 
 	// start HTTP server
 	log.Printf("starting HTTP server on port %d", conf.Port)
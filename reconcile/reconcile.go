@@ -0,0 +1,174 @@
+package reconcile
+
+import (
+	"sort"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/storage"
+)
+
+// Mismatch is a calendar record whose stored record has different values.
+type Mismatch struct {
+	Calendar storage.Record
+	Stored   storage.Record
+}
+
+// Report is the difference between the records derived from calendar events and the stored records within the same
+// time range. Missing records are in the calendar but not in storage, and extra records are in storage but not in the
+// calendar.
+type Report struct {
+	Matched    []storage.Record
+	Missing    []storage.Record
+	Extra      []storage.Record
+	Mismatched []Mismatch
+}
+
+// InSync determines if storage matches the calendar.
+func (r Report) InSync() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Mismatched) == 0
+}
+
+// Diff compares the records derived from calendar events against the stored records. Records are paired by time and
+// calendar event, and records with the same key, such as those written by different runs, are paired with an equivalent
+// record where possible. Stored records without an event tag, i.e. written before events were tagged, are never paired.
+// Imputed records are equivalent regardless of their units, as imputation depends on the history available when the
+// record was collected.
+func Diff(calendar, stored []storage.Record) Report {
+	calendarByKey := groupByKey(calendar)
+	storedByKey := groupByKey(stored)
+
+	keys := make([]storage.RecordKey, 0, len(calendarByKey)+len(storedByKey))
+	for key := range calendarByKey {
+		keys = append(keys, key)
+	}
+	for key := range storedByKey {
+		if _, ok := calendarByKey[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keyLess(keys[i], keys[j])
+	})
+
+	var report Report
+	for _, key := range keys {
+		remaining := append([]storage.Record(nil), storedByKey[key]...)
+
+		// pair equivalent records first, such that only the remainder are reported
+		var unmatched []storage.Record
+		for _, record := range calendarByKey[key] {
+			if i := indexOfEquivalent(remaining, record); i >= 0 {
+				report.Matched = append(report.Matched, record)
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				continue
+			}
+			unmatched = append(unmatched, record)
+		}
+
+		// untagged stored records cannot be matched to an event, so they are replaced rather than mismatched
+		if key.Event == "" {
+			report.Missing = append(report.Missing, unmatched...)
+			report.Extra = append(report.Extra, remaining...)
+			continue
+		}
+
+		for len(unmatched) > 0 && len(remaining) > 0 {
+			report.Mismatched = append(report.Mismatched, Mismatch{
+				Calendar: unmatched[0],
+				Stored:   remaining[0],
+			})
+			unmatched, remaining = unmatched[1:], remaining[1:]
+		}
+		report.Missing = append(report.Missing, unmatched...)
+		report.Extra = append(report.Extra, remaining...)
+	}
+	return report
+}
+
+// Deletion is a set of stored records to delete: those at the time which were parsed from the event. Every record at
+// the time is deleted if the event is empty, as deletions cannot match records without an event tag.
+type Deletion struct {
+	Time  time.Time
+	Event string
+}
+
+// Repairs returns the changes which bring storage in line with the calendar: the stored records which must be deleted,
+// and the calendar records to write once they have been deleted. Matched records covered by a deletion are written
+// again, as deletions cannot distinguish between the records of different runs, nor between the records at the same
+// time when the event is empty.
+func (r Report) Repairs() ([]Deletion, []storage.Record) {
+	deleted := make(map[storage.RecordKey]bool)
+	var deletions []Deletion
+	deleteRecord := func(record storage.Record) {
+		if key := record.Key(); !deleted[key] {
+			deleted[key] = true
+			deletions = append(deletions, Deletion{Time: record.Time, Event: key.Event})
+		}
+	}
+	for _, record := range r.Extra {
+		deleteRecord(record)
+	}
+	for _, mismatch := range r.Mismatched {
+		deleteRecord(mismatch.Stored)
+	}
+
+	writes := append([]storage.Record(nil), r.Missing...)
+	for _, mismatch := range r.Mismatched {
+		writes = append(writes, mismatch.Calendar)
+	}
+	for _, record := range r.Matched {
+		key := record.Key()
+		if deleted[key] || deleted[storage.RecordKey{Time: key.Time}] {
+			writes = append(writes, record)
+		}
+	}
+
+	sort.Slice(deletions, func(i, j int) bool {
+		return keyLess(
+			storage.RecordKey{Time: deletions[i].Time.UnixNano(), Event: deletions[i].Event},
+			storage.RecordKey{Time: deletions[j].Time.UnixNano(), Event: deletions[j].Event},
+		)
+	})
+	sort.SliceStable(writes, func(i, j int) bool {
+		return writes[i].Time.Before(writes[j].Time)
+	})
+	return deletions, writes
+}
+
+// groupByKey groups records by their time and event.
+func groupByKey(records []storage.Record) map[storage.RecordKey][]storage.Record {
+	grouped := make(map[storage.RecordKey][]storage.Record, len(records))
+	for _, record := range records {
+		grouped[record.Key()] = append(grouped[record.Key()], record)
+	}
+	return grouped
+}
+
+// keyLess orders record keys by time, then by event.
+func keyLess(a, b storage.RecordKey) bool {
+	if a.Time != b.Time {
+		return a.Time < b.Time
+	}
+	return a.Event < b.Event
+}
+
+// indexOfEquivalent returns the index of the first record which is equivalent to the target record, or -1 if there is
+// none.
+func indexOfEquivalent(records []storage.Record, target storage.Record) int {
+	for i, record := range records {
+		if equivalent(record, target) {
+			return i
+		}
+	}
+	return -1
+}
+
+// equivalent determines if two records have the same values. The units of imputed records are not compared.
+func equivalent(a, b storage.Record) bool {
+	if a.Tags[storage.ImputedTag] == "true" && b.Tags[storage.ImputedTag] == "true" {
+		_, offsetA := a.LocalTime().Zone()
+		_, offsetB := b.LocalTime().Zone()
+		return a.Timed() == b.Timed() && offsetA == offsetB
+	}
+	return a.SameValues(b)
+}
@@ -0,0 +1,213 @@
+package reconcile
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/storage"
+)
+
+var day = time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+
+// record creates a record for the event at the time, which is untagged if the event is empty.
+func record(t time.Time, event string, units float64, imputed bool) storage.Record {
+	tags := map[string]string{storage.ImputedTag: strconv.FormatBool(imputed)}
+	if event != "" {
+		tags[storage.EventTag] = event
+	}
+	return storage.Record{
+		Time:   t,
+		Tags:   tags,
+		Fields: map[string]interface{}{storage.UnitsField: units, storage.TimedField: false, storage.UTCOffsetField: int64(0)},
+	}
+}
+
+// describe describes records by their event and units, such that they can be compared.
+func describe(records []storage.Record) []string {
+	var described []string
+	for _, r := range records {
+		described = append(described, r.Tags[storage.EventTag]+"="+strconv.FormatFloat(r.Units(), 'f', -1, 64))
+	}
+	return described
+}
+
+func TestDiff(t *testing.T) {
+	cases := []struct {
+		name       string
+		calendar   []storage.Record
+		stored     []storage.Record
+		matched    []string
+		missing    []string
+		extra      []string
+		mismatched [][2]string
+	}{
+		{
+			// all-day events on the same date are paired by event rather than alternating between each other
+			name:     "same_time",
+			calendar: []storage.Record{record(day, "first", 4, false), record(day, "second", 3, false)},
+			stored:   []storage.Record{record(day, "second", 3, false), record(day, "first", 4, false)},
+			matched:  []string{"first=4", "second=3"},
+		},
+		{
+			name:       "same_time_changed",
+			calendar:   []storage.Record{record(day, "first", 4, false), record(day, "second", 3, false)},
+			stored:     []storage.Record{record(day, "first", 4, false), record(day, "second", 2, false)},
+			matched:    []string{"first=4"},
+			mismatched: [][2]string{{"second=3", "second=2"}},
+		},
+		{
+			// records are not paired with an equivalent record of another event at the same time
+			name:     "same_time_swapped",
+			calendar: []storage.Record{record(day, "first", 4, false), record(day, "second", 3, false)},
+			stored:   []storage.Record{record(day, "first", 3, false), record(day, "second", 4, false)},
+			mismatched: [][2]string{
+				{"first=4", "first=3"},
+				{"second=3", "second=4"},
+			},
+		},
+		{
+			name:     "same_time_missing",
+			calendar: []storage.Record{record(day, "first", 4, false), record(day, "second", 3, false)},
+			stored:   []storage.Record{record(day, "first", 4, false)},
+			matched:  []string{"first=4"},
+			missing:  []string{"second=3"},
+		},
+		{
+			// records written by different runs are stored separately
+			name:     "duplicate_run",
+			calendar: []storage.Record{record(day, "first", 4, false)},
+			stored:   []storage.Record{record(day, "first", 5, false), record(day, "first", 4, false)},
+			matched:  []string{"first=4"},
+			extra:    []string{"first=5"},
+		},
+		{
+			name:     "imputed",
+			calendar: []storage.Record{record(day, "first", 0, true)},
+			stored:   []storage.Record{record(day, "first", 3, true)},
+			matched:  []string{"first=0"},
+		},
+		{
+			// untagged records cannot be matched to an event
+			name:     "untagged",
+			calendar: []storage.Record{record(day, "first", 4, false)},
+			stored:   []storage.Record{record(day, "", 4, false)},
+			missing:  []string{"first=4"},
+			extra:    []string{"=4"},
+		},
+		{
+			name:     "removed",
+			calendar: []storage.Record{record(day, "first", 4, false)},
+			stored:   []storage.Record{record(day, "first", 4, false), record(day.AddDate(0, 0, 1), "second", 2, false)},
+			matched:  []string{"first=4"},
+			extra:    []string{"second=2"},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Diff(tt.calendar, tt.stored)
+
+			var mismatched [][2]string
+			for _, mismatch := range report.Mismatched {
+				mismatched = append(mismatched, [2]string{
+					describe([]storage.Record{mismatch.Calendar})[0],
+					describe([]storage.Record{mismatch.Stored})[0],
+				})
+			}
+			if !reflect.DeepEqual(describe(report.Matched), tt.matched) {
+				t.Fatalf("expected matched %v, got %v", tt.matched, describe(report.Matched))
+			}
+			if !reflect.DeepEqual(describe(report.Missing), tt.missing) {
+				t.Fatalf("expected missing %v, got %v", tt.missing, describe(report.Missing))
+			}
+			if !reflect.DeepEqual(describe(report.Extra), tt.extra) {
+				t.Fatalf("expected extra %v, got %v", tt.extra, describe(report.Extra))
+			}
+			if !reflect.DeepEqual(mismatched, tt.mismatched) {
+				t.Fatalf("expected mismatched %v, got %v", tt.mismatched, mismatched)
+			}
+			if inSync := len(tt.missing)+len(tt.extra)+len(tt.mismatched) == 0; report.InSync() != inSync {
+				t.Fatalf("expected in sync to be %t, got %t", inSync, report.InSync())
+			}
+		})
+	}
+}
+
+func TestReport_Repairs(t *testing.T) {
+	cases := []struct {
+		name      string
+		report    Report
+		deletions []Deletion
+		writes    []string
+	}{
+		{
+			name:   "in_sync",
+			report: Report{Matched: []storage.Record{record(day, "first", 4, false)}},
+		},
+		{
+			// only the changed event is replaced, leaving the other event at the same time
+			name: "mismatched",
+			report: Report{
+				Matched: []storage.Record{record(day, "first", 4, false)},
+				Mismatched: []Mismatch{
+					{Calendar: record(day, "second", 3, false), Stored: record(day, "second", 2, false)},
+				},
+			},
+			deletions: []Deletion{{Time: day, Event: "second"}},
+			writes:    []string{"second=3"},
+		},
+		{
+			// deleting the extra record of another run also deletes the matched record, so it is written again
+			name: "duplicate_run",
+			report: Report{
+				Matched: []storage.Record{record(day, "first", 4, false)},
+				Extra:   []storage.Record{record(day, "first", 5, false)},
+			},
+			deletions: []Deletion{{Time: day, Event: "first"}},
+			writes:    []string{"first=4"},
+		},
+		{
+			// deleting an untagged record deletes every record at its time
+			name: "untagged",
+			report: Report{
+				Matched: []storage.Record{
+					record(day, "first", 4, false),
+					record(day.AddDate(0, 0, 1), "third", 1, false),
+				},
+				Missing: []storage.Record{record(day, "second", 3, false)},
+				Extra:   []storage.Record{record(day, "", 7, false)},
+			},
+			deletions: []Deletion{{Time: day}},
+			writes:    []string{"second=3", "first=4"},
+		},
+		{
+			name: "sorted",
+			report: Report{
+				Extra: []storage.Record{
+					record(day.AddDate(0, 0, 1), "third", 1, false),
+					record(day, "second", 3, false),
+					record(day, "first", 4, false),
+				},
+			},
+			deletions: []Deletion{
+				{Time: day, Event: "first"},
+				{Time: day, Event: "second"},
+				{Time: day.AddDate(0, 0, 1), Event: "third"},
+			},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			deletions, writes := tt.report.Repairs()
+			if !reflect.DeepEqual(deletions, tt.deletions) {
+				t.Fatalf("expected deletions %v, got %v", tt.deletions, deletions)
+			}
+			if !reflect.DeepEqual(describe(writes), tt.writes) {
+				t.Fatalf("expected writes %v, got %v", tt.writes, describe(writes))
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var _ Schedule = (*Cron)(nil)
+
+// cronMacros maps the supported cron macros to their expressions.
+var cronMacros = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// cronField is the range of values of a cron field.
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	{name: "day of week", min: 0, max: 7},
+}
+
+// Cron is a Schedule defined by a standard five field cron expression (minute, hour, day of month, month and day of
+// week), evaluated in UTC. Each field supports wildcards, lists, ranges and steps, e.g. "*/15 6-22 * * 1,3,5".
+type Cron struct {
+	minutes, hours, days, months, weekdays map[int]bool
+	// restricted days of the month and week match either, as opposed to both
+	anyDay bool
+}
+
+// ParseCron parses a cron expression or one of the macros @hourly, @daily, @weekly and @monthly.
+func ParseCron(expr string) (*Cron, error) {
+	if macro, ok := cronMacros[expr]; ok {
+		expr = macro
+	}
+
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression must have %d fields: %s", len(cronFields), expr)
+	}
+
+	sets := make([]map[int]bool, len(parts))
+	for i, part := range parts {
+		set, err := parseCronField(part, cronFields[i])
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = set
+	}
+
+	// Sunday is both 0 and 7
+	if sets[4][7] {
+		sets[4][0] = true
+	}
+
+	c := &Cron{
+		minutes:  sets[0],
+		hours:    sets[1],
+		days:     sets[2],
+		months:   sets[3],
+		weekdays: sets[4],
+		anyDay:   !strings.HasPrefix(parts[2], "*") && !strings.HasPrefix(parts[4], "*"),
+	}
+	if c.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("cron expression never matches: %s", expr)
+	}
+	return c, nil
+}
+
+// parseCronField parses a comma separated list of values, ranges and steps into the set of matching values.
+func parseCronField(field string, bounds cronField) (map[int]bool, error) {
+	set := make(map[int]bool)
+	for _, item := range strings.Split(field, ",") {
+		values, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(item[i+1:]); err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid %s step: %s", bounds.name, item)
+			}
+			values = item[:i]
+		}
+
+		start, end := bounds.min, bounds.max
+		if values != "*" {
+			limits := strings.SplitN(values, "-", 2)
+			var err error
+			if start, err = strconv.Atoi(limits[0]); err != nil {
+				return nil, fmt.Errorf("invalid %s: %s", bounds.name, item)
+			}
+			switch {
+			case len(limits) == 2:
+				if end, err = strconv.Atoi(limits[1]); err != nil {
+					return nil, fmt.Errorf("invalid %s: %s", bounds.name, item)
+				}
+			case step == 1:
+				end = start
+			}
+			// otherwise a single value with a step runs to the end of the range, e.g. "5/15"
+		}
+		if start < bounds.min || end > bounds.max || start > end {
+			return nil, fmt.Errorf("%s out of range %d-%d: %s", bounds.name, bounds.min, bounds.max, item)
+		}
+
+		for v := start; v <= end; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// Next returns the first minute after the provided time which matches the expression.
+func (c *Cron) Next(after time.Time) time.Time {
+	t := after.UTC().Truncate(time.Minute).Add(time.Minute)
+
+	// every satisfiable expression matches within 8 years, i.e. 29 February around a skipped leap year
+	limit := t.AddDate(9, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !c.months[int(t.Month())]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !c.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case !c.hours[t.Hour()]:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case !c.minutes[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	// the expression never matches, e.g. 30 February
+	return time.Time{}
+}
+
+// matchDay determines if the day of the month and day of the week match. If both are restricted, either may match.
+func (c *Cron) matchDay(t time.Time) bool {
+	day, weekday := c.days[t.Day()], c.weekdays[int(t.Weekday())]
+	if c.anyDay {
+		return day || weekday
+	}
+	return day && weekday
+}
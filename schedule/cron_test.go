@@ -0,0 +1,103 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCron_Next(t *testing.T) {
+	// a Monday
+	after := time.Date(2022, 8, 1, 10, 7, 30, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		expr     string
+		expected time.Time
+		err      bool
+	}{
+		{
+			name:     "every_minute",
+			expr:     "* * * * *",
+			expected: time.Date(2022, 8, 1, 10, 8, 0, 0, time.UTC),
+		},
+		{
+			name:     "step",
+			expr:     "*/15 * * * *",
+			expected: time.Date(2022, 8, 1, 10, 15, 0, 0, time.UTC),
+		},
+		{
+			name:     "offset_step",
+			expr:     "5/15 * * * *",
+			expected: time.Date(2022, 8, 1, 10, 20, 0, 0, time.UTC),
+		},
+		{
+			name:     "hourly",
+			expr:     "@hourly",
+			expected: time.Date(2022, 8, 1, 11, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "list_and_range",
+			expr:     "30 6,22 * * 2-4",
+			expected: time.Date(2022, 8, 2, 6, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "sunday_as_seven",
+			expr:     "0 0 * * 7",
+			expected: time.Date(2022, 8, 7, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "day_of_month_or_week",
+			expr:     "0 0 15 * 5",
+			expected: time.Date(2022, 8, 5, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "next_year",
+			expr:     "0 0 1 1 *",
+			expected: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "leap_day",
+			expr:     "0 0 29 2 *",
+			expected: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "too_few_fields",
+			expr: "0 0 * *",
+			err:  true,
+		},
+		{
+			name: "out_of_range",
+			expr: "60 * * * *",
+			err:  true,
+		},
+		{
+			name: "invalid_step",
+			expr: "*/0 * * * *",
+			err:  true,
+		},
+		{
+			name: "never_matches",
+			expr: "0 0 30 2 *",
+			err:  true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseCron(tt.expr)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if next := c.Next(after); !next.Equal(tt.expected) {
+				t.Fatalf("expected %s, got %s", tt.expected, next)
+			}
+		})
+	}
+}
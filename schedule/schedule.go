@@ -0,0 +1,174 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/config"
+	"github.com/jemgunay/canlendar-graph/internal/jsonfile"
+)
+
+// ErrDisabled indicates that neither a cron expression nor an interval is configured.
+var ErrDisabled = errors.New("no schedule configured")
+
+// Schedule determines when a job runs.
+type Schedule interface {
+	// Next returns the next run time after the provided time.
+	Next(after time.Time) time.Time
+}
+
+var _ Schedule = Interval(0)
+
+// Interval is a Schedule which runs at a fixed interval.
+type Interval time.Duration
+
+// Next returns the time one interval after the provided time.
+func (i Interval) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(i))
+}
+
+// Parse parses the cron expression or interval from config. ErrDisabled is returned if neither is set.
+func Parse(conf config.Schedule) (Schedule, error) {
+	switch {
+	case conf.Cron != "" && conf.IntervalMinutes > 0:
+		return nil, errors.New("only one of a cron expression or an interval can be configured")
+	case conf.Cron != "":
+		return ParseCron(conf.Cron)
+	case conf.IntervalMinutes > 0:
+		return Interval(time.Duration(conf.IntervalMinutes) * time.Minute), nil
+	}
+	return nil, ErrDisabled
+}
+
+// Job is run by a Scheduler. An error indicates that the run was skipped, i.e. because a previous run is still in
+// progress.
+type Job func(ctx context.Context) error
+
+// state is the scheduler state persisted between restarts.
+type state struct {
+	LastRun time.Time `json:"last_run"`
+}
+
+// Scheduler runs a job in-process on a schedule, delaying each run by a random jitter. The time of the last run is
+// persisted to a file, such that runs missed while the process was stopped are caught up on start.
+type Scheduler struct {
+	schedule Schedule
+	jitter   time.Duration
+	file     string
+	job      Job
+	now      func() time.Time
+
+	mu      sync.Mutex
+	lastRun time.Time
+}
+
+// New initialises a Scheduler from config. ErrDisabled is returned if no schedule is configured. The last run time is
+// not persisted if no state file is configured.
+func New(conf config.Schedule, job Job) (*Scheduler, error) {
+	schedule, err := Parse(conf)
+	if err != nil {
+		return nil, err
+	}
+	if conf.JitterSeconds < 0 {
+		return nil, errors.New("schedule jitter must not be negative")
+	}
+
+	return &Scheduler{
+		schedule: schedule,
+		jitter:   time.Duration(conf.JitterSeconds) * time.Second,
+		file:     conf.StateFile,
+		job:      job,
+		now:      time.Now,
+	}, nil
+}
+
+// LastRun returns the time of the last run, or zero if the job has never run.
+func (s *Scheduler) LastRun() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lastRun
+}
+
+// Run runs the job on schedule until the context is cancelled. The job runs immediately if it has never run, or if a
+// run was missed since the last run.
+func (s *Scheduler) Run(ctx context.Context) {
+	if err := s.load(); err != nil {
+		log.Printf("failed to load schedule state: %s", err)
+	}
+
+	next := s.now()
+	if lastRun := s.LastRun(); !lastRun.IsZero() && s.schedule.Next(lastRun).After(next) {
+		next = s.schedule.Next(lastRun)
+	}
+
+	for {
+		delay := next.Sub(s.now())
+		if s.jitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(s.jitter)))
+		}
+		log.Printf("next scheduled collect in %s", delay.Round(time.Second))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.tick(ctx)
+		next = s.schedule.Next(s.now())
+	}
+}
+
+// tick runs the job, recording the run time unless the run was skipped.
+func (s *Scheduler) tick(ctx context.Context) {
+	started := s.now().UTC()
+	if err := s.job(ctx); err != nil {
+		log.Printf("skipping scheduled run: %s", err)
+		return
+	}
+
+	s.mu.Lock()
+	s.lastRun = started
+	s.mu.Unlock()
+
+	if err := s.save(started); err != nil {
+		log.Printf("failed to save schedule state: %s", err)
+	}
+}
+
+// load reads the last run time from the state file.
+func (s *Scheduler) load() error {
+	if s.file == "" {
+		return nil
+	}
+
+	st := state{}
+	if _, err := jsonfile.Read(s.file, &st); err != nil {
+		return fmt.Errorf("failed to read schedule state: %w", err)
+	}
+
+	s.mu.Lock()
+	s.lastRun = st.LastRun
+	s.mu.Unlock()
+	return nil
+}
+
+// save writes the last run time to the state file.
+func (s *Scheduler) save(lastRun time.Time) error {
+	if s.file == "" {
+		return nil
+	}
+
+	if err := jsonfile.Write(s.file, state{LastRun: lastRun}); err != nil {
+		return fmt.Errorf("failed to write schedule state: %w", err)
+	}
+	return nil
+}
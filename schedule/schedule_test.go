@@ -0,0 +1,100 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/internal/jsonfile"
+)
+
+func TestScheduler_Run(t *testing.T) {
+	cases := []struct {
+		name    string
+		lastRun time.Duration
+		busy    bool
+		runs    bool
+	}{
+		{
+			name: "never_run",
+			runs: true,
+		},
+		{
+			name:    "missed_run",
+			lastRun: -2 * time.Hour,
+			runs:    true,
+		},
+		{
+			name:    "up_to_date",
+			lastRun: -time.Minute,
+			runs:    false,
+		},
+		{
+			name: "skipped",
+			busy: true,
+			runs: true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "schedule.json")
+			var lastRun time.Time
+			if tt.lastRun != 0 {
+				lastRun = time.Now().Add(tt.lastRun).UTC()
+				if err := jsonfile.Write(file, state{LastRun: lastRun}); err != nil {
+					t.Fatalf("failed to write state: %s", err)
+				}
+			}
+
+			ran := make(chan struct{}, 1)
+			s := &Scheduler{
+				schedule: Interval(time.Hour),
+				file:     file,
+				now:      time.Now,
+				job: func(ctx context.Context) error {
+					ran <- struct{}{}
+					if tt.busy {
+						return errors.New("job already running")
+					}
+					return nil
+				},
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				s.Run(ctx)
+				close(done)
+			}()
+
+			select {
+			case <-ran:
+				if !tt.runs {
+					t.Fatalf("expected no run")
+				}
+			case <-time.After(100 * time.Millisecond):
+				if tt.runs {
+					t.Fatalf("expected a run")
+				}
+			}
+			cancel()
+			<-done
+
+			// the last run is only recorded if the run was not skipped
+			saved := state{}
+			if _, err := jsonfile.Read(file, &saved); err != nil {
+				t.Fatalf("failed to read state: %s", err)
+			}
+			recorded := tt.runs && !tt.busy
+			if recorded == saved.LastRun.Equal(lastRun) {
+				t.Fatalf("expected last run to be recorded to be %t, got %s", recorded, saved.LastRun)
+			}
+			if !s.LastRun().Equal(saved.LastRun) {
+				t.Fatalf("expected %s, got %s", saved.LastRun, s.LastRun())
+			}
+		})
+	}
+}
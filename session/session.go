@@ -0,0 +1,131 @@
+package session
+
+import (
+	"sort"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/config"
+	"github.com/jemgunay/canlendar-graph/storage"
+)
+
+// Session is a group of drinking events. Sessions of events without a time of day span the whole drinking day and have
+// no duration.
+type Session struct {
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	DrinkingDay     string    `json:"drinking_day"`
+	Events          int       `json:"events"`
+	Units           float64   `json:"units"`
+	DurationMinutes float64   `json:"duration_minutes"`
+	Timed           bool      `json:"timed"`
+	Binge           bool      `json:"binge"`
+}
+
+// Detector groups records into sessions.
+type Detector struct {
+	gap    time.Duration
+	cutoff int
+}
+
+// New initialises a Detector from config.
+func New(conf config.Session) Detector {
+	return Detector{
+		gap:    time.Duration(conf.GapMinutes) * time.Minute,
+		cutoff: conf.DrinkingDayCutoffHour,
+	}
+}
+
+// DrinkingDay returns the drinking day that a local time belongs to, as midnight UTC. Times before the cutoff hour
+// belong to the previous drinking day.
+func (d Detector) DrinkingDay(t time.Time) time.Time {
+	y, m, day := t.Add(-time.Duration(d.cutoff) * time.Hour).Date()
+	return time.Date(y, m, day, 0, 0, 0, 0, time.UTC)
+}
+
+// Detect groups records into sessions. Timed records belong to the same session if they are on the same drinking day
+// and are separated by no more than the detector's gap. Records without a time of day on the same date form a single
+// session. Sessions with units exceeding bingeUnits are flagged as binges.
+func (d Detector) Detect(records []storage.Record, bingeUnits float64) []Session {
+	sorted := make([]storage.Record, len(records))
+	copy(sorted, records)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Time.Before(sorted[j].Time)
+	})
+
+	var sessions []Session
+	untimed := make(map[time.Time]int)
+	var current *Session
+	for _, r := range sorted {
+		local := r.LocalTime()
+
+		if !r.Timed() {
+			day := storage.Day.PeriodStart(r.Time)
+			i, ok := untimed[day]
+			if !ok {
+				sessions = append(sessions, Session{
+					Start:       day,
+					End:         day,
+					DrinkingDay: day.Format("2006-01-02"),
+				})
+				i = len(sessions) - 1
+				untimed[day] = i
+			}
+			sessions[i].Events++
+			sessions[i].Units += r.Units()
+			continue
+		}
+
+		drinkingDay := d.DrinkingDay(local).Format("2006-01-02")
+		if current == nil || current.DrinkingDay != drinkingDay || r.Time.Sub(current.End) > d.gap {
+			if current != nil {
+				sessions = append(sessions, *current)
+			}
+			current = &Session{
+				Start:       local,
+				DrinkingDay: drinkingDay,
+				Timed:       true,
+			}
+		}
+		current.End = local
+		current.Events++
+		current.Units += r.Units()
+	}
+	if current != nil {
+		sessions = append(sessions, *current)
+	}
+
+	for i := range sessions {
+		sessions[i].DurationMinutes = sessions[i].End.Sub(sessions[i].Start).Minutes()
+		sessions[i].Binge = sessions[i].Units > bingeUnits
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].Start.Before(sessions[j].Start)
+	})
+	return sessions
+}
+
+// CountBinges counts the binge sessions within each aggregation period between start and end, by drinking day.
+// Periods with no binges are included with a zero count.
+func CountBinges(sessions []Session, aggregation storage.Aggregation, start, end time.Time) []storage.Plot {
+	counts := make(map[time.Time]float64)
+	for _, s := range sessions {
+		if !s.Binge {
+			continue
+		}
+		day, err := time.Parse("2006-01-02", s.DrinkingDay)
+		if err != nil {
+			continue
+		}
+		counts[aggregation.PeriodStart(day)]++
+	}
+
+	var plots []storage.Plot
+	for p := aggregation.PeriodStart(start); p.Before(end); p = aggregation.PeriodEnd(p) {
+		plots = append(plots, storage.Plot{
+			X: p.UnixMilli(),
+			Y: counts[p],
+		})
+	}
+	return plots
+}
@@ -5,21 +5,45 @@ function toTitleCase(str) {
     );
 }
 
+const params = new URLSearchParams(window.location.search);
+
+// share token from a share link, if any, which is forwarded to the query API
+const shareToken = params.get('share');
+
+// user whose data is displayed, selected by the user parameter or defaulting to the first user of the login; share
+// links are bound to the user they were minted for
+let selectedUser = params.get('user');
+
+// currently displayed view
+let currentView = 'week';
+
 function newGraph(options) {
     const currentDate = new Date();
 
+    let data = {
+        // TODO: plug in start time/end time
+        "aggregation": options.view,
+        //"start_time": "",
+        "end_time": currentDate.toISOString(),
+    };
+    if (shareToken !== null) {
+        data.share = shareToken;
+    }
+    if (selectedUser !== null) {
+        data.user = selectedUser;
+    }
+
     // fetch graph data from server
     $.ajax({
         url: '/api/v1/query',
-        data: {
-            // TODO: plug in start time/end time
-            "aggregation": options.view,
-            //"start_time": "",
-            "end_time": currentDate.toISOString(),
-        },
+        data: data,
         type: 'GET',
         dataType: 'json',
         error: function (e) {
+            if (e.status === 403 && shareToken !== null) {
+                alert(options.view + ' data is not included in this share link');
+                return;
+            }
             alert('failed to retrieve ' + options.view + ' data (' + e.status + ')');
         },
         success: function (data) {
@@ -34,6 +58,13 @@ function newGraph(options) {
 
 let currentChart = null;
 
+// point colours for each risk tier
+const tierColours = {
+    lower: 'rgb(75, 192, 120)',
+    increasing: 'rgb(255, 180, 60)',
+    higher: 'rgb(220, 40, 60)'
+};
+
 function drawGraph(options, data) {
     // clean up previous chart
     if (currentChart !== null) {
@@ -52,7 +83,10 @@ function drawGraph(options, data) {
                 borderColor: 'rgb(255, 99, 132)',
                 backgroundColor: 'rgba(255, 99, 132, 0.1)',
                 borderWidth: 2,
-                lineTension: 0
+                lineTension: 0,
+                pointBackgroundColor: data.plots.map(function (p) {
+                    return tierColours[p.tier] || 'rgb(255, 99, 132)';
+                })
             }]
         },
 
@@ -105,14 +139,11 @@ function drawGraph(options, data) {
         }
     };
 
-    // display UK units guideline
-    if (options.enableGuideline === true) {
+    // display units guideline for the selected guideline profile
+    if (options.enableGuideline === true && data.metadata.guideline_plots) {
         chartConfig.data.datasets.push({
-            data: [
-                {y: data.metadata.guideline, t: data.plots[0].t},
-                {y: data.metadata.guideline, t: data.plots[data.plots.length - 1].t}
-            ],
-            label: 'Units Guideline (UK)',
+            data: data.metadata.guideline_plots,
+            label: 'Units Guideline (' + data.metadata.guideline_profile.toUpperCase() + ')',
             borderColor: 'rgb(109,109,109)',
             fill: false,
             borderWidth: 2,
@@ -120,12 +151,25 @@ function drawGraph(options, data) {
         });
     }
 
+    // display the weekly targets of the tapering plan, if any
+    if (data.metadata.taper_plots) {
+        chartConfig.data.datasets.push({
+            data: data.metadata.taper_plots,
+            label: 'Tapering Plan',
+            borderColor: 'rgb(54, 162, 235)',
+            fill: false,
+            borderWidth: 2,
+            borderDash: [4]
+        });
+    }
+
     // create new chart
     let ctx = document.getElementById('main-graph').getContext('2d');
     currentChart = new Chart(ctx, chartConfig);
 }
 
 function selectGraph(view) {
+    currentView = view;
     switch (view) {
         case 'year':
             newGraph({
@@ -170,5 +214,42 @@ $('a[data-toggle="tab"]').on('shown.bs.tab', function (e) {
     selectGraph(id.substring(0, id.length - 4));
 })
 
-// draw initial graph
-selectGraph('week');
\ No newline at end of file
+// load the users selectable by the login, then draw the initial graph
+function loadUsers() {
+    if (shareToken !== null) {
+        selectGraph(currentView);
+        return;
+    }
+
+    $.ajax({
+        url: '/api/v1/users',
+        type: 'GET',
+        dataType: 'json',
+        error: function (e) {
+            alert('failed to retrieve users (' + e.status + ')');
+        },
+        success: function (data) {
+            if (selectedUser === null && data.users.length > 0) {
+                selectedUser = data.users[0];
+            }
+
+            // only offer a choice of user if the login has several
+            if (data.users.length > 1) {
+                let select = $('#user-select');
+                data.users.forEach(function (user) {
+                    select.append($('<option>').val(user).text(user));
+                });
+                select.val(selectedUser).removeClass('d-none');
+            }
+            selectGraph(currentView);
+        }
+    });
+}
+
+// redraw the current graph for a newly selected user
+$('#user-select').on('change', function () {
+    selectedUser = $(this).val();
+    selectGraph(currentView);
+})
+
+loadUsers();
\ No newline at end of file
@@ -0,0 +1,65 @@
+package stats
+
+import (
+	"github.com/jemgunay/canlendar-graph/storage"
+)
+
+// Change is the difference between a current and a reference value. PercentageChange is nil if the reference value
+// is zero.
+type Change struct {
+	Current          float64  `json:"current"`
+	Reference        float64  `json:"reference"`
+	Delta            float64  `json:"delta"`
+	PercentageChange *float64 `json:"percentage_change"`
+}
+
+// NewChange calculates the change from a reference value to a current value.
+func NewChange(current, reference float64) Change {
+	c := Change{
+		Current:   current,
+		Reference: reference,
+		Delta:     current - reference,
+	}
+	if reference != 0 {
+		pct := c.Delta / reference * 100
+		c.PercentageChange = &pct
+	}
+	return c
+}
+
+// AlignedChange is the change between a pair of aligned plots. X is the timestamp of the current plot, and
+// ReferenceX is the timestamp of the reference plot it is aligned with.
+type AlignedChange struct {
+	X          int64 `json:"t"`
+	ReferenceX int64 `json:"reference_t"`
+	Change
+}
+
+// Compare aligns two series of aggregated plots by their position within each series, i.e. the first period of the
+// current series is aligned with the first period of the reference series. Only periods present in both series are
+// compared.
+func Compare(current, reference []storage.Plot) []AlignedChange {
+	n := len(current)
+	if len(reference) < n {
+		n = len(reference)
+	}
+
+	changes := make([]AlignedChange, 0, n)
+	for i := 0; i < n; i++ {
+		changes = append(changes, AlignedChange{
+			X:          current[i].X,
+			ReferenceX: reference[i].X,
+			Change:     NewChange(current[i].Y, reference[i].Y),
+		})
+	}
+	return changes
+}
+
+// Total sums the Y values of a set of plots.
+func Total(plots []storage.Plot) float64 {
+	var total float64
+	for _, p := range plots {
+		total += p.Y
+	}
+	return total
+}
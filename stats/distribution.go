@@ -0,0 +1,122 @@
+package stats
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/storage"
+)
+
+// Distribution summarises a set of values.
+type Distribution struct {
+	Count int     `json:"count"`
+	Total float64 `json:"total"`
+	Mean  float64 `json:"mean"`
+	P50   float64 `json:"p50"`
+	P75   float64 `json:"p75"`
+	P90   float64 `json:"p90"`
+}
+
+// NewDistribution summarises a set of values.
+func NewDistribution(values []float64) Distribution {
+	d := Distribution{
+		Count: len(values),
+	}
+	if len(values) == 0 {
+		return d
+	}
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	for _, v := range sorted {
+		d.Total += v
+	}
+	d.Mean = d.Total / float64(len(sorted))
+	d.P50 = Percentile(sorted, 50)
+	d.P75 = Percentile(sorted, 75)
+	d.P90 = Percentile(sorted, 90)
+	return d
+}
+
+// Percentile calculates the pth percentile of a set of sorted values, linearly interpolating between the closest
+// ranks.
+func Percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// WeekdayDistribution is the distribution of daily unit totals for a weekday.
+type WeekdayDistribution struct {
+	Weekday string `json:"weekday"`
+	Distribution
+}
+
+// HourDistribution is the distribution of event units for an hour of the day.
+type HourDistribution struct {
+	Hour int `json:"hour"`
+	Distribution
+}
+
+// DailyTotals sums the units of a set of records by their local date. Every date within [start, end) is included,
+// where dates with no records have no units.
+func DailyTotals(records []storage.Record, start, end time.Time) map[time.Time]float64 {
+	totals := make(map[time.Time]float64)
+	for d := storage.Day.PeriodStart(start); d.Before(end); d = d.AddDate(0, 0, 1) {
+		totals[d] = 0
+	}
+
+	for _, r := range records {
+		y, m, d := r.LocalTime().Date()
+		totals[time.Date(y, m, d, 0, 0, 0, 0, time.UTC)] += r.Units()
+	}
+	return totals
+}
+
+// DistributeByWeekday calculates the distribution of daily unit totals for each weekday, starting on Monday.
+func DistributeByWeekday(totals map[time.Time]float64) []WeekdayDistribution {
+	var values [7][]float64
+	for d, units := range totals {
+		values[d.Weekday()] = append(values[d.Weekday()], units)
+	}
+
+	distributions := make([]WeekdayDistribution, 0, 7)
+	for i := 1; i <= 7; i++ {
+		weekday := time.Weekday(i % 7)
+		distributions = append(distributions, WeekdayDistribution{
+			Weekday:      weekday.String(),
+			Distribution: NewDistribution(values[weekday]),
+		})
+	}
+	return distributions
+}
+
+// DistributeByHour calculates the distribution of event units for each local hour of the day. Records without a time
+// of day are excluded.
+func DistributeByHour(records []storage.Record) []HourDistribution {
+	var values [24][]float64
+	for _, r := range records {
+		if !r.Timed() {
+			continue
+		}
+		hour := r.LocalTime().Hour()
+		values[hour] = append(values[hour], r.Units())
+	}
+
+	distributions := make([]HourDistribution, 0, 24)
+	for hour, v := range values {
+		distributions = append(distributions, HourDistribution{
+			Hour:         hour,
+			Distribution: NewDistribution(v),
+		})
+	}
+	return distributions
+}
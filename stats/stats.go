@@ -0,0 +1,190 @@
+package stats
+
+import (
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/storage"
+)
+
+// Period is the units consumed within an aggregation period.
+type Period struct {
+	Start time.Time `json:"start"`
+	Units float64   `json:"units"`
+}
+
+// Streak is a run of consecutive dry days. Start and End are the first and last dry days of the streak.
+type Streak struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Days  int       `json:"days"`
+}
+
+// Policy describes how days with no logged calendar entries are treated.
+type Policy string
+
+const (
+	// AssumeDry treats days with no logged entries as dry.
+	AssumeDry Policy = "dry"
+	// Unknown treats days with no logged entries as unknown, i.e. neither dry nor drinking days.
+	Unknown Policy = "unknown"
+)
+
+// String gets the policy name.
+func (p Policy) String() string {
+	return string(p)
+}
+
+// IsValid determines if the policy is supported.
+func (p Policy) IsValid() bool {
+	switch p {
+	case AssumeDry, Unknown:
+		return true
+	default:
+		return false
+	}
+}
+
+// Summary is a set of summary metrics for a time range.
+type Summary struct {
+	TotalUnits                   float64 `json:"total_units"`
+	MeanPerWeek                  float64 `json:"mean_per_week"`
+	MeanPerDrinkingDay           float64 `json:"mean_per_drinking_day"`
+	Days                         int     `json:"days"`
+	DrinkingDays                 int     `json:"drinking_days"`
+	DryDays                      int     `json:"dry_days"`
+	UnknownDays                  int     `json:"unknown_days"`
+	DryDaysPercentage            float64 `json:"dry_days_percentage"`
+	HeaviestDay                  *Period `json:"heaviest_day,omitempty"`
+	HeaviestWeek                 *Period `json:"heaviest_week,omitempty"`
+	HeaviestMonth                *Period `json:"heaviest_month,omitempty"`
+	WeeksOverGuideline           int     `json:"weeks_over_guideline"`
+	WeeksOverGuidelinePercentage float64 `json:"weeks_over_guideline_percentage"`
+	LongestDryStreak             *Streak `json:"longest_dry_streak,omitempty"`
+}
+
+// Summarise calculates summary metrics from day, week and month aggregated plots. Weeks with units exceeding
+// weeklyLimit are counted as over the guideline. If the day aggregated entry counts are provided, days with no logged
+// entries are counted as unknown rather than dry. Days with imputed entries but no units, i.e. unknown entries which
+// were excluded, are always counted as unknown, as they were drinking days of an unknown amount.
+func Summarise(days, entries, imputed, weeks, months []storage.Plot, weeklyLimit float64) Summary {
+	s := Summary{
+		Days:          len(days),
+		HeaviestDay:   heaviest(days),
+		HeaviestWeek:  heaviest(weeks),
+		HeaviestMonth: heaviest(months),
+	}
+
+	logged := daysWithEntries(entries)
+	excluded := daysWithEntries(imputed)
+	for _, d := range days {
+		s.TotalUnits += d.Y
+		switch {
+		case d.Y > 0:
+			s.DrinkingDays++
+		case excluded[d.X], logged != nil && !logged[d.X]:
+			s.UnknownDays++
+		default:
+			s.DryDays++
+		}
+	}
+
+	if s.Days > 0 {
+		s.MeanPerWeek = s.TotalUnits / (float64(s.Days) / 7)
+		s.DryDaysPercentage = percentage(s.DryDays, s.Days)
+	}
+	if s.DrinkingDays > 0 {
+		s.MeanPerDrinkingDay = s.TotalUnits / float64(s.DrinkingDays)
+	}
+
+	for _, w := range weeks {
+		if w.Y > weeklyLimit {
+			s.WeeksOverGuideline++
+		}
+	}
+	if len(weeks) > 0 {
+		s.WeeksOverGuidelinePercentage = percentage(s.WeeksOverGuideline, len(weeks))
+	}
+
+	if streaks := DryStreaks(days, entries, imputed, 1); len(streaks) > 0 {
+		longest := Longest(streaks)
+		s.LongestDryStreak = &longest
+	}
+
+	return s
+}
+
+// DryStreaks returns every streak of consecutive dry days of at least minDays from a set of day aggregated plots. If
+// the day aggregated entry counts are provided, days with no logged entries are treated as unknown and end a streak.
+// Days with imputed entries, given the day aggregated imputed entry counts, always end a streak.
+func DryStreaks(days, entries, imputed []storage.Plot, minDays int) []Streak {
+	logged := daysWithEntries(entries)
+	excluded := daysWithEntries(imputed)
+
+	var streaks []Streak
+	var current *Streak
+	for _, d := range days {
+		if d.Y > 0 || excluded[d.X] || (logged != nil && !logged[d.X]) {
+			if current != nil && current.Days >= minDays {
+				streaks = append(streaks, *current)
+			}
+			current = nil
+			continue
+		}
+
+		t := time.UnixMilli(d.X).UTC()
+		if current == nil {
+			current = &Streak{Start: t}
+		}
+		current.End = t
+		current.Days++
+	}
+
+	if current != nil && current.Days >= minDays {
+		streaks = append(streaks, *current)
+	}
+	return streaks
+}
+
+// Longest returns the longest streak. The earliest streak is returned if several are equally long.
+func Longest(streaks []Streak) Streak {
+	var longest Streak
+	for _, s := range streaks {
+		if s.Days > longest.Days {
+			longest = s
+		}
+	}
+	return longest
+}
+
+// daysWithEntries determines which days have entries from a set of day aggregated entry counts, keyed by the plot
+// timestamp. Returns nil if no entry counts are provided.
+func daysWithEntries(entries []storage.Plot) map[int64]bool {
+	if entries == nil {
+		return nil
+	}
+
+	logged := make(map[int64]bool, len(entries))
+	for _, e := range entries {
+		logged[e.X] = e.Y > 0
+	}
+	return logged
+}
+
+// heaviest returns the period with the most units, or nil if there were no units consumed in any period.
+func heaviest(plots []storage.Plot) *Period {
+	var p *Period
+	for _, plot := range plots {
+		if plot.Y > 0 && (p == nil || plot.Y > p.Units) {
+			p = &Period{
+				Start: time.UnixMilli(plot.X).UTC(),
+				Units: plot.Y,
+			}
+		}
+	}
+	return p
+}
+
+// percentage calculates n as a percentage of total.
+func percentage(n, total int) float64 {
+	return float64(n) / float64(total) * 100
+}
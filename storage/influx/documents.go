@@ -0,0 +1,158 @@
+package influx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/jemgunay/canlendar-graph/storage"
+)
+
+const (
+	documentMeasurement  = "documents"
+	documentKindTag      = "kind"
+	documentIDTag        = "id"
+	documentValueField   = "value"
+	documentCreatedField = "created"
+)
+
+// document is a JSON encoded value of a single user, such as a goal, which is shared by every instance. Every write of
+// a document is stored as a new point and only the latest point of each document is read, such that writes replace
+// the document. A document with an empty value has been deleted.
+type document struct {
+	id      string
+	created time.Time
+	value   string
+}
+
+// writeDocument writes a document of the kind for the user. Users are identified by ID, which is empty in single-user
+// mode, as is the ID of a kind of which each user only has a single document.
+func (r Requester) writeDocument(ctx context.Context, kind, user string, doc document) error {
+	log.Printf("storing %s document to influx", kind)
+
+	tags := map[string]string{documentKindTag: kind}
+	if user != "" {
+		tags[storage.UserTag] = user
+	}
+	if doc.id != "" {
+		tags[documentIDTag] = doc.id
+	}
+
+	point := influxdb2.NewPoint(
+		documentMeasurement,
+		tags,
+		map[string]interface{}{
+			documentValueField:   doc.value,
+			documentCreatedField: timeToNanos(doc.created),
+		},
+		time.Now().UTC(),
+	)
+
+	if err := r.writeClient.WritePoint(ctx, point); err != nil {
+		return fmt.Errorf("writing %s document to influx failed: %w", kind, err)
+	}
+	return nil
+}
+
+// readDocuments reads the latest version of every document of the kind for the user, in order of creation. Deleted
+// documents are excluded.
+func (r Requester) readDocuments(ctx context.Context, kind, user string) ([]document, error) {
+	log.Printf("reading %s documents from influx", kind)
+	return r.queryDocuments(ctx, kind, user, "")
+}
+
+// readDocument reads the latest version of the document of the kind with the ID for the user. storage.ErrNoResults is
+// returned if the document does not exist or has been deleted.
+func (r Requester) readDocument(ctx context.Context, kind, user, id string) (document, error) {
+	log.Printf("reading %s document from influx: %s", kind, id)
+
+	docs, err := r.queryDocuments(ctx, kind, user, id)
+	if err != nil {
+		return document{}, err
+	}
+	if len(docs) == 0 {
+		return document{}, storage.ErrNoResults
+	}
+	return docs[0], nil
+}
+
+// queryDocuments queries the latest version of the documents of the kind for the user, restricted to a single document
+// if an ID is provided.
+func (r Requester) queryDocuments(ctx context.Context, kind, user, id string) ([]document, error) {
+	var idFilter string
+	if id != "" {
+		idFilter = `
+		|> filter(fn:(r) => r.` + documentIDTag + ` == ` + quote(id) + `)`
+	}
+
+	query := `from(bucket: "` + bucket + `")
+		|> range(start: 0, stop: now())
+		|> filter(fn:(r) => r._measurement == "` + documentMeasurement + `")
+		|> filter(fn:(r) => r.` + documentKindTag + ` == ` + quote(kind) + `)` + userFilter(storage.QuerySet{User: user}) +
+		idFilter + `
+		|> last()
+		|> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+		|> group()`
+
+	result, err := r.readClient.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query influx: %w", err)
+	}
+
+	var docs []document
+	for result.Next() {
+		if doc := parseDocument(result.Record().Values()); doc.value != "" {
+			docs = append(docs, doc)
+		}
+	}
+
+	if err := result.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse influx query response: %w", err)
+	}
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		return docs[i].created.Before(docs[j].created)
+	})
+	return docs, nil
+}
+
+// parseDocument parses a document from the values of a pivoted record.
+func parseDocument(values map[string]interface{}) document {
+	doc := document{
+		created: nanosToTime(values[documentCreatedField]),
+	}
+	doc.id, _ = values[documentIDTag].(string)
+	doc.value, _ = values[documentValueField].(string)
+	return doc
+}
+
+// newDocumentID generates a random document ID.
+func newDocumentID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate document ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// timeToNanos converts a time to unix nanoseconds. The zero time, which cannot be represented, is converted to zero.
+func timeToNanos(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// nanosToTime converts a unix nanosecond value to a UTC time. Values which are missing or zero are the zero time.
+func nanosToTime(value interface{}) time.Time {
+	nanos, _ := value.(int64)
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos).UTC()
+}
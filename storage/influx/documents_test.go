@@ -0,0 +1,63 @@
+package influx
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_parseDocument(t *testing.T) {
+	created := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		values   map[string]interface{}
+		expected document
+	}{
+		{
+			name: "document",
+			values: map[string]interface{}{
+				documentKindTag:      goalKind,
+				documentIDTag:        "goal",
+				documentValueField:   `{"id":"goal"}`,
+				documentCreatedField: created.UnixNano(),
+			},
+			expected: document{
+				id:      "goal",
+				created: created,
+				value:   `{"id":"goal"}`,
+			},
+		},
+		{
+			name: "deleted",
+			values: map[string]interface{}{
+				documentKindTag:      goalKind,
+				documentIDTag:        "goal",
+				documentValueField:   "",
+				documentCreatedField: created.UnixNano(),
+			},
+			expected: document{
+				id:      "goal",
+				created: created,
+			},
+		},
+		{
+			name: "single_document_kind",
+			values: map[string]interface{}{
+				documentKindTag:    "plan",
+				documentValueField: `{}`,
+			},
+			expected: document{
+				value: `{}`,
+			},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if doc := parseDocument(tt.values); !reflect.DeepEqual(doc, tt.expected) {
+				t.Fatalf("expected %+v, got %+v", tt.expected, doc)
+			}
+		})
+	}
+}
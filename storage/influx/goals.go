@@ -0,0 +1,134 @@
+package influx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/goal"
+	"github.com/jemgunay/canlendar-graph/tenant"
+)
+
+var _ goal.Storer = GoalStore{}
+
+// goalKind is the kind of the documents which goals are stored as.
+const goalKind = "goal"
+
+// GoalStore persists the goals of the user carried by the context to influx, such that they are shared by every
+// instance. Each goal is stored as a separate document, so concurrent changes to different goals do not conflict.
+type GoalStore struct {
+	requester Requester
+}
+
+// NewGoalStore initialises a GoalStore which stores goals with the requester.
+func NewGoalStore(requester Requester) GoalStore {
+	return GoalStore{
+		requester: requester,
+	}
+}
+
+// List returns every goal, in order of creation.
+func (s GoalStore) List(ctx context.Context) ([]goal.Goal, error) {
+	docs, err := s.requester.readDocuments(ctx, goalKind, userID(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read goals: %w", err)
+	}
+
+	goals := make([]goal.Goal, 0, len(docs))
+	for _, doc := range docs {
+		g := goal.Goal{}
+		if err := json.Unmarshal([]byte(doc.value), &g); err != nil {
+			return nil, fmt.Errorf("failed to decode goal %s: %w", doc.id, err)
+		}
+		goals = append(goals, g)
+	}
+	return goals, nil
+}
+
+// Get returns the goal with the provided ID.
+func (s GoalStore) Get(ctx context.Context, id string) (goal.Goal, error) {
+	goals, err := s.List(ctx)
+	if err != nil {
+		return goal.Goal{}, err
+	}
+
+	for _, g := range goals {
+		if g.ID == id {
+			return g, nil
+		}
+	}
+	return goal.Goal{}, goal.ErrNotFound
+}
+
+// Create assigns a new ID to the goal and persists it.
+func (s GoalStore) Create(ctx context.Context, g goal.Goal) (goal.Goal, error) {
+	id, err := newDocumentID()
+	if err != nil {
+		return goal.Goal{}, err
+	}
+	g.ID = id
+
+	if err := s.write(ctx, g, time.Now().UTC()); err != nil {
+		return goal.Goal{}, err
+	}
+	return g, nil
+}
+
+// Update replaces the goal with the same ID.
+func (s GoalStore) Update(ctx context.Context, g goal.Goal) error {
+	doc, err := s.find(ctx, g.ID)
+	if err != nil {
+		return err
+	}
+	return s.write(ctx, g, doc.created)
+}
+
+// Delete removes the goal with the provided ID.
+func (s GoalStore) Delete(ctx context.Context, id string) error {
+	doc, err := s.find(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	deleted := document{id: id, created: doc.created}
+	if err := s.requester.writeDocument(ctx, goalKind, userID(ctx), deleted); err != nil {
+		return fmt.Errorf("failed to delete goal: %w", err)
+	}
+	return nil
+}
+
+// find returns the document of the goal with the provided ID.
+func (s GoalStore) find(ctx context.Context, id string) (document, error) {
+	docs, err := s.requester.readDocuments(ctx, goalKind, userID(ctx))
+	if err != nil {
+		return document{}, fmt.Errorf("failed to read goals: %w", err)
+	}
+
+	for _, doc := range docs {
+		if doc.id == id {
+			return doc, nil
+		}
+	}
+	return document{}, goal.ErrNotFound
+}
+
+// write writes the goal as a document created at the provided time.
+func (s GoalStore) write(ctx context.Context, g goal.Goal, created time.Time) error {
+	value, err := json.Marshal(g)
+	if err != nil {
+		return fmt.Errorf("failed to encode goal: %w", err)
+	}
+
+	doc := document{id: g.ID, created: created, value: string(value)}
+	if err := s.requester.writeDocument(ctx, goalKind, userID(ctx), doc); err != nil {
+		return fmt.Errorf("failed to write goal: %w", err)
+	}
+	return nil
+}
+
+// userID returns the ID of the user carried by the context, which is empty in single-user mode.
+func userID(ctx context.Context) string {
+	user, _ := tenant.FromContext(ctx)
+	return user.ID
+}
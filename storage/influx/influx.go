@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
@@ -24,16 +25,20 @@ const (
 
 // Requester is used to write to and query influx.
 type Requester struct {
-	writeClient influxdbapi.WriteAPIBlocking
-	readClient  influxdbapi.QueryAPI
+	writeClient  influxdbapi.WriteAPIBlocking
+	readClient   influxdbapi.QueryAPI
+	deleteClient influxdbapi.DeleteAPI
+	org          string
 }
 
 // New returns an initialised influx requester.
 func New(conf config.Influx) Requester {
 	client := influxdb2.NewClient(conf.Host, conf.Token)
 	return Requester{
-		writeClient: client.WriteAPIBlocking(conf.Org, bucket),
-		readClient:  client.QueryAPI(conf.Org),
+		writeClient:  client.WriteAPIBlocking(conf.Org, bucket),
+		readClient:   client.QueryAPI(conf.Org),
+		deleteClient: client.DeleteAPI(),
+		org:          conf.Org,
 	}
 }
 
@@ -69,7 +74,7 @@ func (r Requester) Query(ctx context.Context, options ...storage.QueryOption) ([
 
 	// if no start time is provided, default it to the oldest record's timestamp
 	if queryOpts.StartTime.IsZero() {
-		queryOpts.StartTime, err = r.ReadFirstTimestamp(ctx)
+		queryOpts.StartTime, err = r.ReadFirstTimestamp(ctx, storage.WithUser(queryOpts.User))
 		if err != nil {
 			if err != storage.ErrNoResults {
 				return nil, storage.ErrNoResults
@@ -89,8 +94,10 @@ func (r Requester) Query(ctx context.Context, options ...storage.QueryOption) ([
 	  	|> filter(fn:(r) =>
 	    	r._measurement == "` + measurement + `" and
 			r._field == "units"
-	  	)
-		|> aggregateWindow(every: ` + aggregate.unit + `, fn: sum, createEmpty: true, offset: ` + aggregate.offset + `)`
+	  	)` + userFilter(queryOpts) + tagFilter(storage.RunTag, queryOpts.Run) +
+		tagFilter(storage.EventTag, queryOpts.Event) + imputedFilter(queryOpts) + `
+		|> group()
+		|> aggregateWindow(every: ` + aggregate.unit + `, fn: ` + queryOpts.AggregateFunc.String() + `, createEmpty: true, offset: ` + aggregate.offset + `)`
 
 	result, err := r.readClient.Query(ctx, query)
 	if err != nil {
@@ -105,6 +112,9 @@ func (r Requester) Query(ctx context.Context, options ...storage.QueryOption) ([
 		switch tVal := val.(type) {
 		case float64:
 			units = tVal
+		case int64:
+			// count aggregations are integers
+			units = float64(tVal)
 		case nil:
 			units = 0
 		default:
@@ -144,17 +154,119 @@ func (r Requester) Query(ctx context.Context, options ...storage.QueryOption) ([
 	return records, nil
 }
 
+// QueryRecords queries Influx for the raw records within the time range of the provided options. Record values are
+// pivoted into fields, except for string values which are read as tags. Returns storage.ErrNoResults if no records are
+// available for the given time range.
+func (r Requester) QueryRecords(ctx context.Context, options ...storage.QueryOption) ([]storage.Record, error) {
+	log.Printf("executing influx record query")
+
+	queryOpts, err := storage.NewRecordQuery(options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse query: %w", err)
+	}
+
+	// build flux query
+	query := `from(bucket: "` + bucket + `")
+	  	|> range(start: ` + queryOpts.FormatStartTime() + `, stop: ` + queryOpts.FormatEndTime() + `)
+	  	|> filter(fn:(r) => r._measurement == "` + measurement + `")` + userFilter(queryOpts) +
+		tagFilter(storage.RunTag, queryOpts.Run) + tagFilter(storage.EventTag, queryOpts.Event) + imputedFilter(queryOpts) + `
+		|> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+		|> group()
+		|> sort(columns: ["_time"])`
+
+	result, err := r.readClient.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query influx: %w", err)
+	}
+
+	var records []storage.Record
+	for result.Next() {
+		record := storage.Record{
+			Time:   result.Record().Time(),
+			Tags:   make(map[string]string),
+			Fields: make(map[string]interface{}),
+		}
+
+		for key, val := range result.Record().Values() {
+			// skip influx metadata columns
+			if strings.HasPrefix(key, "_") || key == "result" || key == "table" || val == nil {
+				continue
+			}
+
+			if tag, ok := val.(string); ok {
+				record.Tags[key] = tag
+				continue
+			}
+			record.Fields[key] = val
+		}
+
+		records = append(records, record)
+	}
+
+	if err := result.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse influx query response: %w", err)
+	}
+
+	if len(records) == 0 {
+		return nil, storage.ErrNoResults
+	}
+
+	return records, nil
+}
+
+// userFilter builds a flux filter segment which restricts a query to the records of a single user. Records written in
+// single-user mode have no user tag and are matched by an empty user.
+func userFilter(queryOpts storage.QuerySet) string {
+	if queryOpts.User == "" {
+		return `
+		|> filter(fn:(r) => not exists r.` + storage.UserTag + `)`
+	}
+	return `
+		|> filter(fn:(r) => r.` + storage.UserTag + ` == ` + quote(queryOpts.User) + `)`
+}
+
+// tagFilter builds a flux filter segment which restricts a query to the records with a tag value, such as those
+// written by a single collect run. No filter is applied for an empty value.
+func tagFilter(key, value string) string {
+	if value == "" {
+		return ""
+	}
+	return `
+		|> filter(fn:(r) => r.` + key + ` == ` + quote(value) + `)`
+}
+
+// quote quotes a flux string literal.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`).Replace(s) + `"`
+}
+
+// imputedFilter builds a flux filter segment which restricts a query to imputed or observed records. Records written
+// before imputation was introduced have no imputed tag and are treated as observed.
+func imputedFilter(queryOpts storage.QuerySet) string {
+	switch {
+	case queryOpts.Imputed == nil:
+		return ""
+	case *queryOpts.Imputed:
+		return `
+		|> filter(fn:(r) => r.` + storage.ImputedTag + ` == "true")`
+	default:
+		return `
+		|> filter(fn:(r) => not exists r.` + storage.ImputedTag + ` or r.` + storage.ImputedTag + ` != "true")`
+	}
+}
+
 // ReadLastTimestamp returns the timestamp for the record with the newest timestamp. storage.ErrNoResults is returned
 // if there are no records.
-func (r Requester) ReadLastTimestamp(ctx context.Context) (time.Time, error) {
+func (r Requester) ReadLastTimestamp(ctx context.Context, options ...storage.QueryOption) (time.Time, error) {
 	log.Printf("reading last record timestamp from influx")
 
+	queryOpts := storage.NewTimestampQuery(options...)
 	query := `from(bucket: "` + bucket + `")
   	|> range(start: 0, stop: now())
   	|> filter(fn:(r) =>
     	r._measurement == "` + measurement + `" and
 		r._field == "units"
-  	)
+  	)` + userFilter(queryOpts) + `
   	|> last()`
 
 	result, err := r.readClient.Query(ctx, query)
@@ -180,15 +292,16 @@ func (r Requester) ReadLastTimestamp(ctx context.Context) (time.Time, error) {
 
 // ReadFirstTimestamp returns the timestamp for the record with the oldest timestamp. storage.ErrNoResults is returned
 // if there are no records.
-func (r Requester) ReadFirstTimestamp(ctx context.Context) (time.Time, error) {
+func (r Requester) ReadFirstTimestamp(ctx context.Context, options ...storage.QueryOption) (time.Time, error) {
 	log.Printf("reading first record timestamp from influx")
 
+	queryOpts := storage.NewTimestampQuery(options...)
 	query := `from(bucket: "` + bucket + `")
   	|> range(start: 0, stop: now())
   	|> filter(fn:(r) =>
     	r._measurement == "` + measurement + `" and
 		r._field == "units"
-  	)
+  	)` + userFilter(queryOpts) + `
   	|> first()`
 
 	result, err := r.readClient.Query(ctx, query)
@@ -238,3 +351,42 @@ func (r Requester) Store(ctx context.Context, records ...storage.Record) error {
 
 	return nil
 }
+
+// Delete deletes the records within the time range of the provided options, restricted to a single run if provided.
+// The end time is exclusive. Influx delete predicates cannot match a missing tag, so the records of every user are
+// deleted if no user is provided, i.e. in single-user mode.
+func (r Requester) Delete(ctx context.Context, options ...storage.QueryOption) error {
+	log.Printf("deleting records from influx")
+
+	queryOpts, err := storage.NewRecordQuery(options...)
+	if err != nil {
+		return fmt.Errorf("failed to parse query: %w", err)
+	}
+
+	predicate := `_measurement="` + measurement + `"`
+	if queryOpts.User != "" {
+		predicate += ` AND ` + storage.UserTag + `=` + quotePredicate(queryOpts.User)
+	}
+	if queryOpts.Run != "" {
+		predicate += ` AND ` + storage.RunTag + `=` + quotePredicate(queryOpts.Run)
+	}
+	if queryOpts.Event != "" {
+		predicate += ` AND ` + storage.EventTag + `=` + quotePredicate(queryOpts.Event)
+	}
+
+	// influx cannot represent times before the unix epoch, and the delete range is inclusive of the stop time
+	start := queryOpts.StartTime
+	if epoch := time.Unix(0, 0).UTC(); start.Before(epoch) {
+		start = epoch
+	}
+	stop := queryOpts.EndTime.Add(-time.Nanosecond)
+	if err := r.deleteClient.DeleteWithName(ctx, r.org, bucket, start, stop, predicate); err != nil {
+		return fmt.Errorf("deleting records from influx failed: %w", err)
+	}
+	return nil
+}
+
+// quotePredicate quotes a delete predicate string literal.
+func quotePredicate(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
@@ -0,0 +1,49 @@
+package influx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jemgunay/canlendar-graph/collect"
+	"github.com/jemgunay/canlendar-graph/storage"
+)
+
+var _ collect.Store = (*Requester)(nil)
+
+// jobKind is the kind of the documents which collect jobs are stored as. Jobs span every user, so are stored without
+// a user.
+const jobKind = "collect_job"
+
+// StoreJob writes the status and progress of a collect job, replacing any previously stored version of the job.
+func (r Requester) StoreJob(ctx context.Context, job collect.Job) error {
+	value, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to encode collect job: %w", err)
+	}
+
+	doc := document{id: job.ID, created: job.StartedAt, value: string(value)}
+	if err := r.writeDocument(ctx, jobKind, "", doc); err != nil {
+		return fmt.Errorf("failed to write collect job: %w", err)
+	}
+	return nil
+}
+
+// ReadJob reads the latest stored version of the collect job with the provided ID. collect.ErrNotFound is returned if
+// no such job has been stored.
+func (r Requester) ReadJob(ctx context.Context, id string) (collect.Job, error) {
+	doc, err := r.readDocument(ctx, jobKind, "", id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNoResults) {
+			return collect.Job{}, collect.ErrNotFound
+		}
+		return collect.Job{}, fmt.Errorf("failed to read collect job: %w", err)
+	}
+
+	job := collect.Job{}
+	if err := json.Unmarshal([]byte(doc.value), &job); err != nil {
+		return collect.Job{}, fmt.Errorf("failed to decode collect job %s: %w", id, err)
+	}
+	return job, nil
+}
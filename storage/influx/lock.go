@@ -0,0 +1,139 @@
+package influx
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/jemgunay/canlendar-graph/storage"
+)
+
+var _ storage.Locker = (*Requester)(nil)
+
+const (
+	lockMeasurement = "locks"
+	lockNameTag     = "name"
+	lockOwnerField  = "owner"
+	lockExpiryField = "expiry"
+)
+
+// lockEvent is an attempt to acquire or release a lock. Releases have a zero expiry.
+type lockEvent struct {
+	time   time.Time
+	owner  string
+	expiry time.Time
+}
+
+// Lock acquires or refreshes the named lock. Influx has no atomic compare-and-set, so an acquisition attempt is written
+// as an event and the holder is then resolved by replaying the lock's recent events in order, i.e. the earliest attempt
+// wins when attempts overlap. storage.ErrLocked is returned if the lock is held by another owner.
+func (r Requester) Lock(ctx context.Context, name, owner string, ttl time.Duration) error {
+	log.Printf("acquiring influx lock: %s", name)
+
+	now := time.Now().UTC()
+	if err := r.writeLockEvent(ctx, name, owner, now.Add(ttl)); err != nil {
+		return err
+	}
+
+	// events older than twice the TTL can no longer affect the holder, assuming a consistent TTL
+	events, err := r.readLockEvents(ctx, name, now.Add(-2*ttl), now.Add(ttl))
+	if err != nil {
+		return err
+	}
+
+	if holder, ok := lockHolder(events, now); !ok || holder.owner != owner {
+		// withdraw the failed attempt such that it is not mistaken for the holder once older events fall out of range
+		if err := r.writeLockEvent(ctx, name, owner, time.Time{}); err != nil {
+			log.Printf("failed to withdraw lock attempt: %s", err)
+		}
+		return storage.ErrLocked
+	}
+	return nil
+}
+
+// Unlock releases the named lock if it is held by the owner.
+func (r Requester) Unlock(ctx context.Context, name, owner string) error {
+	log.Printf("releasing influx lock: %s", name)
+
+	return r.writeLockEvent(ctx, name, owner, time.Time{})
+}
+
+// writeLockEvent writes a lock event. A zero expiry releases the lock.
+func (r Requester) writeLockEvent(ctx context.Context, name, owner string, expiry time.Time) error {
+	var expiryNanos int64
+	if !expiry.IsZero() {
+		expiryNanos = expiry.UnixNano()
+	}
+
+	point := influxdb2.NewPoint(
+		lockMeasurement,
+		map[string]string{lockNameTag: name},
+		map[string]interface{}{
+			lockOwnerField:  owner,
+			lockExpiryField: expiryNanos,
+		},
+		time.Now().UTC(),
+	)
+
+	if err := r.writeClient.WritePoint(ctx, point); err != nil {
+		return fmt.Errorf("writing lock event to influx failed: %w", err)
+	}
+	return nil
+}
+
+// readLockEvents reads the named lock's events within the time range, in order of time.
+func (r Requester) readLockEvents(ctx context.Context, name string, start, stop time.Time) ([]lockEvent, error) {
+	query := `from(bucket: "` + bucket + `")
+		|> range(start: ` + start.Format(time.RFC3339Nano) + `, stop: ` + stop.Format(time.RFC3339Nano) + `)
+		|> filter(fn:(r) => r._measurement == "` + lockMeasurement + `" and r.` + lockNameTag + ` == ` + quote(name) + `)
+		|> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+		|> group()
+		|> sort(columns: ["_time"])`
+
+	result, err := r.readClient.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query influx: %w", err)
+	}
+
+	var events []lockEvent
+	for result.Next() {
+		values := result.Record().Values()
+		ev := lockEvent{
+			time: result.Record().Time(),
+		}
+		ev.owner, _ = values[lockOwnerField].(string)
+		if nanos, _ := values[lockExpiryField].(int64); nanos != 0 {
+			ev.expiry = time.Unix(0, nanos).UTC()
+		}
+		events = append(events, ev)
+	}
+
+	if err := result.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse influx query response: %w", err)
+	}
+	return events, nil
+}
+
+// lockHolder replays lock events in order of time to determine the current holder of a lock. An acquisition attempt
+// succeeds if the lock is free, expired or already held by the same owner (i.e. a refresh), and a release only applies
+// to the holder. Returns false if the lock is not held at the provided time.
+func lockHolder(events []lockEvent, now time.Time) (lockEvent, bool) {
+	var holder lockEvent
+	for _, ev := range events {
+		switch {
+		case ev.expiry.IsZero():
+			if ev.owner == holder.owner {
+				holder = lockEvent{}
+			}
+		case holder.owner == "" || holder.owner == ev.owner || !ev.time.Before(holder.expiry):
+			holder = ev
+		}
+	}
+
+	if holder.owner == "" || !now.Before(holder.expiry) {
+		return lockEvent{}, false
+	}
+	return holder, true
+}
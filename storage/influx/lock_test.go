@@ -0,0 +1,78 @@
+package influx
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_lockHolder(t *testing.T) {
+	now := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	acquire := func(owner string, at time.Duration) lockEvent {
+		return lockEvent{time: now.Add(at), owner: owner, expiry: now.Add(at + time.Hour)}
+	}
+	release := func(owner string, at time.Duration) lockEvent {
+		return lockEvent{time: now.Add(at), owner: owner}
+	}
+
+	cases := []struct {
+		name   string
+		events []lockEvent
+		owner  string
+	}{
+		{
+			name: "no_events",
+		},
+		{
+			name:   "single_attempt",
+			events: []lockEvent{acquire("a", -time.Minute)},
+			owner:  "a",
+		},
+		{
+			name:   "earliest_attempt_wins",
+			events: []lockEvent{acquire("a", -2*time.Minute), acquire("b", -time.Minute)},
+			owner:  "a",
+		},
+		{
+			name:   "refresh",
+			events: []lockEvent{acquire("a", -90*time.Minute), acquire("a", -40*time.Minute)},
+			owner:  "a",
+		},
+		{
+			name:   "expired",
+			events: []lockEvent{acquire("a", -2*time.Hour)},
+		},
+		{
+			name:   "acquire_after_expiry",
+			events: []lockEvent{acquire("a", -2*time.Hour), acquire("b", -time.Minute)},
+			owner:  "b",
+		},
+		{
+			name:   "released",
+			events: []lockEvent{acquire("a", -2*time.Minute), release("a", -time.Minute)},
+		},
+		{
+			name:   "acquire_after_release",
+			events: []lockEvent{acquire("a", -3*time.Minute), release("a", -2*time.Minute), acquire("b", -time.Minute)},
+			owner:  "b",
+		},
+		{
+			name: "release_by_other_owner",
+			events: []lockEvent{
+				acquire("a", -3*time.Minute), acquire("b", -2*time.Minute), release("b", -time.Minute),
+			},
+			owner: "a",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			holder, ok := lockHolder(tt.events, now)
+			if ok != (tt.owner != "") {
+				t.Fatalf("expected held to be %t, got %t", tt.owner != "", ok)
+			}
+			if holder.owner != tt.owner {
+				t.Fatalf("expected %s, got %s", tt.owner, holder.owner)
+			}
+		})
+	}
+}
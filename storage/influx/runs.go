@@ -0,0 +1,182 @@
+package influx
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/jemgunay/canlendar-graph/storage"
+)
+
+var _ storage.RunStore = (*Requester)(nil)
+
+const (
+	runMeasurement         = "collect_runs"
+	runJobIDField          = "job_id"
+	runFinishedAtField     = "finished_at"
+	runRangeStartField     = "range_start"
+	runRangeEndField       = "range_end"
+	runEventsFetchedField  = "events_fetched"
+	runRecordsWrittenField = "records_written"
+	runFirstRecordField    = "first_record"
+	runLastRecordField     = "last_record"
+	runUndoneField         = "undone"
+	runSucceededField      = "succeeded"
+	runFailuresField       = "failures"
+	watermarkMeasurement   = "collect_watermarks"
+	watermarkField         = "watermark"
+	runFailuresSeparator   = "\n"
+)
+
+// StoreRun writes a collect run as a point at the time the run started, replacing any run stored for the same user and
+// start time. Times are stored as unix nanoseconds.
+func (r Requester) StoreRun(ctx context.Context, run storage.Run) error {
+	log.Printf("storing collect run to influx: %s", run.JobID)
+
+	tags := map[string]string{}
+	if run.User != "" {
+		tags[storage.UserTag] = run.User
+	}
+
+	point := influxdb2.NewPoint(
+		runMeasurement,
+		tags,
+		map[string]interface{}{
+			runJobIDField:          run.JobID,
+			runFinishedAtField:     timeToNanos(run.FinishedAt),
+			runRangeStartField:     timeToNanos(run.RangeStart),
+			runRangeEndField:       timeToNanos(run.RangeEnd),
+			runEventsFetchedField:  run.EventsFetched,
+			runRecordsWrittenField: run.RecordsWritten,
+			runFirstRecordField:    timeToNanos(run.FirstRecord),
+			runLastRecordField:     timeToNanos(run.LastRecord),
+			runUndoneField:         run.Undone,
+			runSucceededField:      run.Succeeded,
+			runFailuresField:       strings.Join(run.Failures, runFailuresSeparator),
+		},
+		run.StartedAt,
+	)
+
+	if err := r.writeClient.WritePoint(ctx, point); err != nil {
+		return fmt.Errorf("writing collect run to influx failed: %w", err)
+	}
+	return nil
+}
+
+// QueryRuns reads the collect runs of every user which started within the time range, in order of start time.
+// storage.ErrNoResults is returned if there are no runs.
+func (r Requester) QueryRuns(ctx context.Context, startTime, endTime time.Time) ([]storage.Run, error) {
+	log.Printf("executing influx collect run query")
+
+	query := `from(bucket: "` + bucket + `")
+		|> range(start: ` + startTime.UTC().Format(time.RFC3339Nano) + `, stop: ` + endTime.UTC().Format(time.RFC3339Nano) + `)
+		|> filter(fn:(r) => r._measurement == "` + runMeasurement + `")
+		|> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+		|> group()
+		|> sort(columns: ["_time"])`
+
+	result, err := r.readClient.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query influx: %w", err)
+	}
+
+	var runs []storage.Run
+	for result.Next() {
+		runs = append(runs, parseRun(result.Record().Time(), result.Record().Values()))
+	}
+
+	if err := result.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse influx query response: %w", err)
+	}
+
+	if len(runs) == 0 {
+		return nil, storage.ErrNoResults
+	}
+	return runs, nil
+}
+
+// parseRun parses a collect run from the values of a pivoted record.
+func parseRun(start time.Time, values map[string]interface{}) storage.Run {
+	run := storage.Run{
+		StartedAt:   start.UTC(),
+		FinishedAt:  nanosToTime(values[runFinishedAtField]),
+		RangeStart:  nanosToTime(values[runRangeStartField]),
+		RangeEnd:    nanosToTime(values[runRangeEndField]),
+		FirstRecord: nanosToTime(values[runFirstRecordField]),
+		LastRecord:  nanosToTime(values[runLastRecordField]),
+	}
+	run.JobID, _ = values[runJobIDField].(string)
+	run.User, _ = values[storage.UserTag].(string)
+	run.Succeeded, _ = values[runSucceededField].(bool)
+	run.Undone, _ = values[runUndoneField].(bool)
+	if count, ok := values[runEventsFetchedField].(int64); ok {
+		run.EventsFetched = int(count)
+	}
+	if count, ok := values[runRecordsWrittenField].(int64); ok {
+		run.RecordsWritten = int(count)
+	}
+	if failures, _ := values[runFailuresField].(string); failures != "" {
+		run.Failures = strings.Split(failures, runFailuresSeparator)
+	}
+	return run
+}
+
+// StoreWatermark writes the user's collection watermark.
+func (r Requester) StoreWatermark(ctx context.Context, user string, watermark time.Time) error {
+	log.Printf("storing collection watermark to influx: %s", watermark.Format(time.RFC3339))
+
+	tags := map[string]string{}
+	if user != "" {
+		tags[storage.UserTag] = user
+	}
+
+	point := influxdb2.NewPoint(
+		watermarkMeasurement,
+		tags,
+		map[string]interface{}{
+			watermarkField: timeToNanos(watermark),
+		},
+		time.Now().UTC(),
+	)
+
+	if err := r.writeClient.WritePoint(ctx, point); err != nil {
+		return fmt.Errorf("writing collection watermark to influx failed: %w", err)
+	}
+	return nil
+}
+
+// ReadWatermark returns the user's most recently written collection watermark. storage.ErrNoResults is returned if the
+// user has no watermark.
+func (r Requester) ReadWatermark(ctx context.Context, user string) (time.Time, error) {
+	log.Printf("reading collection watermark from influx")
+
+	query := `from(bucket: "` + bucket + `")
+  	|> range(start: 0, stop: now())
+  	|> filter(fn:(r) =>
+    	r._measurement == "` + watermarkMeasurement + `" and
+		r._field == "` + watermarkField + `"
+  	)` + userFilter(storage.QuerySet{User: user}) + `
+  	|> last()`
+
+	result, err := r.readClient.Query(ctx, query)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to query influx: %w", err)
+	}
+
+	var watermark time.Time
+	if result.Next() {
+		watermark = nanosToTime(result.Record().Value())
+	}
+
+	if err := result.Err(); err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse influx query response: %w", err)
+	}
+
+	if watermark.IsZero() {
+		return time.Time{}, storage.ErrNoResults
+	}
+	return watermark, nil
+}
@@ -0,0 +1,72 @@
+package influx
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/storage"
+)
+
+func Test_parseRun(t *testing.T) {
+	start := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		values   map[string]interface{}
+		expected storage.Run
+	}{
+		{
+			name: "succeeded",
+			values: map[string]interface{}{
+				storage.UserTag:        "alice",
+				runJobIDField:          "job",
+				runFinishedAtField:     start.Add(time.Minute).UnixNano(),
+				runRangeStartField:     int64(0),
+				runRangeEndField:       start.UnixNano(),
+				runEventsFetchedField:  int64(3),
+				runRecordsWrittenField: int64(2),
+				runFirstRecordField:    start.Add(-time.Hour).UnixNano(),
+				runLastRecordField:     start.Add(-time.Minute).UnixNano(),
+				runSucceededField:      true,
+				runUndoneField:         true,
+				runFailuresField:       "failed to read event: invalid units",
+			},
+			expected: storage.Run{
+				JobID:          "job",
+				User:           "alice",
+				StartedAt:      start,
+				FinishedAt:     start.Add(time.Minute),
+				RangeEnd:       start,
+				EventsFetched:  3,
+				RecordsWritten: 2,
+				FirstRecord:    start.Add(-time.Hour),
+				LastRecord:     start.Add(-time.Minute),
+				Succeeded:      true,
+				Undone:         true,
+				Failures:       []string{"failed to read event: invalid units"},
+			},
+		},
+		{
+			name: "failed_single_user",
+			values: map[string]interface{}{
+				runJobIDField:     "job",
+				runSucceededField: false,
+				runFailuresField:  "first\nsecond",
+			},
+			expected: storage.Run{
+				JobID:     "job",
+				StartedAt: start,
+				Failures:  []string{"first", "second"},
+			},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if run := parseRun(start, tt.values); !reflect.DeepEqual(run, tt.expected) {
+				t.Fatalf("expected %+v, got %+v", tt.expected, run)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package influx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/taper"
+)
+
+var _ taper.Storer = TaperStore{}
+
+// taperKind is the kind of the document which the tapering plan is stored as.
+const taperKind = "taper_plan"
+
+// TaperStore persists the current tapering plan of the user carried by the context to influx, such that it is shared
+// by every instance.
+type TaperStore struct {
+	requester Requester
+}
+
+// NewTaperStore initialises a TaperStore which stores plans with the requester.
+func NewTaperStore(requester Requester) TaperStore {
+	return TaperStore{
+		requester: requester,
+	}
+}
+
+// Get returns the current plan.
+func (s TaperStore) Get(ctx context.Context) (taper.Plan, error) {
+	doc, err := s.read(ctx)
+	if err != nil {
+		return taper.Plan{}, err
+	}
+
+	p := taper.Plan{}
+	if err := json.Unmarshal([]byte(doc.value), &p); err != nil {
+		return taper.Plan{}, fmt.Errorf("failed to decode tapering plan: %w", err)
+	}
+	return p, nil
+}
+
+// Save replaces the current plan.
+func (s TaperStore) Save(ctx context.Context, p taper.Plan) error {
+	value, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("failed to encode tapering plan: %w", err)
+	}
+
+	doc := document{created: time.Now().UTC(), value: string(value)}
+	if err := s.requester.writeDocument(ctx, taperKind, userID(ctx), doc); err != nil {
+		return fmt.Errorf("failed to write tapering plan: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the current plan.
+func (s TaperStore) Delete(ctx context.Context) error {
+	doc, err := s.read(ctx)
+	if err != nil {
+		return err
+	}
+
+	deleted := document{created: doc.created}
+	if err := s.requester.writeDocument(ctx, taperKind, userID(ctx), deleted); err != nil {
+		return fmt.Errorf("failed to remove tapering plan: %w", err)
+	}
+	return nil
+}
+
+// read reads the document of the current plan. taper.ErrNotFound is returned if there is no plan.
+func (s TaperStore) read(ctx context.Context) (document, error) {
+	docs, err := s.requester.readDocuments(ctx, taperKind, userID(ctx))
+	if err != nil {
+		return document{}, fmt.Errorf("failed to read tapering plan: %w", err)
+	}
+	if len(docs) == 0 {
+		return document{}, taper.ErrNotFound
+	}
+	return docs[len(docs)-1], nil
+}
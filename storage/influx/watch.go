@@ -0,0 +1,46 @@
+package influx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/watch"
+)
+
+var _ watch.Store = (*Requester)(nil)
+
+// watchKind is the kind of the document which the registered watch channels of every user are stored as.
+const watchKind = "watch_channels"
+
+// ReadChannels reads the registered watch channels. No channels are returned if none have been registered.
+func (r Requester) ReadChannels(ctx context.Context) ([]watch.Registration, error) {
+	docs, err := r.readDocuments(ctx, watchKind, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watch channels: %w", err)
+	}
+	if len(docs) == 0 {
+		return nil, nil
+	}
+
+	var regs []watch.Registration
+	if err := json.Unmarshal([]byte(docs[len(docs)-1].value), &regs); err != nil {
+		return nil, fmt.Errorf("failed to decode watch channels: %w", err)
+	}
+	return regs, nil
+}
+
+// WriteChannels replaces the registered watch channels.
+func (r Requester) WriteChannels(ctx context.Context, regs []watch.Registration) error {
+	value, err := json.Marshal(regs)
+	if err != nil {
+		return fmt.Errorf("failed to encode watch channels: %w", err)
+	}
+
+	doc := document{created: time.Now().UTC(), value: string(value)}
+	if err := r.writeDocument(ctx, watchKind, "", doc); err != nil {
+		return fmt.Errorf("failed to write watch channels: %w", err)
+	}
+	return nil
+}
@@ -36,6 +36,25 @@ func (m *MockStorer) EXPECT() *MockStorerMockRecorder {
 	return m.recorder
 }
 
+// Delete mocks base method.
+func (m *MockStorer) Delete(ctx context.Context, options ...storage.QueryOption) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx}
+	for _, a := range options {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Delete", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockStorerMockRecorder) Delete(ctx interface{}, options ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx}, options...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockStorer)(nil).Delete), varargs...)
+}
+
 // Query mocks base method.
 func (m *MockStorer) Query(ctx context.Context, options ...storage.QueryOption) ([]storage.Plot, error) {
 	m.ctrl.T.Helper()
@@ -56,34 +75,64 @@ func (mr *MockStorerMockRecorder) Query(ctx interface{}, options ...interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Query", reflect.TypeOf((*MockStorer)(nil).Query), varargs...)
 }
 
+// QueryRecords mocks base method.
+func (m *MockStorer) QueryRecords(ctx context.Context, options ...storage.QueryOption) ([]storage.Record, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx}
+	for _, a := range options {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "QueryRecords", varargs...)
+	ret0, _ := ret[0].([]storage.Record)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueryRecords indicates an expected call of QueryRecords.
+func (mr *MockStorerMockRecorder) QueryRecords(ctx interface{}, options ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx}, options...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryRecords", reflect.TypeOf((*MockStorer)(nil).QueryRecords), varargs...)
+}
+
 // ReadFirstTimestamp mocks base method.
-func (m *MockStorer) ReadFirstTimestamp(ctx context.Context) (time.Time, error) {
+func (m *MockStorer) ReadFirstTimestamp(ctx context.Context, options ...storage.QueryOption) (time.Time, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ReadFirstTimestamp", ctx)
+	varargs := []interface{}{ctx}
+	for _, a := range options {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ReadFirstTimestamp", varargs...)
 	ret0, _ := ret[0].(time.Time)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ReadFirstTimestamp indicates an expected call of ReadFirstTimestamp.
-func (mr *MockStorerMockRecorder) ReadFirstTimestamp(ctx interface{}) *gomock.Call {
+func (mr *MockStorerMockRecorder) ReadFirstTimestamp(ctx interface{}, options ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadFirstTimestamp", reflect.TypeOf((*MockStorer)(nil).ReadFirstTimestamp), ctx)
+	varargs := append([]interface{}{ctx}, options...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadFirstTimestamp", reflect.TypeOf((*MockStorer)(nil).ReadFirstTimestamp), varargs...)
 }
 
 // ReadLastTimestamp mocks base method.
-func (m *MockStorer) ReadLastTimestamp(ctx context.Context) (time.Time, error) {
+func (m *MockStorer) ReadLastTimestamp(ctx context.Context, options ...storage.QueryOption) (time.Time, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ReadLastTimestamp", ctx)
+	varargs := []interface{}{ctx}
+	for _, a := range options {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ReadLastTimestamp", varargs...)
 	ret0, _ := ret[0].(time.Time)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ReadLastTimestamp indicates an expected call of ReadLastTimestamp.
-func (mr *MockStorerMockRecorder) ReadLastTimestamp(ctx interface{}) *gomock.Call {
+func (mr *MockStorerMockRecorder) ReadLastTimestamp(ctx interface{}, options ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadLastTimestamp", reflect.TypeOf((*MockStorer)(nil).ReadLastTimestamp), ctx)
+	varargs := append([]interface{}{ctx}, options...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadLastTimestamp", reflect.TypeOf((*MockStorer)(nil).ReadLastTimestamp), varargs...)
 }
 
 // Store mocks base method.
@@ -104,3 +153,135 @@ func (mr *MockStorerMockRecorder) Store(ctx interface{}, records ...interface{})
 	varargs := append([]interface{}{ctx}, records...)
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Store", reflect.TypeOf((*MockStorer)(nil).Store), varargs...)
 }
+
+// MockRunStore is a mock of RunStore interface.
+type MockRunStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockRunStoreMockRecorder
+}
+
+// MockRunStoreMockRecorder is the mock recorder for MockRunStore.
+type MockRunStoreMockRecorder struct {
+	mock *MockRunStore
+}
+
+// NewMockRunStore creates a new mock instance.
+func NewMockRunStore(ctrl *gomock.Controller) *MockRunStore {
+	mock := &MockRunStore{ctrl: ctrl}
+	mock.recorder = &MockRunStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRunStore) EXPECT() *MockRunStoreMockRecorder {
+	return m.recorder
+}
+
+// QueryRuns mocks base method.
+func (m *MockRunStore) QueryRuns(ctx context.Context, startTime, endTime time.Time) ([]storage.Run, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueryRuns", ctx, startTime, endTime)
+	ret0, _ := ret[0].([]storage.Run)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueryRuns indicates an expected call of QueryRuns.
+func (mr *MockRunStoreMockRecorder) QueryRuns(ctx, startTime, endTime interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryRuns", reflect.TypeOf((*MockRunStore)(nil).QueryRuns), ctx, startTime, endTime)
+}
+
+// ReadWatermark mocks base method.
+func (m *MockRunStore) ReadWatermark(ctx context.Context, user string) (time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadWatermark", ctx, user)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadWatermark indicates an expected call of ReadWatermark.
+func (mr *MockRunStoreMockRecorder) ReadWatermark(ctx, user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadWatermark", reflect.TypeOf((*MockRunStore)(nil).ReadWatermark), ctx, user)
+}
+
+// StoreRun mocks base method.
+func (m *MockRunStore) StoreRun(ctx context.Context, run storage.Run) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StoreRun", ctx, run)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StoreRun indicates an expected call of StoreRun.
+func (mr *MockRunStoreMockRecorder) StoreRun(ctx, run interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoreRun", reflect.TypeOf((*MockRunStore)(nil).StoreRun), ctx, run)
+}
+
+// StoreWatermark mocks base method.
+func (m *MockRunStore) StoreWatermark(ctx context.Context, user string, watermark time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StoreWatermark", ctx, user, watermark)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StoreWatermark indicates an expected call of StoreWatermark.
+func (mr *MockRunStoreMockRecorder) StoreWatermark(ctx, user, watermark interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StoreWatermark", reflect.TypeOf((*MockRunStore)(nil).StoreWatermark), ctx, user, watermark)
+}
+
+// MockLocker is a mock of Locker interface.
+type MockLocker struct {
+	ctrl     *gomock.Controller
+	recorder *MockLockerMockRecorder
+}
+
+// MockLockerMockRecorder is the mock recorder for MockLocker.
+type MockLockerMockRecorder struct {
+	mock *MockLocker
+}
+
+// NewMockLocker creates a new mock instance.
+func NewMockLocker(ctrl *gomock.Controller) *MockLocker {
+	mock := &MockLocker{ctrl: ctrl}
+	mock.recorder = &MockLockerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLocker) EXPECT() *MockLockerMockRecorder {
+	return m.recorder
+}
+
+// Lock mocks base method.
+func (m *MockLocker) Lock(ctx context.Context, name, owner string, ttl time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Lock", ctx, name, owner, ttl)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Lock indicates an expected call of Lock.
+func (mr *MockLockerMockRecorder) Lock(ctx, name, owner, ttl interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Lock", reflect.TypeOf((*MockLocker)(nil).Lock), ctx, name, owner, ttl)
+}
+
+// Unlock mocks base method.
+func (m *MockLocker) Unlock(ctx context.Context, name, owner string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unlock", ctx, name, owner)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unlock indicates an expected call of Unlock.
+func (mr *MockLockerMockRecorder) Unlock(ctx, name, owner interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unlock", reflect.TypeOf((*MockLocker)(nil).Unlock), ctx, name, owner)
+}
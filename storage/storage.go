@@ -15,29 +15,168 @@ type Record struct {
 	Fields map[string]interface{}
 }
 
-// Plot is a point on a graph.
+// Record tag keys.
+const (
+	// ImputedTag flags whether the record's units were imputed rather than observed.
+	ImputedTag = "imputed"
+	// UserTag is the ID of the user the record belongs to. Records written in single-user mode have no user tag.
+	UserTag = "user"
+	// RunTag is the ID of the collect run which wrote the record. Records written before runs were tracked have no run
+	// tag.
+	RunTag = "run"
+	// EventTag is the ID of the calendar event which the record was parsed from, such that events at the same time are
+	// stored as separate points. Records written before events were tagged have no event tag.
+	EventTag = "event"
+)
+
+// Record field keys.
+const (
+	// UnitsField is the number of units consumed.
+	UnitsField = "units"
+	// TimedField flags whether the record has a time of day, as opposed to only a date.
+	TimedField = "timed"
+	// UTCOffsetField is the offset in seconds of the record's local time zone from UTC.
+	UTCOffsetField = "utc_offset"
+)
+
+// LocalTime returns the record's time in its local time zone, given its UTC offset field. The time is returned
+// unchanged if the record has no UTC offset field.
+func (r Record) LocalTime() time.Time {
+	offset, ok := r.Fields[UTCOffsetField]
+	if !ok {
+		return r.Time
+	}
+
+	var seconds int
+	switch o := offset.(type) {
+	case int:
+		seconds = o
+	case int64:
+		seconds = int(o)
+	case float64:
+		seconds = int(o)
+	}
+	return r.Time.In(time.FixedZone("", seconds))
+}
+
+// Units returns the record's units field, or zero if it is not set.
+func (r Record) Units() float64 {
+	units, _ := r.Fields[UnitsField].(float64)
+	return units
+}
+
+// Timed determines if the record has a time of day.
+func (r Record) Timed() bool {
+	timed, _ := r.Fields[TimedField].(bool)
+	return timed
+}
+
+// SameValues determines if two records have the same units, time of day flag, UTC offset and imputed tag. Other tags,
+// such as the user and run, are ignored.
+func (r Record) SameValues(other Record) bool {
+	_, offset := r.LocalTime().Zone()
+	_, otherOffset := other.LocalTime().Zone()
+	return r.Units() == other.Units() && r.Timed() == other.Timed() && offset == otherOffset &&
+		r.Tags[ImputedTag] == other.Tags[ImputedTag]
+}
+
+// RecordKey identifies a record by its time in unix nanoseconds and the calendar event it was parsed from, as events at
+// the same time, such as all-day events on the same date, are stored as separate records. Records without an event tag
+// have an empty event.
+type RecordKey struct {
+	Time  int64
+	Event string
+}
+
+// Key returns the key of the record.
+func (r Record) Key() RecordKey {
+	return RecordKey{
+		Time:  r.Time.UnixNano(),
+		Event: r.Tags[EventTag],
+	}
+}
+
+// Plot is a point on a graph. Tier is the risk tier of an aggregated plot, if it has been classified.
 type Plot struct {
-	X int64   `json:"t"`
-	Y float64 `json:"y"`
+	X    int64   `json:"t"`
+	Y    float64 `json:"y"`
+	Tier string  `json:"tier,omitempty"`
 }
 
-// Storer stored records and queries for records from a data store. It also provides the means to fetch the timestamps
-// for the first and last records.
+// Storer stored records and queries for records from a data store, either aggregated into plots or as raw records. It
+// also provides the means to fetch the timestamps for the first and last records, and to delete the records within a
+// time range. Only the user option is applied when reading timestamps, and only the time range, user, run and event
+// options are applied when deleting.
 type Storer interface {
 	Store(ctx context.Context, records ...Record) error
+	Delete(ctx context.Context, options ...QueryOption) error
 	Query(ctx context.Context, options ...QueryOption) ([]Plot, error)
-	ReadLastTimestamp(ctx context.Context) (time.Time, error)
-	ReadFirstTimestamp(ctx context.Context) (time.Time, error)
+	QueryRecords(ctx context.Context, options ...QueryOption) ([]Record, error)
+	ReadLastTimestamp(ctx context.Context, options ...QueryOption) (time.Time, error)
+	ReadFirstTimestamp(ctx context.Context, options ...QueryOption) (time.Time, error)
 }
 
 // ErrNoResults indicates that there are no results for the executed query.
 var ErrNoResults = errors.New("no results found for query")
 
+// Run is the history of a collect job for a single user. The run succeeded if it collected every event within its
+// range, even if some events failed to be read. The records written by the run, which are tagged with its job ID, lie
+// between its first and last record times. A run which has been undone no longer has any records.
+type Run struct {
+	JobID          string    `json:"job_id"`
+	User           string    `json:"user,omitempty"`
+	StartedAt      time.Time `json:"started_at"`
+	FinishedAt     time.Time `json:"finished_at"`
+	RangeStart     time.Time `json:"range_start"`
+	RangeEnd       time.Time `json:"range_end"`
+	EventsFetched  int       `json:"events_fetched"`
+	RecordsWritten int       `json:"records_written"`
+	FirstRecord    time.Time `json:"first_record"`
+	LastRecord     time.Time `json:"last_record"`
+	Succeeded      bool      `json:"succeeded"`
+	Undone         bool      `json:"undone"`
+	Failures       []string  `json:"failures,omitempty"`
+}
+
+// RunStore persists the history of collect runs, and each user's collection watermark, i.e. the time up to which their
+// calendar has been collected. Storing a run with the same user and start time as a stored run replaces it. Users are
+// identified by ID, which is empty in single-user mode. ErrNoResults is returned if there are no runs within the time
+// range, or if the user has no watermark.
+type RunStore interface {
+	StoreRun(ctx context.Context, run Run) error
+	QueryRuns(ctx context.Context, startTime, endTime time.Time) ([]Run, error)
+	StoreWatermark(ctx context.Context, user string, watermark time.Time) error
+	ReadWatermark(ctx context.Context, user string) (time.Time, error)
+}
+
+// ErrLocked indicates that a lock is held by another owner.
+var ErrLocked = errors.New("lock is held by another owner")
+
+// Locker provides named locks held in a data store, such that they are shared across instances. A lock expires after
+// its TTL unless it is refreshed by calling Lock again with the same owner. ErrLocked is returned if the lock is held
+// by another owner.
+type Locker interface {
+	Lock(ctx context.Context, name, owner string, ttl time.Duration) error
+	Unlock(ctx context.Context, name, owner string) error
+}
+
 // QuerySet defines the parameters for a query.
 type QuerySet struct {
 	StartTime   time.Time
 	EndTime     time.Time
 	Aggregation Aggregation
+	// Imputed restricts the query to imputed (true) or observed (false) records. All records are queried if nil.
+	Imputed       *bool
+	AggregateFunc AggregateFunc
+	// User restricts the query to the records of a single user. Records written in single-user mode have no user tag
+	// and are queried with an empty user.
+	User string
+	// Run restricts the query to the records written by a single collect run. Records of every run are queried if
+	// empty.
+	Run string
+	// Event restricts the query to the records parsed from a single calendar event. Records of every event are queried
+	// if empty.
+	Event string
 }
 
 // FormatStartTime formats the start time as RFC3339.
@@ -52,21 +191,52 @@ func (q QuerySet) FormatEndTime() string {
 
 // NewQuery validates a set of query options and configures a QuerySet given the provided options.
 func NewQuery(opts ...QueryOption) (QuerySet, error) {
-	q := &QuerySet{}
+	q := newQuerySet(opts...)
+
+	// validate provided option values
+	switch {
+	case !q.Aggregation.IsValid():
+		return q, errors.New("unsupported aggregation")
+	case !q.AggregateFunc.IsValid():
+		return q, errors.New("unsupported aggregate function")
+	}
+	return q, validateTimeRange(q)
+}
+
+// NewRecordQuery validates a set of query options for a raw record query and configures a QuerySet given the provided
+// options. Aggregation options are ignored.
+func NewRecordQuery(opts ...QueryOption) (QuerySet, error) {
+	q := newQuerySet(opts...)
+	return q, validateTimeRange(q)
+}
+
+// NewTimestampQuery configures a QuerySet for reading the first or last record timestamp given the provided options.
+// Only the user option is applied.
+func NewTimestampQuery(opts ...QueryOption) QuerySet {
+	q := newQuerySet(opts...)
+	return QuerySet{User: q.User}
+}
+
+// newQuerySet configures a QuerySet given the provided options.
+func newQuerySet(opts ...QueryOption) QuerySet {
+	q := &QuerySet{
+		AggregateFunc: Sum,
+	}
 	for _, opt := range opts {
 		opt(q)
 	}
+	return *q
+}
 
-	// validate provided option values
+// validateTimeRange validates the query's time range.
+func validateTimeRange(q QuerySet) error {
 	switch {
-	case !q.Aggregation.IsValid():
-		return *q, errors.New("unsupported aggregation")
 	case q.EndTime.IsZero():
-		return *q, errors.New("end time must not be zero")
+		return errors.New("end time must not be zero")
 	case q.StartTime.After(q.EndTime):
-		return *q, errors.New("start time must not exceed end time")
+		return errors.New("start time must not exceed end time")
 	}
-	return *q, nil
+	return nil
 }
 
 // QueryOption is used to provide options to storage queries.
@@ -111,9 +281,103 @@ func (a Aggregation) IsValid() bool {
 	}
 }
 
+// PeriodStart returns the start of the aggregation period containing t, aligned with the windows that storage
+// aggregates over, i.e. UTC days, weeks starting on Monday, calendar months and calendar years.
+func (a Aggregation) PeriodStart(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	switch a {
+	case Week:
+		day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		// shift Sunday to the end of the week
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case Month:
+		return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+	case Year:
+		return time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// PeriodEnd returns the end (exclusive) of the aggregation period containing t.
+func (a Aggregation) PeriodEnd(t time.Time) time.Time {
+	start := a.PeriodStart(t)
+	switch a {
+	case Week:
+		return start.AddDate(0, 0, 7)
+	case Month:
+		return start.AddDate(0, 1, 0)
+	case Year:
+		return start.AddDate(1, 0, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
 // WithAggregation defines the query aggregation to use.
 func WithAggregation(aggregation Aggregation) QueryOption {
 	return func(set *QuerySet) {
 		set.Aggregation = aggregation
 	}
 }
+
+// WithUser restricts the query to the records of a single user.
+func WithUser(user string) QueryOption {
+	return func(set *QuerySet) {
+		set.User = user
+	}
+}
+
+// WithRun restricts the query to the records written by a single collect run.
+func WithRun(run string) QueryOption {
+	return func(set *QuerySet) {
+		set.Run = run
+	}
+}
+
+// WithEvent restricts the query to the records parsed from a single calendar event.
+func WithEvent(event string) QueryOption {
+	return func(set *QuerySet) {
+		set.Event = event
+	}
+}
+
+// WithImputed restricts the query to records which were either imputed or observed.
+func WithImputed(imputed bool) QueryOption {
+	return func(set *QuerySet) {
+		set.Imputed = &imputed
+	}
+}
+
+// AggregateFunc describes how the records within each aggregation window are combined.
+type AggregateFunc string
+
+const (
+	// Sum totals the units of the records in each window.
+	Sum AggregateFunc = "sum"
+	// Count counts the records in each window.
+	Count AggregateFunc = "count"
+)
+
+// String gets the aggregate function name.
+func (f AggregateFunc) String() string {
+	return string(f)
+}
+
+// IsValid determines if the aggregate function is supported.
+func (f AggregateFunc) IsValid() bool {
+	switch f {
+	case Sum, Count:
+		return true
+	default:
+		return false
+	}
+}
+
+// WithAggregateFunc defines the function used to combine records within each aggregation window. Defaults to Sum.
+func WithAggregateFunc(fn AggregateFunc) QueryOption {
+	return func(set *QuerySet) {
+		set.AggregateFunc = fn
+	}
+}
@@ -0,0 +1,75 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/jemgunay/canlendar-graph/config"
+	"github.com/jemgunay/canlendar-graph/internal/jsonfile"
+	"github.com/jemgunay/canlendar-graph/taper"
+	"github.com/jemgunay/canlendar-graph/tenant"
+)
+
+var _ taper.Storer = (*Store)(nil)
+
+// Store persists the current tapering plan to a JSON file per user.
+type Store struct {
+	file string
+	mu   sync.Mutex
+}
+
+// New initialises a Store from config.
+func New(conf config.Taper) *Store {
+	return &Store{
+		file: conf.File,
+	}
+}
+
+// Get returns the current plan.
+func (s *Store) Get(ctx context.Context) (taper.Plan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := taper.Plan{}
+	found, err := jsonfile.Read(s.path(ctx), &p)
+	if err != nil {
+		return taper.Plan{}, fmt.Errorf("failed to read tapering plan: %w", err)
+	}
+	if !found {
+		return taper.Plan{}, taper.ErrNotFound
+	}
+	return p, nil
+}
+
+// Save replaces the current plan.
+func (s *Store) Save(ctx context.Context, p taper.Plan) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := jsonfile.Write(s.path(ctx), p); err != nil {
+		return fmt.Errorf("failed to write tapering plan: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the current plan.
+func (s *Store) Delete(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found, err := jsonfile.Remove(s.path(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to remove tapering plan: %w", err)
+	}
+	if !found {
+		return taper.ErrNotFound
+	}
+	return nil
+}
+
+// path returns the file for the user carried by the context.
+func (s *Store) path(ctx context.Context) string {
+	user, _ := tenant.FromContext(ctx)
+	return tenant.Path(s.file, user)
+}
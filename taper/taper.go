@@ -0,0 +1,161 @@
+package taper
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/storage"
+)
+
+//go:generate mockgen -source=taper.go -destination=mocks/taper.go
+
+// ErrNotFound indicates that no tapering plan has been stored.
+var ErrNotFound = errors.New("tapering plan not found")
+
+// Step describes how the weekly target is reduced from the baseline to the target.
+type Step string
+
+const (
+	// Linear reduces the weekly target by the same number of units each week.
+	Linear Step = "linear"
+	// Percentage reduces the weekly target by the same percentage each week.
+	Percentage Step = "percentage"
+)
+
+// String gets the step name.
+func (s Step) String() string {
+	return string(s)
+}
+
+// IsValid determines if the step is supported.
+func (s Step) IsValid() bool {
+	switch s {
+	case Linear, Percentage:
+		return true
+	default:
+		return false
+	}
+}
+
+// Plan is a weekly reduction plan from a baseline down to a target over a number of weeks, in UK units. Start is the
+// start of the plan's first week. The final week's target is the plan's target.
+type Plan struct {
+	Start    time.Time `json:"start"`
+	Baseline float64   `json:"baseline"`
+	Target   float64   `json:"target"`
+	Weeks    int       `json:"weeks"`
+	Step     Step      `json:"step"`
+}
+
+// Validate determines if the plan is valid.
+func (p Plan) Validate() error {
+	if !p.Step.IsValid() {
+		return errors.New("unsupported step: " + p.Step.String())
+	}
+	if p.Weeks < 1 {
+		return errors.New("weeks must be at least 1")
+	}
+	if p.Target < 0 {
+		return errors.New("target must not be negative")
+	}
+	if p.Baseline <= p.Target {
+		return errors.New("baseline is already at or below the target")
+	}
+	if p.Step == Percentage && p.Target == 0 {
+		return errors.New("percentage steps cannot reach a target of zero")
+	}
+	return nil
+}
+
+// End returns the end of the plan's final week.
+func (p Plan) End() time.Time {
+	return p.Start.AddDate(0, 0, 7*p.Weeks)
+}
+
+// WeekTarget returns the target for the plan's nth week, starting at zero.
+func (p Plan) WeekTarget(n int) float64 {
+	progress := float64(n+1) / float64(p.Weeks)
+	if p.Step == Percentage {
+		return p.Baseline * math.Pow(p.Target/p.Baseline, progress)
+	}
+	return p.Baseline - (p.Baseline-p.Target)*progress
+}
+
+// Targets returns the target for each week of the plan as week aggregated plots.
+func (p Plan) Targets() []storage.Plot {
+	targets := make([]storage.Plot, 0, p.Weeks)
+	for n := 0; n < p.Weeks; n++ {
+		targets = append(targets, storage.Plot{
+			X: p.Start.AddDate(0, 0, 7*n).UnixMilli(),
+			Y: p.WeekTarget(n),
+		})
+	}
+	return targets
+}
+
+// Week is the adherence to the plan for a single week. Adherent is only set once the units consumed exceed the target
+// or the week has ended.
+type Week struct {
+	Start    time.Time `json:"start"`
+	Target   float64   `json:"target"`
+	Units    float64   `json:"units"`
+	Complete bool      `json:"complete"`
+	Adherent *bool     `json:"adherent,omitempty"`
+}
+
+// Adherence is the week by week adherence to a plan.
+type Adherence struct {
+	Weeks               []Week  `json:"weeks"`
+	AdherentWeeks       int     `json:"adherent_weeks"`
+	CompleteWeeks       int     `json:"complete_weeks"`
+	AdherencePercentage float64 `json:"adherence_percentage"`
+}
+
+// Adherence determines the adherence to the plan at now from a set of week aggregated plots (in UK units). Only weeks
+// which have started by now are included.
+func (p Plan) Adherence(weeks []storage.Plot, now time.Time) Adherence {
+	units := make(map[time.Time]float64, len(weeks))
+	for _, w := range weeks {
+		units[storage.Week.PeriodStart(time.UnixMilli(w.X))] += w.Y
+	}
+
+	a := Adherence{Weeks: []Week{}}
+	for n := 0; n < p.Weeks; n++ {
+		start := p.Start.AddDate(0, 0, 7*n)
+		if start.After(now) {
+			break
+		}
+
+		w := Week{
+			Start:    start,
+			Target:   p.WeekTarget(n),
+			Units:    units[start],
+			Complete: !now.Before(start.AddDate(0, 0, 7)),
+		}
+		if w.Complete || w.Units > w.Target {
+			adherent := w.Units <= w.Target
+			w.Adherent = &adherent
+		}
+		if w.Complete {
+			a.CompleteWeeks++
+			if *w.Adherent {
+				a.AdherentWeeks++
+			}
+		}
+		a.Weeks = append(a.Weeks, w)
+	}
+
+	if a.CompleteWeeks > 0 {
+		a.AdherencePercentage = float64(a.AdherentWeeks) / float64(a.CompleteWeeks) * 100
+	}
+	return a
+}
+
+// Storer persists the current tapering plan.
+type Storer interface {
+	Get(ctx context.Context) (Plan, error)
+	Save(ctx context.Context, p Plan) error
+	Delete(ctx context.Context) error
+}
@@ -0,0 +1,173 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/config"
+	"github.com/jemgunay/canlendar-graph/internal/jsonfile"
+)
+
+// ErrUnknownUser indicates that a user could not be resolved.
+var ErrUnknownUser = errors.New("unknown user")
+
+var idRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// User is a person whose calendar is collected and whose data is partitioned from other users. The single user in
+// single-user mode has an empty ID.
+type User struct {
+	ID string `json:"id"`
+	// CalendarID is the ID or name of the user's Google calendar.
+	CalendarID string `json:"calendar_id"`
+	// GuidelineProfile is the guideline profile used by default for the user's queries.
+	GuidelineProfile string `json:"guideline_profile,omitempty"`
+	// Timezone is the IANA time zone that the user's calendar events are read in, defaulting to each event's own.
+	Timezone string `json:"timezone,omitempty"`
+	// Logins are the basic auth usernames or OIDC emails which are allowed to read the user's data when read access is
+	// protected by a login.
+	Logins []string `json:"logins,omitempty"`
+}
+
+// Validate determines if the user is valid.
+func (u User) Validate() error {
+	switch {
+	case !idRegex.MatchString(u.ID):
+		return fmt.Errorf("user ID must be lowercase alphanumeric: %s", u.ID)
+	case u.CalendarID == "":
+		return fmt.Errorf("calendar ID must be set for user %s", u.ID)
+	}
+	if _, err := time.LoadLocation(u.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone for user %s: %w", u.ID, err)
+	}
+	for _, login := range u.Logins {
+		if strings.TrimSpace(login) == "" {
+			return fmt.Errorf("empty login for user %s", u.ID)
+		}
+	}
+	return nil
+}
+
+// Allows determines if the login is allowed to read the user's data. Logins are compared case-insensitively. Any login
+// is allowed to read the data of the single user in single-user mode.
+func (u User) Allows(login string) bool {
+	if u.ID == "" {
+		return true
+	}
+	for _, l := range u.Logins {
+		if strings.EqualFold(l, login) {
+			return true
+		}
+	}
+	return false
+}
+
+// Directory is the set of users served by a deployment.
+type Directory struct {
+	users []User
+}
+
+// Single initialises a Directory for single-user mode.
+func Single(user User) *Directory {
+	return &Directory{
+		users: []User{user},
+	}
+}
+
+// Load initialises a Directory from the users file. A single user is read from the calendar name if no users file is
+// configured.
+func Load(conf config.Tenants, calendarName string) (*Directory, error) {
+	if conf.File == "" {
+		return Single(User{CalendarID: calendarName}), nil
+	}
+
+	var users []User
+	found, err := jsonfile.Read(conf.File, &users)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("users file not found: %s", conf.File)
+	}
+	return New(users)
+}
+
+// New initialises a Directory for multiple users, validating each user.
+func New(users []User) (*Directory, error) {
+	if len(users) == 0 {
+		return nil, errors.New("at least one user must be provided")
+	}
+
+	seen := make(map[string]bool, len(users))
+	for _, u := range users {
+		if err := u.Validate(); err != nil {
+			return nil, err
+		}
+		if seen[u.ID] {
+			return nil, fmt.Errorf("duplicate user ID: %s", u.ID)
+		}
+		seen[u.ID] = true
+	}
+	return &Directory{users: users}, nil
+}
+
+// List returns every user.
+func (d *Directory) List() []User {
+	return d.users
+}
+
+// Get returns the user with the provided ID. The only user is returned if no ID is provided and there is exactly one
+// user.
+func (d *Directory) Get(id string) (User, error) {
+	if id == "" {
+		if len(d.users) == 1 {
+			return d.users[0], nil
+		}
+		return User{}, fmt.Errorf("%w: a user must be provided", ErrUnknownUser)
+	}
+
+	for _, u := range d.users {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+	return User{}, fmt.Errorf("%w: %s", ErrUnknownUser, id)
+}
+
+// ForLogin returns the users which the login is allowed to read.
+func (d *Directory) ForLogin(login string) []User {
+	var users []User
+	for _, u := range d.users {
+		if u.Allows(login) {
+			users = append(users, u)
+		}
+	}
+	return users
+}
+
+type userKey struct{}
+
+// NewContext returns a copy of the context which carries the user.
+func NewContext(ctx context.Context, user User) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// FromContext returns the user carried by the context, if any.
+func FromContext(ctx context.Context) (User, bool) {
+	user, ok := ctx.Value(userKey{}).(User)
+	return user, ok
+}
+
+// Path returns the per-user variant of a file path, i.e. "goals.json" becomes "goals.alice.json" for user "alice". The
+// path is unchanged for the single user in single-user mode.
+func Path(path string, user User) string {
+	if user.ID == "" {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + user.ID + ext
+}
@@ -0,0 +1,68 @@
+package units
+
+import (
+	"github.com/jemgunay/canlendar-graph/storage"
+)
+
+// Unit is a measure of alcohol intake. Intake is stored in UK units and converted to other units via the grams of
+// ethanol that each unit contains.
+type Unit string
+
+const (
+	// UK is a UK unit (8g of ethanol).
+	UK Unit = "uk_units"
+	// USDrinks is a US standard drink (14g of ethanol).
+	USDrinks Unit = "us_drinks"
+	// AUDrinks is an Australian/New Zealand standard drink (10g of ethanol).
+	AUDrinks Unit = "au_drinks"
+	// Grams is a gram of ethanol.
+	Grams Unit = "grams"
+)
+
+// maps units to the grams of ethanol they contain.
+var gramsLookup = map[Unit]float64{
+	UK:       8,
+	USDrinks: 14,
+	AUDrinks: 10,
+	Grams:    1,
+}
+
+// String gets the unit name.
+func (u Unit) String() string {
+	return string(u)
+}
+
+// IsValid determines if the unit is supported.
+func (u Unit) IsValid() bool {
+	_, ok := gramsLookup[u]
+	return ok
+}
+
+// Grams returns the grams of ethanol in one of the unit.
+func (u Unit) Grams() float64 {
+	return gramsLookup[u]
+}
+
+// FromUK converts a value in UK units to the unit.
+func (u Unit) FromUK(ukUnits float64) float64 {
+	return ukUnits * UK.Grams() / u.Grams()
+}
+
+// ToUK converts a value in the unit to UK units.
+func (u Unit) ToUK(value float64) float64 {
+	return value * u.Grams() / UK.Grams()
+}
+
+// FromUKPlots converts the Y values of a set of plots from UK units to the unit.
+func (u Unit) FromUKPlots(plots []storage.Plot) []storage.Plot {
+	if plots == nil {
+		return nil
+	}
+
+	converted := make([]storage.Plot, 0, len(plots))
+	for _, p := range plots {
+		p.Y = u.FromUK(p.Y)
+		converted = append(converted, p)
+	}
+	return converted
+}
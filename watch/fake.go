@@ -0,0 +1,119 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/jemgunay/canlendar-graph/calendar"
+)
+
+var _ calendar.Watcher = (*Fake)(nil)
+
+// Fake is a calendar.Watcher which stands in for the Google Calendar API, allowing notification handling to be tested
+// locally. Registered channels are held in memory, and notifications are sent to them in the same format as Google.
+type Fake struct {
+	client *http.Client
+
+	mu       sync.Mutex
+	channels map[string]calendar.Channel
+	messages map[string]int
+}
+
+// NewFake initialises a Fake which sends notifications with the provided client.
+func NewFake(client *http.Client) *Fake {
+	return &Fake{
+		client:   client,
+		channels: make(map[string]calendar.Channel),
+		messages: make(map[string]int),
+	}
+}
+
+// Watch registers the channel and sends it a sync notification, as Google does. Unlike Google, registration fails if
+// the sync notification is rejected, surfacing a misconfigured webhook early.
+func (f *Fake) Watch(ctx context.Context, channel calendar.Channel) (calendar.Channel, error) {
+	channel.ResourceID = "fake-" + channel.ID
+
+	f.mu.Lock()
+	f.channels[channel.ID] = channel
+	f.mu.Unlock()
+
+	if err := f.send(ctx, channel, StateSync); err != nil {
+		f.mu.Lock()
+		delete(f.channels, channel.ID)
+		f.mu.Unlock()
+		return calendar.Channel{}, err
+	}
+	return channel, nil
+}
+
+// StopWatch removes the channel, such that it no longer receives notifications.
+func (f *Fake) StopWatch(_ context.Context, channel calendar.Channel) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.channels[channel.ID]; !ok {
+		return fmt.Errorf("unknown channel: %s", channel.ID)
+	}
+	delete(f.channels, channel.ID)
+	return nil
+}
+
+// Channels returns the registered channels.
+func (f *Fake) Channels() []calendar.Channel {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	channels := make([]calendar.Channel, 0, len(f.channels))
+	for _, channel := range f.channels {
+		channels = append(channels, channel)
+	}
+	return channels
+}
+
+// Notify sends a change notification to every registered channel.
+func (f *Fake) Notify(ctx context.Context) error {
+	for _, channel := range f.Channels() {
+		if err := f.send(ctx, channel, StateExists); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send sends a notification to the channel, numbering the channel's messages sequentially.
+func (f *Fake) send(ctx context.Context, channel calendar.Channel, state string) error {
+	f.mu.Lock()
+	f.messages[channel.ID]++
+	message := f.messages[channel.ID]
+	f.mu.Unlock()
+
+	return Send(ctx, f.client, channel, state, message)
+}
+
+// Send sends a notification to the channel's address in the format sent by the Google Calendar API, i.e. an empty body
+// with the channel and resource described by headers.
+func Send(ctx context.Context, client *http.Client, channel calendar.Channel, state string, message int) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, channel.Address, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create notification request: %w", err)
+	}
+	req.Header.Set(ChannelIDHeader, channel.ID)
+	req.Header.Set(ChannelTokenHeader, channel.Token)
+	req.Header.Set(ResourceIDHeader, channel.ResourceID)
+	req.Header.Set(ResourceStateHeader, state)
+	req.Header.Set(MessageNumberHeader, strconv.Itoa(message))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("notification rejected with status %d", resp.StatusCode)
+	}
+	return nil
+}
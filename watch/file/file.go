@@ -0,0 +1,46 @@
+package file
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jemgunay/canlendar-graph/config"
+	"github.com/jemgunay/canlendar-graph/internal/jsonfile"
+	"github.com/jemgunay/canlendar-graph/watch"
+)
+
+var _ watch.Store = (*Store)(nil)
+
+// Store persists the registered watch channels to a JSON file. The file is only shared by instances with a common
+// disk.
+type Store struct {
+	file string
+	mu   sync.Mutex
+}
+
+// New initialises a Store from config.
+func New(conf config.Watch) *Store {
+	return &Store{
+		file: conf.File,
+	}
+}
+
+// ReadChannels reads the registered channels. A missing file is treated as no channels.
+func (s *Store) ReadChannels(_ context.Context) ([]watch.Registration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var regs []watch.Registration
+	if _, err := jsonfile.Read(s.file, &regs); err != nil {
+		return nil, err
+	}
+	return regs, nil
+}
+
+// WriteChannels replaces the file with the registered channels.
+func (s *Store) WriteChannels(_ context.Context, regs []watch.Registration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return jsonfile.Write(s.file, regs)
+}
@@ -0,0 +1,390 @@
+package watch
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jemgunay/canlendar-graph/calendar"
+	"github.com/jemgunay/canlendar-graph/collect"
+	"github.com/jemgunay/canlendar-graph/config"
+	"github.com/jemgunay/canlendar-graph/storage"
+	"github.com/jemgunay/canlendar-graph/tenant"
+)
+
+// ErrUnknownChannel indicates that a channel is not registered, e.g. because it has been replaced.
+var ErrUnknownChannel = errors.New("unknown watch channel")
+
+// Notification headers sent by the Google Calendar API.
+const (
+	ChannelIDHeader     = "X-Goog-Channel-ID"
+	ChannelTokenHeader  = "X-Goog-Channel-Token"
+	ResourceIDHeader    = "X-Goog-Resource-ID"
+	ResourceStateHeader = "X-Goog-Resource-State"
+	MessageNumberHeader = "X-Goog-Message-Number"
+)
+
+// Notification resource states.
+const (
+	// StateSync is sent once when a channel is registered, and does not indicate a change.
+	StateSync = "sync"
+	// StateExists is sent when a calendar's events change.
+	StateExists = "exists"
+)
+
+const (
+	// minSecretLength is the minimum length of the secret used to sign channel tokens.
+	minSecretLength = 32
+	// retryDelay is how long to wait before retrying a failed registration.
+	retryDelay = 5 * time.Minute
+)
+
+// Registration is a channel registered for a user's calendar.
+type Registration struct {
+	User       string           `json:"user,omitempty"`
+	CalendarID string           `json:"calendar_id"`
+	Channel    calendar.Channel `json:"channel"`
+}
+
+// Store persists the registered channels, such that they can be renewed and stopped by any instance.
+type Store interface {
+	ReadChannels(ctx context.Context) ([]Registration, error)
+	WriteChannels(ctx context.Context, regs []Registration) error
+}
+
+// Option configures a Manager.
+type Option func(m *Manager)
+
+// WithLocker defines the locker used to hold the collect lock while channels are registered, such that instances do
+// not register duplicate channels. The lock expires after the TTL. Channels are registered without a lock if no locker
+// is provided.
+func WithLocker(locker storage.Locker, ttl time.Duration) Option {
+	return func(m *Manager) {
+		m.locker = locker
+		m.lockTTL = ttl
+	}
+}
+
+// Manager registers a watch channel for every user's calendar and renews each channel before it expires. Channel tokens
+// are an HMAC of the channel ID, such that notifications can be verified by any instance sharing the secret. Registered
+// channels are persisted to the store so that they can be renewed and stopped after a restart, and by other instances.
+type Manager struct {
+	calendars   calendar.Provider
+	users       *tenant.Directory
+	store       Store
+	locker      storage.Locker
+	lockTTL     time.Duration
+	address     string
+	secret      []byte
+	ttl         time.Duration
+	renewBefore time.Duration
+	now         func() time.Time
+
+	mu sync.Mutex
+}
+
+// New initialises a Manager from config, persisting registered channels to the store. The secret must be at least 32
+// characters.
+func New(conf config.Watch, users *tenant.Directory, calendars calendar.Provider, store Store,
+	opts ...Option) (*Manager, error) {
+
+	switch {
+	case conf.Address == "":
+		return nil, errors.New("watch address must be configured")
+	case len(conf.Secret) < minSecretLength:
+		return nil, fmt.Errorf("watch secret must be at least %d characters", minSecretLength)
+	case conf.RenewBeforeHours <= 0 || conf.TTLHours <= conf.RenewBeforeHours:
+		return nil, errors.New("watch TTL must exceed the positive renewal period")
+	}
+
+	m := &Manager{
+		calendars:   calendars,
+		users:       users,
+		store:       store,
+		address:     conf.Address,
+		secret:      []byte(conf.Secret),
+		ttl:         time.Duration(conf.TTLHours) * time.Hour,
+		renewBefore: time.Duration(conf.RenewBeforeHours) * time.Hour,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m, nil
+}
+
+// Token returns the token of the channel with the provided ID.
+func (m *Manager) Token(channelID string) string {
+	h := hmac.New(sha256.New, m.secret)
+	h.Write([]byte(channelID))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Verify determines if a notification's token is valid for its channel.
+func (m *Manager) Verify(channelID, token string) bool {
+	if channelID == "" {
+		return false
+	}
+	return hmac.Equal([]byte(token), []byte(m.Token(channelID)))
+}
+
+// Owner returns the user whose calendar the channel is registered for. ErrUnknownChannel is returned if the channel is
+// not registered, or its user no longer exists.
+func (m *Manager) Owner(ctx context.Context, channelID string) (tenant.User, error) {
+	regs, err := m.read(ctx)
+	if err != nil {
+		return tenant.User{}, err
+	}
+	for _, reg := range regs {
+		if reg.Channel.ID != channelID {
+			continue
+		}
+		// the channel is stopped once the user is removed, but may still deliver notifications until then
+		user, err := m.users.Get(reg.User)
+		if err != nil {
+			return tenant.User{}, fmt.Errorf("%w: %s", ErrUnknownChannel, err)
+		}
+		return user, nil
+	}
+	return tenant.User{}, ErrUnknownChannel
+}
+
+// Channel returns the channel registered for the user's calendar, regardless of the address it notifies.
+// ErrUnknownChannel is returned if the user has no channel.
+func (m *Manager) Channel(ctx context.Context, user tenant.User) (calendar.Channel, error) {
+	regs, err := m.read(ctx)
+	if err != nil {
+		return calendar.Channel{}, err
+	}
+	for _, reg := range regs {
+		if reg.User == user.ID && reg.CalendarID == user.CalendarID {
+			return reg.Channel, nil
+		}
+	}
+	return calendar.Channel{}, ErrUnknownChannel
+}
+
+// Run registers channels and renews them before they expire until the context is cancelled. As the timer does not fire
+// while the service is scaled to zero, channels should also be renewed by calling Register whenever the service is
+// woken, e.g. by a collect job.
+func (m *Manager) Run(ctx context.Context) {
+	for {
+		renewAt, err := m.Register(ctx)
+		if err != nil {
+			log.Printf("failed to register watch channels: %s", err)
+			if retryAt := m.now().Add(retryDelay); renewAt.IsZero() || renewAt.After(retryAt) {
+				renewAt = retryAt
+			}
+		}
+		log.Printf("renewing watch channels at %s", renewAt.Format(time.RFC3339))
+
+		timer := time.NewTimer(renewAt.Sub(m.now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// Register registers a channel for every user's calendar which does not have one, or whose channel is due for renewal,
+// and stops the channels of calendars which are no longer watched. Returns the time at which the next channel is due
+// for renewal. Registration continues for the remaining users if it fails for one. The collect lock is held throughout,
+// and an error wrapping storage.ErrLocked is returned if it is held by another owner.
+func (m *Manager) Register(ctx context.Context) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer unlock()
+
+	existing, err := m.read(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var (
+		registered []Registration
+		renewAt    time.Time
+		failed     error
+	)
+	for _, user := range m.users.List() {
+		current, ok := find(existing, user, m.address)
+		if !ok || m.now().After(current.Channel.Expiry.Add(-m.renewBefore)) {
+			reg, err := m.watch(ctx, user)
+			if err != nil {
+				log.Printf("failed to register watch channel for calendar %s: %s", user.CalendarID, err)
+				failed = fmt.Errorf("failed to register channel for calendar %s: %w", user.CalendarID, err)
+				// keep the current channel until it expires
+				if !ok {
+					continue
+				}
+				reg = current
+			}
+			current = reg
+		}
+
+		registered = append(registered, current)
+		if renew := current.Channel.Expiry.Add(-m.renewBefore); renewAt.IsZero() || renew.Before(renewAt) {
+			renewAt = renew
+		}
+	}
+
+	// stop replaced channels, and the channels of calendars which are no longer watched
+	for _, reg := range existing {
+		if !contains(registered, reg.Channel.ID) {
+			m.stop(ctx, reg)
+		}
+	}
+
+	if err := m.write(ctx, registered); err != nil {
+		return renewAt, err
+	}
+	return renewAt, failed
+}
+
+// Stop stops every registered channel. The collect lock is held throughout, as with Register.
+func (m *Manager) Stop(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	existing, err := m.read(ctx)
+	if err != nil {
+		return err
+	}
+	for _, reg := range existing {
+		m.stop(ctx, reg)
+	}
+	return m.write(ctx, nil)
+}
+
+// lock acquires the collect lock, returning a function which releases it. No lock is acquired if no locker is
+// configured.
+func (m *Manager) lock(ctx context.Context) (func(), error) {
+	if m.locker == nil {
+		return func() {}, nil
+	}
+
+	owner, err := newID("watch-")
+	if err != nil {
+		return nil, err
+	}
+	if err := m.locker.Lock(ctx, collect.LockName, owner, m.lockTTL); err != nil {
+		return nil, fmt.Errorf("failed to acquire collect lock: %w", err)
+	}
+	return func() {
+		if err := m.locker.Unlock(ctx, collect.LockName, owner); err != nil {
+			log.Printf("failed to release collect lock: %s", err)
+		}
+	}, nil
+}
+
+// watch registers a new channel for the user's calendar.
+func (m *Manager) watch(ctx context.Context, user tenant.User) (Registration, error) {
+	watcher, err := m.calendars.Watcher(user.CalendarID)
+	if err != nil {
+		return Registration{}, err
+	}
+
+	id, err := newID("canlendar-")
+	if err != nil {
+		return Registration{}, err
+	}
+	channel, err := watcher.Watch(ctx, calendar.Channel{
+		ID:      id,
+		Address: m.address,
+		Token:   m.Token(id),
+		Expiry:  m.now().Add(m.ttl).UTC(),
+	})
+	if err != nil {
+		return Registration{}, err
+	}
+
+	log.Printf("registered watch channel %s for calendar %s until %s", channel.ID, user.CalendarID,
+		channel.Expiry.Format(time.RFC3339))
+	return Registration{
+		User:       user.ID,
+		CalendarID: user.CalendarID,
+		Channel:    channel,
+	}, nil
+}
+
+// stop stops a channel. Failures are logged rather than returned, as the channel will expire regardless.
+func (m *Manager) stop(ctx context.Context, reg Registration) {
+	watcher, err := m.calendars.Watcher(reg.CalendarID)
+	if err == nil {
+		err = watcher.StopWatch(ctx, reg.Channel)
+	}
+	if err != nil {
+		log.Printf("failed to stop watch channel %s: %s", reg.Channel.ID, err)
+		return
+	}
+	log.Printf("stopped watch channel %s for calendar %s", reg.Channel.ID, reg.CalendarID)
+}
+
+// read reads the registered channels from the store.
+func (m *Manager) read(ctx context.Context) ([]Registration, error) {
+	regs, err := m.store.ReadChannels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watch channels: %w", err)
+	}
+	return regs, nil
+}
+
+// write replaces the registered channels in the store.
+func (m *Manager) write(ctx context.Context, regs []Registration) error {
+	if regs == nil {
+		regs = []Registration{}
+	}
+	if err := m.store.WriteChannels(ctx, regs); err != nil {
+		return fmt.Errorf("failed to write watch channels: %w", err)
+	}
+	return nil
+}
+
+// find returns the registration for the user's current calendar which notifies the address.
+func find(regs []Registration, user tenant.User, address string) (Registration, bool) {
+	for _, reg := range regs {
+		if reg.User == user.ID && reg.CalendarID == user.CalendarID && reg.Channel.Address == address {
+			return reg, true
+		}
+	}
+	return Registration{}, false
+}
+
+// contains determines if a registration exists for the channel ID.
+func contains(regs []Registration, channelID string) bool {
+	for _, reg := range regs {
+		if reg.Channel.ID == channelID {
+			return true
+		}
+	}
+	return false
+}
+
+// newID generates a random channel or lock owner ID with the prefix.
+func newID(prefix string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate ID: %w", err)
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
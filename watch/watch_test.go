@@ -0,0 +1,274 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jemgunay/canlendar-graph/calendar"
+	"github.com/jemgunay/canlendar-graph/collect"
+	"github.com/jemgunay/canlendar-graph/config"
+	"github.com/jemgunay/canlendar-graph/storage"
+	"github.com/jemgunay/canlendar-graph/tenant"
+
+	mock_storage "github.com/jemgunay/canlendar-graph/storage/mocks"
+)
+
+// fakeProvider provides a Fake for each calendar.
+type fakeProvider map[string]*Fake
+
+func (p fakeProvider) Fetcher(string, string) (calendar.Fetcher, error) {
+	return nil, nil
+}
+
+func (p fakeProvider) Watcher(calendarID string) (calendar.Watcher, error) {
+	return p[calendarID], nil
+}
+
+// memoryStore stores registered channels in memory.
+type memoryStore struct {
+	regs []Registration
+}
+
+func (s *memoryStore) ReadChannels(context.Context) ([]Registration, error) {
+	return s.regs, nil
+}
+
+func (s *memoryStore) WriteChannels(_ context.Context, regs []Registration) error {
+	s.regs = regs
+	return nil
+}
+
+func TestManager_Register(t *testing.T) {
+	// accept every notification
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	fakes := fakeProvider{
+		"alice-calendar": NewFake(srv.Client()),
+		"bob-calendar":   NewFake(srv.Client()),
+	}
+	users, err := tenant.New([]tenant.User{
+		{ID: "alice", CalendarID: "alice-calendar"},
+		{ID: "bob", CalendarID: "bob-calendar"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create users: %s", err)
+	}
+
+	conf := config.Watch{
+		Address:          srv.URL,
+		Secret:           "0123456789abcdef0123456789abcdef",
+		TTLHours:         168,
+		RenewBeforeHours: 24,
+	}
+	store := &memoryStore{}
+	m, err := New(conf, users, fakes, store)
+	if err != nil {
+		t.Fatalf("failed to create manager: %s", err)
+	}
+	start := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return start }
+
+	// a channel is registered for each user, with a verifiable token
+	renewAt, err := m.Register(context.Background())
+	if err != nil {
+		t.Fatalf("failed to register: %s", err)
+	}
+	if expected := start.Add(144 * time.Hour); !renewAt.Equal(expected) {
+		t.Fatalf("expected %s, got %s", expected, renewAt)
+	}
+	initial := channelIDs(fakes)
+	if len(initial) != 2 {
+		t.Fatalf("expected %d channels, got %d", 2, len(initial))
+	}
+	for _, fake := range fakes {
+		for _, channel := range fake.Channels() {
+			if !m.Verify(channel.ID, channel.Token) {
+				t.Fatalf("expected channel %s token to be valid", channel.ID)
+			}
+		}
+	}
+
+	// notifications are attributed to the user whose calendar the channel is registered for
+	owner, err := m.Owner(context.Background(), fakes["bob-calendar"].Channels()[0].ID)
+	if err != nil || owner.ID != "bob" {
+		t.Fatalf("expected bob to own the channel, got %+v (%v)", owner, err)
+	}
+	if _, err := m.Owner(context.Background(), "unknown"); !errors.Is(err, ErrUnknownChannel) {
+		t.Fatalf("expected %v, got %v", ErrUnknownChannel, err)
+	}
+
+	// channels are reused until they are due for renewal
+	m.now = func() time.Time { return start.Add(time.Hour) }
+	if _, err := m.Register(context.Background()); err != nil {
+		t.Fatalf("failed to register: %s", err)
+	}
+	if ids := channelIDs(fakes); !reflect.DeepEqual(ids, initial) {
+		t.Fatalf("expected channels to be reused, got %v", ids)
+	}
+
+	// channels due for renewal are replaced, and the replaced channels are stopped
+	m.now = func() time.Time { return start.Add(145 * time.Hour) }
+	if _, err := m.Register(context.Background()); err != nil {
+		t.Fatalf("failed to register: %s", err)
+	}
+	renewed := channelIDs(fakes)
+	if len(renewed) != 2 || renewed["alice-calendar"] == initial["alice-calendar"] ||
+		renewed["bob-calendar"] == initial["bob-calendar"] {
+		t.Fatalf("expected channels to be renewed, got %v", renewed)
+	}
+
+	// the channels of removed users are stopped
+	bobChannel := fakes["bob-calendar"].Channels()[0].ID
+	m.users = tenant.Single(tenant.User{ID: "alice", CalendarID: "alice-calendar"})
+	if _, err := m.Owner(context.Background(), bobChannel); !errors.Is(err, ErrUnknownChannel) {
+		t.Fatalf("expected %v for removed user, got %v", ErrUnknownChannel, err)
+	}
+	if _, err := m.Register(context.Background()); err != nil {
+		t.Fatalf("failed to register: %s", err)
+	}
+	if ids := channelIDs(fakes); len(ids) != 1 || ids["alice-calendar"] != renewed["alice-calendar"] {
+		t.Fatalf("expected only alice's channel to remain, got %v", ids)
+	}
+
+	if err := m.Stop(context.Background()); err != nil {
+		t.Fatalf("failed to stop: %s", err)
+	}
+	if ids := channelIDs(fakes); len(ids) != 0 {
+		t.Fatalf("expected no channels, got %v", ids)
+	}
+}
+
+func TestManager_Register_Lock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cases := []struct {
+		name     string
+		lockErr  error
+		channels int
+	}{
+		{
+			name:     "acquired",
+			channels: 1,
+		},
+		{
+			name:    "locked",
+			lockErr: storage.ErrLocked,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			defer srv.Close()
+			fakes := fakeProvider{"units": NewFake(srv.Client())}
+
+			// channels are only registered while holding the collect lock, which is released afterwards
+			mockLocker := mock_storage.NewMockLocker(ctrl)
+			var owner string
+			mockLocker.EXPECT().Lock(gomock.Any(), collect.LockName, gomock.Any(), time.Minute).DoAndReturn(
+				func(_ context.Context, _, o string, _ time.Duration) error {
+					owner = o
+					return tt.lockErr
+				},
+			)
+			if tt.lockErr == nil {
+				mockLocker.EXPECT().Unlock(gomock.Any(), collect.LockName, gomock.Any()).DoAndReturn(
+					func(_ context.Context, _, o string) error {
+						if o != owner {
+							t.Fatalf("expected lock to be released by %s, got %s", owner, o)
+						}
+						return nil
+					},
+				)
+			}
+
+			store := &memoryStore{}
+			m, err := New(config.Watch{
+				Address:          srv.URL,
+				Secret:           "0123456789abcdef0123456789abcdef",
+				TTLHours:         168,
+				RenewBeforeHours: 24,
+			}, tenant.Single(tenant.User{CalendarID: "units"}), fakes, store, WithLocker(mockLocker, time.Minute))
+			if err != nil {
+				t.Fatalf("failed to create manager: %s", err)
+			}
+
+			_, err = m.Register(context.Background())
+			if !errors.Is(err, tt.lockErr) {
+				t.Fatalf("expected %v, got %v", tt.lockErr, err)
+			}
+			if channels := len(fakes["units"].Channels()); channels != tt.channels || len(store.regs) != tt.channels {
+				t.Fatalf("expected %d channels, got %d (%d stored)", tt.channels, channels, len(store.regs))
+			}
+		})
+	}
+}
+
+func TestManager_Verify(t *testing.T) {
+	m, err := New(config.Watch{
+		Address:          "https://example.com/api/v1/watch",
+		Secret:           "0123456789abcdef0123456789abcdef",
+		TTLHours:         168,
+		RenewBeforeHours: 24,
+	}, tenant.Single(tenant.User{}), nil, &memoryStore{})
+	if err != nil {
+		t.Fatalf("failed to create manager: %s", err)
+	}
+
+	cases := []struct {
+		name      string
+		channelID string
+		token     string
+		valid     bool
+	}{
+		{
+			name:      "valid",
+			channelID: "a",
+			token:     m.Token("a"),
+			valid:     true,
+		},
+		{
+			name:      "other_channel",
+			channelID: "a",
+			token:     m.Token("b"),
+		},
+		{
+			name:      "no_token",
+			channelID: "a",
+		},
+		{
+			name: "no_channel",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if valid := m.Verify(tt.channelID, tt.token); valid != tt.valid {
+				t.Fatalf("expected %t, got %t", tt.valid, valid)
+			}
+		})
+	}
+}
+
+// channelIDs returns the IDs of the registered channels, keyed by calendar. Channel IDs are joined if a calendar has
+// more than one channel.
+func channelIDs(fakes fakeProvider) map[string]string {
+	ids := make(map[string]string)
+	for calendarID, fake := range fakes {
+		for _, channel := range fake.Channels() {
+			if ids[calendarID] != "" {
+				ids[calendarID] += ","
+			}
+			ids[calendarID] += channel.ID
+		}
+	}
+	return ids
+}